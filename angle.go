@@ -0,0 +1,242 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// AngleUnit is the unit trigonometric functions take and return angles in
+type AngleUnit int
+
+const (
+	Radians AngleUnit = iota
+	Degrees
+	Gradians
+)
+
+var angleUnitNames = map[AngleUnit]string{
+	Radians:  "rad",
+	Degrees:  "deg",
+	Gradians: "grad",
+}
+
+func (a AngleUnit) String() string {
+	return angleUnitNames[a]
+}
+
+// ParseAngleUnit converts a unit name (rad, deg or grad) to an AngleUnit
+func ParseAngleUnit(name string) (AngleUnit, error) {
+	for unit, unitName := range angleUnitNames {
+		if unitName == name {
+			return unit, nil
+		}
+	}
+
+	return Radians, fmt.Errorf("Invalid angle unit ‘%s’", name)
+}
+
+// fullTurn returns the size of a full turn in the given unit. Radians have
+// no exact full turn and return nil.
+func (a AngleUnit) fullTurn() *big.Rat {
+	switch a {
+	case Degrees:
+		return big.NewRat(360, 1)
+	case Gradians:
+		return big.NewRat(400, 1)
+	}
+
+	return nil
+}
+
+// turns converts an angle to a fraction of a full turn in [0, 1). For radians,
+// ok is false as the fraction can't be calculated exactly.
+func (a AngleUnit) turns(angle *big.Rat) (frac *big.Rat, ok bool) {
+	turn := a.fullTurn()
+	if turn == nil {
+		return nil, false
+	}
+
+	frac = new(big.Rat).Quo(angle, turn)
+	return frac.Sub(frac, Floor(frac)), true
+}
+
+// toRadians converts an angle in the given unit to radians as a float64
+func (a AngleUnit) toRadians(angle *big.Rat) float64 {
+	if frac, ok := a.turns(angle); ok {
+		float, _ := frac.Float64()
+		return float * 2 * math.Pi
+	}
+
+	float, _ := angle.Float64()
+	return float
+}
+
+// inverse returns the result of an inverse trigonometric function in the given
+// unit. exact gives the result in turns if it's rational, otherwise the result
+// in radians is converted.
+func (a AngleUnit) inverse(exact func() (*big.Rat, bool), radians float64) (*big.Rat, error) {
+	if turn := a.fullTurn(); turn != nil {
+		if frac, ok := exact(); ok {
+			return frac.Mul(frac, turn), nil
+		}
+	}
+
+	return a.fromRadians(radians)
+}
+
+// fromRadians converts an angle in radians to the given unit
+func (a AngleUnit) fromRadians(angle float64) (*big.Rat, error) {
+	if turn := a.fullTurn(); turn != nil {
+		res, err := floatResult(angle / (2 * math.Pi))
+		if err != nil {
+			return nil, err
+		}
+		return res.Mul(res, turn), nil
+	}
+
+	return floatResult(angle)
+}
+
+// sineTable holds the sines of multiples of 1/12 turn that are rational, the
+// others are nil
+var sineTable = [12]*big.Rat{
+	big.NewRat(0, 1), big.NewRat(1, 2), nil, big.NewRat(1, 1), nil, big.NewRat(1, 2),
+	big.NewRat(0, 1), big.NewRat(-1, 2), nil, big.NewRat(-1, 1), nil, big.NewRat(-1, 2),
+}
+
+// exactSin returns the sine of frac turns if it's rational
+func exactSin(frac *big.Rat) (*big.Rat, bool) {
+	twelfths := new(big.Rat).Mul(frac, big.NewRat(12, 1))
+	if !twelfths.IsInt() {
+		return nil, false
+	}
+
+	res := sineTable[twelfths.Num().Int64()%12]
+	return res, res != nil
+}
+
+// exactCos returns the cosine of frac turns if it's rational
+func exactCos(frac *big.Rat) (*big.Rat, bool) {
+	shifted := new(big.Rat).Add(frac, big.NewRat(1, 4))
+	return exactSin(shifted.Sub(shifted, Floor(shifted)))
+}
+
+// exactAsin returns the arcsine of x in turns if it's rational
+func exactAsin(x *big.Rat) (*big.Rat, bool) {
+	for i := 9; i <= 15; i++ {
+		sin := sineTable[i%12]
+		if sin != nil && sin.Cmp(x) == 0 {
+			return big.NewRat(int64(i-12), 12), true
+		}
+	}
+
+	return nil, false
+}
+
+// exactAtan2 returns the angle of the point (x, y) in turns if it's a multiple
+// of 1/8 turn
+func exactAtan2(y, x *big.Rat) (*big.Rat, bool) {
+	var eighths int64
+	switch {
+	case y.Sign() == 0 && x.Sign() >= 0:
+		eighths = 0
+	case y.Sign() == 0:
+		eighths = 4
+	case x.Sign() == 0:
+		eighths = 2 * int64(y.Sign())
+	case new(big.Rat).Abs(x).Cmp(new(big.Rat).Abs(y)) == 0:
+		eighths = int64(y.Sign())
+		if x.Sign() < 0 {
+			eighths *= 3
+		}
+	default:
+		return nil, false
+	}
+
+	return big.NewRat(eighths, 8), true
+}
+
+// floatResult converts the float64 result of a function, erroring on NaN and
+// infinity
+func floatResult(f float64) (*big.Rat, error) {
+	if math.IsNaN(f) {
+		return nil, ErrDomain
+	}
+	if math.IsInf(f, 0) {
+		return nil, ErrNotFinite
+	}
+
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+// tangentTable holds the tangents of multiples of 1/8 turn, nil where the
+// tangent is undefined
+var tangentTable = [8]*big.Rat{
+	big.NewRat(0, 1), big.NewRat(1, 1), nil, big.NewRat(-1, 1),
+	big.NewRat(0, 1), big.NewRat(1, 1), nil, big.NewRat(-1, 1),
+}
+
+// angleSin calculates the sine of an angle in the given unit
+func angleSin(unit AngleUnit, angle *big.Rat) (*big.Rat, error) {
+	if frac, ok := unit.turns(angle); ok {
+		if res, ok := exactSin(frac); ok {
+			return new(big.Rat).Set(res), nil
+		}
+	}
+
+	return floatResult(math.Sin(unit.toRadians(angle)))
+}
+
+// angleCos calculates the cosine of an angle in the given unit
+func angleCos(unit AngleUnit, angle *big.Rat) (*big.Rat, error) {
+	if frac, ok := unit.turns(angle); ok {
+		if res, ok := exactCos(frac); ok {
+			return new(big.Rat).Set(res), nil
+		}
+	}
+
+	return floatResult(math.Cos(unit.toRadians(angle)))
+}
+
+// angleTan calculates the tangent of an angle in the given unit
+func angleTan(unit AngleUnit, angle *big.Rat) (*big.Rat, error) {
+	if frac, ok := unit.turns(angle); ok {
+		if eighths := new(big.Rat).Mul(frac, big.NewRat(8, 1)); eighths.IsInt() {
+			res := tangentTable[eighths.Num().Int64()%8]
+			if res == nil {
+				return nil, ErrDomain
+			}
+			return new(big.Rat).Set(res), nil
+		}
+	}
+
+	return floatResult(math.Tan(unit.toRadians(angle)))
+}
+
+// reciprocal calculates 1 / x, for the reciprocal trigonometric functions
+func reciprocal(x *big.Rat, err error) (*big.Rat, error) {
+	if err != nil {
+		return nil, err
+	}
+	if x.Sign() == 0 {
+		return nil, ErrDomain
+	}
+
+	return x.Inv(x), nil
+}
+
+// angleCot calculates the cotangent of an angle in the given unit
+func angleCot(unit AngleUnit, angle *big.Rat) (*big.Rat, error) {
+	if frac, ok := unit.turns(angle); ok {
+		// cot(x) = tan(1/4 turn - x)
+		shifted := new(big.Rat).Sub(big.NewRat(1, 4), frac)
+		return angleTan(unit, shifted.Mul(shifted, unit.fullTurn()))
+	}
+
+	return floatResult(1 / math.Tan(unit.toRadians(angle)))
+}
@@ -0,0 +1,418 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Node is a node in the tree of a parsed expression
+type Node interface {
+	// Pos returns the position of the node in the expression
+	Pos() int
+	// String returns the node as expression
+	String() string
+}
+
+type (
+	// NumberNode is a number literal
+	NumberNode struct {
+		Tok   *Token
+		Value *big.Rat
+	}
+
+	// IdentNode is a variable
+	IdentNode struct {
+		Tok *Token
+	}
+
+	// UnaryNode is a unary operation like -x
+	UnaryNode struct {
+		Op *Token
+		X  Node
+	}
+
+	// BinaryNode is a binary operation like x + y, including assignments
+	BinaryNode struct {
+		Op       *Token
+		Lhs, Rhs Node
+	}
+
+	// CallNode is a function call
+	CallNode struct {
+		Func *Token
+		Args []Node
+	}
+
+	// MatrixNode is a matrix literal. Breaks holds the element counts at which
+	// rows end, except for the last row.
+	MatrixNode struct {
+		Lbracket *Token
+		Elems    []Node
+		Breaks   []int
+	}
+)
+
+func (n *NumberNode) Pos() int { return n.Tok.Pos }
+func (n *IdentNode) Pos() int  { return n.Tok.Pos }
+func (n *UnaryNode) Pos() int  { return n.Op.Pos }
+func (n *BinaryNode) Pos() int { return n.Lhs.Pos() }
+func (n *CallNode) Pos() int   { return n.Func.Pos }
+func (n *MatrixNode) Pos() int { return n.Lbracket.Pos }
+
+// Name returns the name of the variable
+func (n *IdentNode) Name() string {
+	return n.Tok.Value
+}
+
+// Parse parses an expression into a tree without evaluating it. The tree can
+// be evaluated any number of times without lexing and parsing it again.
+//
+// Example:
+//     tree, err := mathcat.Parse("2*x + 1")
+func Parse(expr string) (Node, error) {
+	tokens, err := Lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &treeParser{tokens: tokens}
+	if t.peek().Is(Eol) {
+		return nil, fmt.Errorf("Unexpected ‘%s’", t.peek().Type)
+	}
+
+	node, err := t.expr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := t.peek(); !tok.Is(Eol) {
+		return nil, t.unexpected(tok)
+	}
+
+	return node, nil
+}
+
+// treeParser builds a tree from tokens using precedence climbing with the same
+// precedence and associativity as the operators table
+type treeParser struct {
+	tokens Tokens
+	pos    int
+}
+
+func (t *treeParser) peek() *Token {
+	return t.tokens[t.pos]
+}
+
+func (t *treeParser) next() *Token {
+	tok := t.tokens[t.pos]
+	if !tok.Is(Eol) {
+		t.pos++
+	}
+	return tok
+}
+
+func (t *treeParser) unexpected(tok *Token) error {
+	switch tok.Type {
+	case Rparen:
+		return ErrUnmatchedParentheses
+	case Rbracket:
+		return ErrUnmatchedBrackets
+	case Comma:
+		return ErrMisplacedComma
+	case Semicolon:
+		return ErrMisplacedSemicolon
+	case Eol:
+		return fmt.Errorf("Unexpected ‘%s’", tok.Type)
+	}
+
+	return fmt.Errorf("Unexpected ‘%s’", tok)
+}
+
+// expr parses a binary expression with operators of at least precedence prec
+func (t *treeParser) expr(prec int) (Node, error) {
+	lhs, err := t.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := t.peek()
+		op, ok := operators[tok.Type]
+		if !ok || op.unary || op.prec < prec {
+			return lhs, nil
+		}
+		t.next()
+
+		next := op.prec
+		if op.assoc == AssocLeft {
+			next++
+		}
+
+		rhs, err := t.expr(next)
+		if err != nil {
+			return nil, err
+		}
+
+		lhs = &BinaryNode{Op: tok, Lhs: lhs, Rhs: rhs}
+	}
+}
+
+func (t *treeParser) unary() (Node, error) {
+	if tok := t.peek(); tok.Is(UnaryMin) || tok.Is(Not) {
+		t.next()
+		// Like the evaluator, only allow a unary operator to follow another
+		// one if it binds tighter, so ‘~-x’ is fine but ‘- -x’ and ‘~~x’ are not
+		if next, ok := operators[t.peek().Type]; ok && next.unary &&
+			next.prec <= operators[tok.Type].prec {
+			return nil, fmt.Errorf("Unexpected ‘%s’", tok)
+		}
+		x, err := t.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &UnaryNode{Op: tok, X: x}, nil
+	}
+
+	return t.operand()
+}
+
+func (t *treeParser) operand() (Node, error) {
+	tok := t.next()
+
+	switch {
+	case tok.Is(Ident):
+		if t.peek().Is(Lparen) {
+			return t.call(tok)
+		}
+		return &IdentNode{Tok: tok}, nil
+	case tok.IsLiteral():
+		val, err := parseNumber(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &NumberNode{Tok: tok, Value: val}, nil
+	case tok.Is(Lparen):
+		x, err := t.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		if !t.next().Is(Rparen) {
+			return nil, ErrUnmatchedParentheses
+		}
+		return x, nil
+	case tok.Is(Lbracket):
+		return t.matrix(tok)
+	}
+
+	return nil, t.unexpected(tok)
+}
+
+// call parses the arguments of a function call, the next token being the
+// opening parenthesis
+func (t *treeParser) call(fn *Token) (*CallNode, error) {
+	t.next()
+
+	node := &CallNode{Func: fn}
+	if t.peek().Is(Rparen) {
+		t.next()
+		return node, nil
+	}
+
+	for {
+		arg, err := t.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		node.Args = append(node.Args, arg)
+
+		switch tok := t.next(); tok.Type {
+		case Comma:
+		case Rparen:
+			return node, nil
+		case Eol, Rbracket:
+			return nil, ErrUnmatchedParentheses
+		default:
+			return nil, t.unexpected(tok)
+		}
+	}
+}
+
+func (t *treeParser) matrix(lbracket *Token) (*MatrixNode, error) {
+	node := &MatrixNode{Lbracket: lbracket}
+	if t.peek().Is(Rbracket) {
+		return nil, ErrEmptyMatrix
+	}
+
+	for {
+		elem, err := t.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		node.Elems = append(node.Elems, elem)
+
+		switch tok := t.next(); tok.Type {
+		case Comma:
+		case Semicolon:
+			node.Breaks = append(node.Breaks, len(node.Elems))
+		case Rbracket:
+			return node, nil
+		case Eol, Rparen:
+			return nil, ErrUnmatchedBrackets
+		default:
+			return nil, t.unexpected(tok)
+		}
+	}
+}
+
+// EvalNode evaluates a parsed expression on the parser instance
+func (p *Parser) EvalNode(n Node) (Value, error) {
+	return p.evalNode(n, nil)
+}
+
+// evalNode evaluates a node, looking up variables in scope before the parser's
+// variables
+func (p *Parser) evalNode(n Node, scope map[string]*big.Rat) (Value, error) {
+	switch n := n.(type) {
+	case *NumberNode:
+		return p.round(n.Value)
+	case *IdentNode:
+		if val, ok := scope[n.Name()]; ok {
+			return val, nil
+		}
+		return p.lookupValue(n.Tok)
+	case *UnaryNode:
+		x, err := p.evalNode(n.X, scope)
+		if err != nil {
+			return nil, err
+		}
+		return p.applyOperator(n.Op, nil, x)
+	case *BinaryNode:
+		return p.evalBinary(n, scope)
+	case *CallNode:
+		return p.evalCall(n, scope)
+	case *MatrixNode:
+		elems := make([]Value, len(n.Elems))
+		for i, elem := range n.Elems {
+			val, err := p.evalNode(elem, scope)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = val
+		}
+		return buildMatrix(elems, n.Breaks)
+	}
+
+	return nil, fmt.Errorf("Invalid node ‘%T’", n)
+}
+
+func (p *Parser) evalBinary(n *BinaryNode, scope map[string]*big.Rat) (Value, error) {
+	var lhs Value
+
+	rhs, err := p.evalNode(n.Rhs, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't evaluate the left hand side of = so we can do initial assignment
+	if !n.Op.Is(Eq) {
+		if lhs, err = p.evalNode(n.Lhs, scope); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := p.applyOperator(n.Op, lhs, rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.Op.IsAssignment() {
+		ident, ok := n.Lhs.(*IdentNode)
+		if !ok {
+			return nil, ErrAssignToLiteral
+		}
+		p.setVar(ident.Name(), result)
+	}
+
+	return result, nil
+}
+
+func (p *Parser) evalCall(n *CallNode, scope map[string]*big.Rat) (Value, error) {
+	function, ok := funcs[n.Func.Value]
+	if !ok {
+		return nil, fmt.Errorf("Undefined function ‘%s’", n.Func)
+	}
+
+	if err := p.checkCall(n.Func, function, len(n.Args)); err != nil {
+		return nil, err
+	}
+
+	// Functions with expression arguments get them unevaluated
+	if function.exprFn != nil {
+		args := make([]*Expr, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = &Expr{node: arg, p: p, scope: scope}
+		}
+		res, err := function.exprFn(p, args)
+		if err != nil {
+			return nil, err
+		}
+		return p.round(res)
+	}
+
+	values := make([]Value, len(n.Args))
+	for i, arg := range n.Args {
+		val, err := p.evalNode(arg, scope)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+
+	return p.call(n.Func, function, values)
+}
+
+// lazyCall parses and evaluates a call to a function with expression
+// arguments, starting at the current token. The arguments are skipped by the
+// parser so they aren't evaluated.
+func (p *Parser) lazyCall() (Value, error) {
+	t := &treeParser{tokens: p.Tokens, pos: p.pos}
+
+	node, err := t.call(p.tok)
+	if err != nil {
+		return nil, err
+	}
+
+	p.pos = t.pos
+	p.tok = p.Tokens[p.pos-1]
+
+	return p.evalNode(node, nil)
+}
+
+// Inspect traverses a tree in depth-first order, calling fn for every node. If
+// fn returns false, the children of the node are skipped.
+func Inspect(n Node, fn func(Node) bool) {
+	if !fn(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	case *UnaryNode:
+		Inspect(n.X, fn)
+	case *BinaryNode:
+		Inspect(n.Lhs, fn)
+		Inspect(n.Rhs, fn)
+	case *CallNode:
+		for _, arg := range n.Args {
+			Inspect(arg, fn)
+		}
+	case *MatrixNode:
+		for _, elem := range n.Elems {
+			Inspect(elem, fn)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseTree(t *testing.T) {
+	// Evaluating the tree has to give the same result as evaluating inline
+	exprs := []string{
+		"1 + 2 * 3", "(1 + 2) * 3", "-2 ** 2", "2 ** 3 ** 2", "~5 & 0xff",
+		"1 << 2 << 3", "10 % 4 - 7 / 2", "1 < 2 == 1", "max(3, abs(-4)) ** 2",
+		"2*pi", "3*(1 + 2)", "0x10 | 0b11 ^ 0o7", "-(-(3))", "[1, 2; 3, 4] * [1; 1]",
+		"det([[1, 2], [3, 4]])", "a = 3", "a = b = 4", "1 - -1", "5 != 4 + 1",
+		"~-5",
+	}
+
+	for _, expr := range exprs {
+		expected, err := New().RunValue(expr)
+		if err != nil {
+			t.Errorf("unexpected error evaluating '%s': %s", expr, err)
+			continue
+		}
+
+		tree, err := Parse(expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing '%s': %s", expr, err)
+			continue
+		}
+
+		res, err := New().EvalNode(tree)
+		if err != nil {
+			t.Errorf("unexpected error evaluating tree of '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected.String() {
+			t.Errorf("wrong result evaluating tree of '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	badExprs := []string{
+		"", "1 +", "(1 + 2", "1 + 2)", "[1, 2", "max(1, 2", "1 2", "1, 2",
+		"[]", "f(1;2)", "~~2", "- -x", "-~2", "2x", "3(1 + 2)",
+	}
+
+	for _, expr := range badExprs {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error parsing '%s'", expr)
+		}
+	}
+}
+
+func TestExprVariables(t *testing.T) {
+	p := New()
+	p.Run("a = 2")
+
+	tree, err := Parse("a * x + 1")
+	if err != nil {
+		t.Fatalf("unexpected error parsing: %s", err)
+	}
+
+	e := &Expr{node: tree, p: p}
+	res, err := e.EvalRat(map[string]*big.Rat{"x": big.NewRat(5, 1)})
+	if err != nil || res.Cmp(big.NewRat(11, 1)) != 0 {
+		t.Errorf("wrong result evaluating with bound variable: %v, %v", res, err)
+	}
+
+	// Bindings don't leak into the parser's variables
+	if _, err := p.GetVar("x"); err == nil {
+		t.Error("bound variable leaked into parser variables")
+	}
+	if _, err := e.Eval(nil); err == nil {
+		t.Error("expected error evaluating with unbound variable")
+	}
+}
+
+func TestPrintTree(t *testing.T) {
+	exprs := map[string]string{
+		"1+2*3":           "1 + 2*3",
+		"(1 + 2) * 3":     "(1 + 2)*3",
+		"-2 ** 2":         "(-2)**2",
+		"-(2 ** 2)":       "-(2**2)",
+		"2 ** 3 ** 2":     "(2**3)**2",
+		"2 ** (3 ** 2)":   "2**(3**2)",
+		"1 - (2 - 3)":     "1 - (2 - 3)",
+		"1 << 2 << 3":     "1 << 2 << 3",
+		"(1 << 2) << 3":   "(1 << 2) << 3",
+		"10 % 4 - 7 / 2":  "10 % 4 - 7/2",
+		"-(-(3))":         "-(-3)",
+		"max(3,abs(-4))":  "max(3, abs(-4))",
+		"[1,2;3,4]*[1;1]": "[1, 2; 3, 4]*[1; 1]",
+		"a = b = 4":       "a = b = 4",
+		"~5 & 0xff":       "~5 & 0xff",
+		"2 * pi":          "2*pi",
+	}
+
+	for expr, expected := range exprs {
+		tree, err := Parse(expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing '%s': %s", expr, err)
+			continue
+		}
+
+		if tree.String() != expected {
+			t.Errorf("wrong text of '%s' (expected %s, got %s)", expr, expected, tree)
+		}
+
+		// The text has to parse into the same tree
+		again, err := Parse(tree.String())
+		if err != nil || again.String() != expected {
+			t.Errorf("text of '%s' doesn't parse into the same tree: %v, %v", expr, again, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	exprs := map[string]string{
+		"((2*x))+ 3 #  area":       "2*x + 3 #  area",
+		"  1+2   ":                 "1 + 2",
+		"a=(b)*  -c#note   ":       "a = b*-c #note",
+		"# only a comment":         "# only a comment",
+		"":                         "",
+		"0xFF  +  24e3":            "0xFF + 24e3",
+		"sin( (pi) /2 )**2":        "sin(pi/2)**2",
+		"[ 1 ,2 ; (3) , 4 ]":       "[1, 2; 3, 4]",
+		"x+=1 # # not a new one":   "x += 1 # # not a new one",
+		"2 ** 3 ** 2 # left assoc": "(2**3)**2 # left assoc",
+		"~ -x":                     "~-x",
+	}
+
+	for expr, expected := range exprs {
+		res, err := Format(expr)
+		if err != nil {
+			t.Errorf("unexpected error formatting '%s': %s", expr, err)
+			continue
+		}
+
+		if res != expected {
+			t.Errorf("wrong format of '%s' (expected '%s', got '%s')", expr, expected, res)
+		}
+
+		// Formatting is idempotent
+		if again, err := Format(res); err != nil || again != res {
+			t.Errorf("format of '%s' isn't canonical: '%s', %v", expr, again, err)
+		}
+	}
+
+	// Formatted expressions evaluate to the same value
+	for _, expr := range []string{"-2 ** 2", "1 - (2 - 3) * 4", "10 % (4 - 7) / 2", "2*(3 + 4) # x", "~5 & 0xff | 3 << 1"} {
+		res, err := Format(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, _ := Eval(expr)
+		got, err := Eval(res)
+		if err != nil || got.Cmp(expected) != 0 {
+			t.Errorf("format of '%s' changed its value (expected %s, got %v, %v)", expr, expected, got, err)
+		}
+	}
+
+	for _, expr := range []string{"1 +", "$", "(1"} {
+		if _, err := Format(expr); err == nil {
+			t.Errorf("expected error formatting '%s'", expr)
+		}
+	}
+
+	// Expressions the evaluator rejects can't be formatted into ones it accepts
+	for _, expr := range []string{"- -x", "~~2", "-~2", "--2", "1 * * 2"} {
+		_, runErr := New().Run(expr)
+		_, parseErr := Parse(expr)
+		res, err := Format(expr)
+		if runErr == nil || parseErr == nil || err == nil {
+			t.Errorf("expected errors on '%s' (run: %v, parse: %v, format: '%s', %v)",
+				expr, runErr, parseErr, res, err)
+		}
+	}
+}
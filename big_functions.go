@@ -7,7 +7,11 @@ func RationalToInteger(n *big.Rat) *big.Int {
 	return new(big.Int).Div(n.Num(), n.Denom())
 }
 
-// Factorial calculates the factorial of rational number n
+// Factorial calculates the factorial of rational number n, truncated to an
+// integer.
+//
+// Deprecated: Factorial truncates non-integers, so Factorial(4.5) is 24. Use
+// Gamma(n + 1) for the factorial of any number, as fact does.
 func Factorial(n *big.Rat) *big.Rat {
 	integer := RationalToInteger(n)
 	fact := new(big.Int).MulRange(1, integer.Int64())
@@ -0,0 +1,321 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// DefaultPrecision is the number of mantissa bits used for functions that
+// can't be calculated exactly, like gamma and erf
+const DefaultPrecision = 64
+
+// guardBits are the extra bits of precision used during intermediate
+// calculations, so rounding errors don't show up in the result
+const guardBits = 32
+
+var ErrDomain = errors.New("Argument outside of function domain")
+
+// newFloat returns a new float with given precision, set to x
+func newFloat(prec uint, x int64) *big.Float {
+	return new(big.Float).SetPrec(prec).SetInt64(x)
+}
+
+// exponent returns the binary exponent of x, so that 0.5 <= |x| / 2**exp < 1
+func exponent(x *big.Float) int {
+	return x.MantExp(nil)
+}
+
+// negligible reports whether term is too small to change sum at precision
+// prec
+func negligible(term, sum *big.Float, prec uint) bool {
+	if term.Sign() == 0 {
+		return true
+	}
+	if sum.Sign() == 0 {
+		return false
+	}
+
+	return exponent(term) < exponent(sum)-int(prec)-2
+}
+
+// constCache caches a mathematical constant at the highest precision
+// requested so far
+type constCache struct {
+	sync.Mutex
+	val  *big.Float
+	calc func(prec uint) *big.Float
+}
+
+func (c *constCache) get(prec uint) *big.Float {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.val == nil || c.val.Prec() < prec {
+		c.val = c.calc(prec + guardBits)
+	}
+
+	return new(big.Float).SetPrec(prec).Set(c.val)
+}
+
+var (
+	piCache = &constCache{calc: func(prec uint) *big.Float {
+		// Machin's formula, pi = 16 * atan(1/5) - 4 * atan(1/239)
+		a := atanInv(5, prec)
+		b := atanInv(239, prec)
+		a.Mul(a, newFloat(prec, 16))
+		b.Mul(b, newFloat(prec, 4))
+		return a.Sub(a, b)
+	}}
+	ln2Cache = &constCache{calc: func(prec uint) *big.Float {
+		// ln(2) = 2 * atanh(1/3)
+		z := newFloat(prec, 1)
+		z.Quo(z, newFloat(prec, 3))
+		return atanhSeries(z, prec)
+	}}
+)
+
+// bigPi returns pi with given precision
+func bigPi(prec uint) *big.Float {
+	return piCache.get(prec)
+}
+
+// atanInv calculates atan(1/n) with the Taylor series
+func atanInv(n int64, prec uint) *big.Float {
+	var (
+		sum    = newFloat(prec, 0)
+		power  = newFloat(prec, 1)
+		term   = new(big.Float).SetPrec(prec)
+		bigN   = newFloat(prec, n)
+		nSq    = newFloat(prec, n*n)
+		offset = int64(1)
+	)
+
+	power.Quo(power, bigN)
+	for i := 0; ; i++ {
+		term.Quo(power, newFloat(prec, offset))
+		if negligible(term, sum, prec) {
+			break
+		}
+
+		if i%2 == 0 {
+			sum.Add(sum, term)
+		} else {
+			sum.Sub(sum, term)
+		}
+
+		power.Quo(power, nSq)
+		offset += 2
+	}
+
+	return sum
+}
+
+// atanhSeries calculates 2 * atanh(z) = ln((1 + z) / (1 - z)) for small |z|
+func atanhSeries(z *big.Float, prec uint) *big.Float {
+	var (
+		sum    = new(big.Float).SetPrec(prec).Set(z)
+		power  = new(big.Float).SetPrec(prec).Set(z)
+		zSq    = new(big.Float).SetPrec(prec).Mul(z, z)
+		term   = new(big.Float).SetPrec(prec)
+		offset = int64(3)
+	)
+
+	for {
+		power.Mul(power, zSq)
+		term.Quo(power, newFloat(prec, offset))
+		if negligible(term, sum, prec) {
+			break
+		}
+		sum.Add(sum, term)
+		offset += 2
+	}
+
+	return sum.Add(sum, sum)
+}
+
+// bigExp calculates e**x with given precision
+func bigExp(x *big.Float, prec uint) *big.Float {
+	if x.Sign() == 0 {
+		return newFloat(prec, 1)
+	}
+
+	// Scale x down to |r| < 2**-8 and square the result back up afterwards.
+	// Each squaring loses a bit, so account for those in the working
+	// precision.
+	squarings := 0
+	if exp := exponent(x); exp > -8 {
+		squarings = exp + 8
+	}
+	wp := prec + uint(squarings) + guardBits
+
+	r := new(big.Float).SetPrec(wp).SetMantExp(x, -squarings)
+	sum := newFloat(wp, 1)
+	term := newFloat(wp, 1)
+	for k := int64(1); ; k++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(wp, k))
+		if negligible(term, sum, wp) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+
+	for i := 0; i < squarings; i++ {
+		sum.Mul(sum, sum)
+	}
+
+	return sum.SetPrec(prec)
+}
+
+// bigLog calculates the natural logarithm of x > 0 with given precision
+func bigLog(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() <= 0 {
+		return nil, ErrDomain
+	}
+
+	wp := prec + guardBits
+
+	// x = m * 2**e, ln(x) = ln(m) + e * ln(2), with m scaled into
+	// [sqrt(0.5), sqrt(2)) so the series converges quickly
+	m := new(big.Float).SetPrec(wp)
+	e := x.MantExp(m)
+	if m.Cmp(big.NewFloat(0.7071067811865476)) < 0 {
+		m.SetMantExp(m, 1)
+		e--
+	}
+
+	// ln(m) = 2 * atanh((m - 1) / (m + 1))
+	num := new(big.Float).SetPrec(wp).Sub(m, newFloat(wp, 1))
+	den := new(big.Float).SetPrec(wp).Add(m, newFloat(wp, 1))
+	res := atanhSeries(num.Quo(num, den), wp)
+
+	if e != 0 {
+		ln2 := ln2Cache.get(wp + 32)
+		res.Add(res, ln2.Mul(ln2, newFloat(wp+32, int64(e))))
+	}
+
+	return res.SetPrec(prec), nil
+}
+
+// bigPow calculates x**y for x > 0 with given precision
+func bigPow(x, y *big.Float, prec uint) (*big.Float, error) {
+	if y.IsInt() {
+		if n, acc := y.Int64(); acc == big.Exact && n < 1<<16 && n > -(1<<16) {
+			return bigPowInt(x, n, prec), nil
+		}
+	}
+
+	// The exponent of the result can be large, so calculate the logarithm with
+	// some extra bits
+	ln, err := bigLog(x, prec+guardBits+uint(bitLen(exponent(y))))
+	if err != nil {
+		return nil, err
+	}
+
+	return bigExp(ln.Mul(ln, y), prec), nil
+}
+
+// bigPowInt calculates x**n by repeated squaring
+func bigPowInt(x *big.Float, n int64, prec uint) *big.Float {
+	wp := prec + guardBits
+	res := newFloat(wp, 1)
+	base := new(big.Float).SetPrec(wp).Set(x)
+
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			res.Mul(res, base)
+		}
+		base.Mul(base, base)
+	}
+
+	if neg {
+		res.Quo(newFloat(wp, 1), res)
+	}
+
+	return res.SetPrec(prec)
+}
+
+// bigSinCos calculates the sine and cosine of x with given precision
+func bigSinCos(x *big.Float, prec uint) (sin, cos *big.Float) {
+	// Reduce x to r in [-pi/4, pi/4] with x = r + k * pi/2. Large x need pi
+	// with more bits for the reduction to be accurate.
+	wp := prec + guardBits
+	if exp := exponent(x); exp > 0 {
+		wp += uint(exp)
+	}
+
+	halfPi := bigPi(wp)
+	halfPi.SetMantExp(halfPi, -1)
+
+	k := new(big.Float).SetPrec(wp).Quo(x, halfPi)
+	kInt := roundFloat(k)
+	r := new(big.Float).SetPrec(wp).Mul(halfPi, new(big.Float).SetPrec(wp).SetInt(kInt))
+	r.Sub(x, r)
+
+	// Taylor series for both sin(r) and cos(r)
+	sin = new(big.Float).SetPrec(wp).Set(r)
+	cos = newFloat(wp, 1)
+	term := new(big.Float).SetPrec(wp).Set(r)
+	rSq := new(big.Float).SetPrec(wp).Mul(r, r)
+	cosTerm := newFloat(wp, 1)
+	for n := int64(1); ; n++ {
+		// term = (-1)**n * r**(2n+1) / (2n+1)!
+		term.Mul(term, rSq)
+		term.Quo(term, newFloat(wp, -(2*n)*(2*n+1)))
+		cosTerm.Mul(cosTerm, rSq)
+		cosTerm.Quo(cosTerm, newFloat(wp, -(2*n-1)*(2*n)))
+
+		if negligible(term, sin, wp) && negligible(cosTerm, cos, wp) {
+			break
+		}
+		sin.Add(sin, term)
+		cos.Add(cos, cosTerm)
+	}
+
+	// Map back to the right quadrant
+	switch new(big.Int).Mod(kInt, big.NewInt(4)).Int64() {
+	case 1:
+		sin, cos = cos, sin.Neg(sin)
+	case 2:
+		sin, cos = sin.Neg(sin), cos.Neg(cos)
+	case 3:
+		sin, cos = cos.Neg(cos), sin
+	}
+
+	return sin.SetPrec(prec), cos.SetPrec(prec)
+}
+
+// roundFloat rounds x to the nearest integer
+func roundFloat(x *big.Float) *big.Int {
+	half := new(big.Float).SetPrec(x.Prec() + 1).SetFloat64(0.5)
+	if x.Sign() < 0 {
+		half.Neg(half)
+	}
+
+	res, _ := half.Add(half, x).Int(nil)
+	return res
+}
+
+// bitLen returns the number of bits needed to represent |n|
+func bitLen(n int) int {
+	if n < 0 {
+		n = -n
+	}
+
+	bits := 0
+	for ; n > 0; n >>= 1 {
+		bits++
+	}
+
+	return bits
+}
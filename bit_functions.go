@@ -0,0 +1,128 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math/big"
+)
+
+// DefaultBitWidth is the word size used by the bit manipulation functions when
+// no explicit width is given
+const DefaultBitWidth = 64
+
+// maxBitWidth limits bit widths and positions so a typo can't allocate huge
+// integers
+const maxBitWidth = 1 << 16
+
+var (
+	ErrInvalidBitWidth = errors.New("Invalid bit width")
+	ErrNegativeBitPos  = errors.New("Bit position can't be negative")
+)
+
+// mask returns 2**width - 1
+func mask(width uint) *big.Int {
+	m := new(big.Int).Lsh(big.NewInt(1), width)
+	return m.Sub(m, big.NewInt(1))
+}
+
+// Truncate wraps x into an unsigned integer of width bits, using two's
+// complement for negative numbers
+func Truncate(x *big.Int, width uint) *big.Int {
+	return new(big.Int).And(x, mask(width))
+}
+
+// PopCount returns the number of set bits in x when represented with width
+// bits
+func PopCount(x *big.Int, width uint) int {
+	x = Truncate(x, width)
+	count := 0
+	for i := 0; i < x.BitLen(); i++ {
+		count += int(x.Bit(i))
+	}
+
+	return count
+}
+
+// Parity returns 1 if x has an odd number of set bits in width bits, 0
+// otherwise
+func Parity(x *big.Int, width uint) int {
+	return PopCount(x, width) % 2
+}
+
+// LeadingZeros returns the number of leading zero bits of x in width bits
+func LeadingZeros(x *big.Int, width uint) int {
+	return int(width) - Truncate(x, width).BitLen()
+}
+
+// TrailingZeros returns the number of trailing zero bits of x in width bits.
+// If x is zero, width is returned.
+func TrailingZeros(x *big.Int, width uint) int {
+	x = Truncate(x, width)
+	if x.Sign() == 0 {
+		return int(width)
+	}
+
+	return int(x.TrailingZeroBits())
+}
+
+// RotateLeft rotates x left by n bits in width bits. A negative n rotates to
+// the right.
+func RotateLeft(x *big.Int, n int64, width uint) *big.Int {
+	x = Truncate(x, width)
+	n %= int64(width)
+	if n < 0 {
+		n += int64(width)
+	}
+
+	left := new(big.Int).Lsh(x, uint(n))
+	right := new(big.Int).Rsh(x, width-uint(n))
+
+	return Truncate(left.Or(left, right), width)
+}
+
+// RotateRight rotates x right by n bits in width bits. A negative n rotates to
+// the left.
+func RotateRight(x *big.Int, n int64, width uint) *big.Int {
+	return RotateLeft(x, -n, width)
+}
+
+// ReverseBits reverses the order of the bits of x in width bits
+func ReverseBits(x *big.Int, width uint) *big.Int {
+	x = Truncate(x, width)
+	res := new(big.Int)
+	for i := 0; i < x.BitLen(); i++ {
+		if x.Bit(i) == 1 {
+			res.SetBit(res, int(width)-1-i, 1)
+		}
+	}
+
+	return res
+}
+
+// ReverseBytes reverses the byte order of x in width bits, width being a
+// multiple of 8
+func ReverseBytes(x *big.Int, width uint) *big.Int {
+	x = Truncate(x, width)
+	res := new(big.Int)
+	byteMask := big.NewInt(0xff)
+	for i := uint(0); i < width/8; i++ {
+		b := new(big.Int).Rsh(x, i*8)
+		b.And(b, byteMask)
+		res.Or(res, b.Lsh(b, width-8-i*8))
+	}
+
+	return res
+}
+
+// BitField extracts the bits hi down to lo (inclusive) of x
+func BitField(x *big.Int, hi, lo uint) *big.Int {
+	if hi < lo {
+		hi, lo = lo, hi
+	}
+
+	res := new(big.Int).Rsh(x, lo)
+	return res.And(res, mask(hi-lo+1))
+}
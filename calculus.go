@@ -0,0 +1,422 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"sync"
+)
+
+// kronrod is the 15 point Gauss-Kronrod rule on [-1, 1], which embeds the 7
+// point Gauss rule. Nodes are symmetric, so only the node 0 and the positive
+// nodes are stored, in increasing order. gauss holds the Gauss weights, zero
+// for the nodes that are only in the Kronrod rule.
+type kronrod struct {
+	nodes, weights, gauss []*big.Float
+}
+
+var kronrodCache struct {
+	sync.Mutex
+	rule *kronrod
+}
+
+// kronrodRule returns the Gauss-Kronrod rule with at least prec bits
+func kronrodRule(prec uint) *kronrod {
+	kronrodCache.Lock()
+	defer kronrodCache.Unlock()
+
+	if rule := kronrodCache.rule; rule == nil || rule.nodes[1].Prec() < prec {
+		kronrodCache.rule = newKronrod(prec + guardBits)
+	}
+
+	return kronrodCache.rule
+}
+
+// newKronrod calculates the nodes and weights of the 15 point Gauss-Kronrod
+// rule. All calculations are done with rationals in y = x**2, as the nodes
+// are symmetric.
+func newKronrod(prec uint) *kronrod {
+	// Legendre polynomial P7 = (429x**7 - 693x**5 + 315x**3 - 35x) / 16. The
+	// Gauss nodes are its roots.
+	legendre := []*big.Rat{
+		new(big.Rat), big.NewRat(-35, 16), new(big.Rat), big.NewRat(315, 16),
+		new(big.Rat), big.NewRat(-693, 16), new(big.Rat), big.NewRat(429, 16),
+	}
+
+	// moment calculates the integral of P7(x) * x**k over [-1, 1]
+	moment := func(k int) *big.Rat {
+		sum := new(big.Rat)
+		for i, coef := range legendre {
+			if (i+k)%2 == 0 {
+				sum.Add(sum, new(big.Rat).Mul(coef, big.NewRat(2, int64(i+k+1))))
+			}
+		}
+		return sum
+	}
+
+	// The Kronrod nodes are the roots of the Stieltjes polynomial
+	// E8 = x**8 + a x**6 + b x**4 + c x**2 + d, which is orthogonal to
+	// P7 * x**k for k < 8. For even k that holds by symmetry.
+	m := NewMatrix(4, 4)
+	rhs := NewMatrix(4, 1)
+	for i, k := range []int{1, 3, 5, 7} {
+		for j := 0; j < 4; j++ {
+			m.At(i, j).Set(moment(k + 6 - 2*j))
+		}
+		rhs.Data[i].Neg(moment(k + 8))
+	}
+	sol, _ := m.Solve(rhs)
+	coeffs := sol.Particular.Data
+
+	// Both polynomials in y, with the coefficients in increasing order
+	stieltjes := []*big.Rat{coeffs[3], coeffs[2], coeffs[1], coeffs[0], big.NewRat(1, 1)}
+	gaussPoly := []*big.Rat{legendre[1], legendre[3], legendre[5], legendre[7]}
+
+	gaussY := polyRootsUnit(gaussPoly, prec)
+	kronrodY := polyRootsUnit(stieltjes, prec)
+
+	// The node 0 is in both rules
+	allY := append([]*big.Rat{new(big.Rat)}, kronrodY...)
+	allY = append(allY, gaussY...)
+	kronrodW := quadratureWeights(allY)
+	gaussW := quadratureWeights(append([]*big.Rat{new(big.Rat)}, gaussY...))
+
+	rule := &kronrod{}
+	for i, y := range allY {
+		x := new(big.Float).SetPrec(prec).SetRat(y)
+		rule.nodes = append(rule.nodes, x.Sqrt(x))
+		rule.weights = append(rule.weights, new(big.Float).SetPrec(prec).SetRat(kronrodW[i]))
+
+		gauss := new(big.Float).SetPrec(prec)
+		switch {
+		case i == 0:
+			gauss.SetRat(gaussW[0])
+		case i > len(kronrodY):
+			gauss.SetRat(gaussW[i-len(kronrodY)])
+		}
+		rule.gauss = append(rule.gauss, gauss)
+	}
+
+	return rule
+}
+
+// polyEval evaluates a polynomial with coefficients in increasing order
+func polyEval(coeffs []*big.Rat, x *big.Rat) *big.Rat {
+	res := new(big.Rat)
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		res.Mul(res, x)
+		res.Add(res, coeffs[i])
+	}
+
+	return res
+}
+
+// polyRootsUnit finds the simple roots of a polynomial in (0, 1) to prec bits
+// by bisection
+func polyRootsUnit(coeffs []*big.Rat, prec uint) []*big.Rat {
+	const samples = 1024
+
+	var roots []*big.Rat
+	lo := new(big.Rat)
+	flo := polyEval(coeffs, lo)
+	for i := 1; i <= samples; i++ {
+		hi := big.NewRat(int64(i), samples)
+		fhi := polyEval(coeffs, hi)
+		if flo.Sign()*fhi.Sign() < 0 {
+			a, b := new(big.Rat).Set(lo), new(big.Rat).Set(hi)
+			for j := uint(0); j < prec; j++ {
+				mid := new(big.Rat).Add(a, b)
+				mid.Quo(mid, big.NewRat(2, 1))
+				if polyEval(coeffs, mid).Sign() == flo.Sign() {
+					a = mid
+				} else {
+					b = mid
+				}
+			}
+			roots = append(roots, a)
+		}
+
+		lo, flo = hi, fhi
+	}
+
+	return roots
+}
+
+// quadratureWeights calculates the weights of a symmetric quadrature rule on
+// [-1, 1] with nodes 0 and the square roots of ys[1:], so that it integrates
+// even powers of x exactly
+func quadratureWeights(ys []*big.Rat) []*big.Rat {
+	n := len(ys)
+	m := NewMatrix(n, n)
+	rhs := NewMatrix(n, 1)
+	for j := 0; j < n; j++ {
+		// The integral of x**2j over [-1, 1]
+		rhs.Data[j].SetFrac64(2, int64(2*j+1))
+
+		pow := big.NewRat(1, 1)
+		for i := 0; i < n; i++ {
+			if i == 0 {
+				// The node 0 only counts for x**0
+				if j == 0 {
+					m.At(j, i).SetInt64(1)
+				}
+				continue
+			}
+
+			// Nodes other than 0 occur twice, at x and -x
+			m.At(j, i).Mul(pow.SetInt64(2), ratPow(ys[i], j))
+		}
+	}
+
+	sol, _ := m.Solve(rhs)
+	return sol.Particular.Data
+}
+
+func ratPow(x *big.Rat, n int) *big.Rat {
+	res := big.NewRat(1, 1)
+	for i := 0; i < n; i++ {
+		res.Mul(res, x)
+	}
+
+	return res
+}
+
+// segment is a part of the integration interval with the Kronrod estimate of
+// its integral and the error estimate
+type segment struct {
+	a, b, sum, err *big.Float
+}
+
+// quad calculates the integral of f over [a, b] with adaptive Gauss-Kronrod
+// quadrature. The segment with the largest error is split in halves until
+// the total error is below the tolerance.
+func (n *numeric) quad(f *realFunc, a, b *big.Float) (*big.Float, error) {
+	rule := kronrodRule(n.prec)
+
+	first, err := n.kronrod(rule, f, a, b)
+	if err != nil {
+		return nil, err
+	}
+	segments := []*segment{first}
+
+	// Splitting a segment should greatly decrease its error. If it doesn't,
+	// the error is dominated by rounding in f and can't be improved.
+	roundoff := 0
+	for i := 0; i < n.maxIter; i++ {
+		sum, total := n.float(), n.float()
+		worst := 0
+		for j, s := range segments {
+			sum.Add(sum, s.sum)
+			total.Add(total, s.err)
+			if s.err.Cmp(segments[worst].err) > 0 {
+				worst = j
+			}
+		}
+
+		tol := n.float().Mul(n.eps, n.abs(sum))
+		if tol.Cmp(n.tol) < 0 {
+			tol.Set(n.tol)
+		}
+		if total.Cmp(tol) <= 0 {
+			return sum, nil
+		}
+		if roundoff >= 10 {
+			// Rounding in f only costs a few of the guard bits, so a larger
+			// error means the integral diverges
+			if total.Cmp(tol.SetMantExp(tol, guardBits/2)) > 0 {
+				return nil, ErrNoConvergence
+			}
+			return sum, nil
+		}
+
+		s := segments[worst]
+		mid := n.float().Add(s.a, s.b)
+		mid.SetMantExp(mid, -1)
+
+		left, err := n.kronrod(rule, f, s.a, mid)
+		if err != nil {
+			return nil, err
+		}
+		right, err := n.kronrod(rule, f, mid, s.b)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.float().Add(left.err, right.err).Cmp(s.err) >= 0 {
+			roundoff++
+		}
+
+		segments[worst] = left
+		segments = append(segments, right)
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// kronrod applies the Gauss-Kronrod rule to f on [a, b]. The difference with
+// the embedded Gauss rule is used as error estimate.
+func (n *numeric) kronrod(rule *kronrod, f *realFunc, a, b *big.Float) (*segment, error) {
+	// Map [-1, 1] to [a, b]
+	center := n.float().Add(a, b)
+	center.SetMantExp(center, -1)
+	half := n.float().Sub(b, a)
+	half.SetMantExp(half, -1)
+
+	sum, gauss := n.float(), n.float()
+	for i, node := range rule.nodes {
+		var y *big.Float
+		if i == 0 {
+			fc, err := f.eval(center)
+			if err != nil {
+				return nil, err
+			}
+			y = fc
+		} else {
+			dx := n.float().Mul(half, node)
+			fl, err := f.eval(n.float().Sub(center, dx))
+			if err != nil {
+				return nil, err
+			}
+			fr, err := f.eval(n.float().Add(center, dx))
+			if err != nil {
+				return nil, err
+			}
+			y = fl.Add(fl, fr)
+		}
+
+		sum.Add(sum, n.float().Mul(rule.weights[i], y))
+		gauss.Add(gauss, n.float().Mul(rule.gauss[i], y))
+	}
+
+	sum.Mul(sum, half)
+	gauss.Mul(gauss, half)
+
+	return &segment{a: a, b: b, sum: sum, err: n.abs(gauss.Sub(sum, gauss))}, nil
+}
+
+// integrate calculates the integral of an expression in a variable between
+// two bounds, like integrate(x**2, x, 0, 1)
+func integrate(p *Parser, args []*Expr) (Value, error) {
+	n := newNumeric(p)
+	f, bounds, err := funcArgs(n, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if bounds[0].Cmp(bounds[1]) == 0 {
+		return new(big.Rat), nil
+	}
+
+	res, err := n.quad(f, bounds[0], bounds[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return floatToRat(res.SetPrec(floatPrec(p)))
+}
+
+// derive calculates the derivative of f at x with Ridders' method, which
+// extrapolates central differences with shrinking steps to step size zero
+func (n *numeric) derive(f *realFunc, x *big.Float) (*big.Float, error) {
+	const shrink = 1.4
+
+	var (
+		con  = n.float().SetFloat64(shrink)
+		con2 = n.float().SetFloat64(shrink * shrink)
+		two  = newFloat(n.prec, 2)
+		rows = 10 + int(n.prec)/8
+	)
+
+	// Start with a step relative to the size of x
+	h := n.abs(x)
+	h.Add(h, newFloat(n.prec, 1))
+	h.SetMantExp(h, -3)
+
+	central := func(h *big.Float) (*big.Float, error) {
+		fr, err := f.eval(n.float().Add(x, h))
+		if err != nil {
+			return nil, err
+		}
+		fl, err := f.eval(n.float().Sub(x, h))
+		if err != nil {
+			return nil, err
+		}
+
+		d := fr.Sub(fr, fl)
+		return d.Quo(d, n.float().Mul(two, h)), nil
+	}
+
+	first, err := central(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res     = first
+		resErr  *big.Float
+		prevRow = []*big.Float{first}
+	)
+	for i := 1; i < rows; i++ {
+		h.Quo(h, con)
+		d, err := central(h)
+		if err != nil {
+			return nil, err
+		}
+
+		// Extrapolate to higher orders
+		row := []*big.Float{d}
+		fac := n.float().Set(con2)
+		for j := 1; j <= i; j++ {
+			est := n.float().Mul(row[j-1], fac)
+			est.Sub(est, prevRow[j-1])
+			est.Quo(est, n.float().Sub(fac, newFloat(n.prec, 1)))
+			row = append(row, est)
+			fac.Mul(fac, con2)
+
+			errEst := n.abs(n.float().Sub(est, row[j-1]))
+			if other := n.abs(n.float().Sub(est, prevRow[j-1])); other.Cmp(errEst) > 0 {
+				errEst = other
+			}
+			if resErr == nil || errEst.Cmp(resErr) <= 0 {
+				res, resErr = est, errEst
+			}
+		}
+
+		// Stop when the result is accurate enough, or when the higher orders
+		// get worse due to rounding
+		tol := n.float().Mul(n.eps, n.abs(res))
+		if tol.Cmp(n.tol) < 0 {
+			tol.Set(n.tol)
+		}
+		if resErr.Cmp(tol) <= 0 {
+			break
+		}
+		diverging := n.abs(n.float().Sub(row[i], prevRow[i-1]))
+		if diverging.Cmp(n.float().Mul(two, resErr)) >= 0 {
+			break
+		}
+
+		prevRow = row
+	}
+
+	return res, nil
+}
+
+// nderiv calculates the derivative of an expression in a variable at a point,
+// like nderiv(x**2, x, 3)
+func nderiv(p *Parser, args []*Expr) (Value, error) {
+	n := newNumeric(p)
+	f, at, err := funcArgs(n, args)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := n.derive(f, at[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return floatToRat(res.SetPrec(floatPrec(p)))
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestIntegrate(t *testing.T) {
+	calls := map[string]string{
+		"integrate(x**2, x, 0, 1)":                    "0.333333333333333",
+		"integrate(x**2, x, 1, 0)":                    "-0.333333333333333",
+		"integrate(x**10, x, 0, 2)":                   "186.181818181818182",
+		"integrate(sin(x), x, 0, pi)":                 "2.000000000000000",
+		"integrate(1/x, x, 1, e)":                     "1.000000000000000",
+		"integrate(sqrt(x), x, 0, 1)":                 "0.666666666666667",
+		"integrate(normpdf(x), x, -10, 10)":           "1.000000000000000",
+		"integrate(x > 1, x, 0, 2)":                   "1.000000000000000",
+		"integrate(x, x, 3, 3)":                       "0.000000000000000",
+		"integrate(integrate(x*y, x, 0, 1), y, 0, 2)": "1.000000000000000",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error integrating '%s': %s", expr, err)
+			continue
+		}
+
+		if res.FloatString(15) != expected {
+			t.Errorf("wrong integral '%s' (expected %s, got %s)", expr, expected, res.FloatString(15))
+		}
+	}
+
+	badCalls := []string{
+		"integrate(1/x, x, -1, 1)", "integrate(x, 2, 0, 1)", "integrate(x, x, 0)",
+		"integrate(y, x, 0, 1)",
+	}
+
+	for _, expr := range badCalls {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("expected error on bad integral '%s'", expr)
+		}
+	}
+
+	// Divergent integrals
+	for _, expr := range []string{"integrate(1/x, x, 0, 1)", "integrate(1/x**2, x, -1, 0)"} {
+		if res, err := Eval(expr); err != ErrNoConvergence {
+			t.Errorf("expected no convergence on divergent integral '%s', got %v, %v", expr, res, err)
+		}
+	}
+}
+
+func TestNderiv(t *testing.T) {
+	calls := map[string]string{
+		"nderiv(x**3, x, 2)":       "12.000000000000000",
+		"nderiv(sin(x), x, 0)":     "1.000000000000000",
+		"nderiv(sqrt(x), x, 4)":    "0.250000000000000",
+		"nderiv(1/x, x, -2)":       "-0.250000000000000",
+		"nderiv(x**4 - x, x, 0.5)": "-0.500000000000000",
+		"nderiv(x**2, x, 10**10)":  "20000000000.000000000000000",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error differentiating '%s': %s", expr, err)
+			continue
+		}
+
+		if res.FloatString(15) != expected {
+			t.Errorf("wrong derivative '%s' (expected %s, got %s)", expr, expected, res.FloatString(15))
+		}
+	}
+
+	badCalls := []string{"nderiv(sqrt(x), x, 0)", "nderiv(x, 1, 0)", "nderiv(x, x)"}
+
+	for _, expr := range badCalls {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("expected error on bad derivative '%s'", expr)
+		}
+	}
+}
+
+func TestKronrodRule(t *testing.T) {
+	// The rule has to integrate polynomials up to degree 22 exactly, and the
+	// embedded Gauss rule up to degree 13
+	rule := kronrodRule(128)
+	for _, degree := range []int{0, 2, 12, 22} {
+		sum, gauss := newFloat(128, 0), newFloat(128, 0)
+		for i, node := range rule.nodes {
+			pow := newFloat(128, 1)
+			for j := 0; j < degree; j++ {
+				pow.Mul(pow, node)
+			}
+			if i > 0 {
+				pow.Add(pow, pow)
+			}
+
+			sum.Add(sum, new(big.Float).Mul(rule.weights[i], pow))
+			if degree <= 12 {
+				gauss.Add(gauss, new(big.Float).Mul(rule.gauss[i], pow))
+			}
+		}
+
+		expected := new(big.Float).SetPrec(128).SetRat(big.NewRat(2, int64(degree+1)))
+		if sum.Text('g', 30) != expected.Text('g', 30) {
+			t.Errorf("Kronrod rule wrong for degree %d: %s", degree, sum.Text('g', 30))
+		}
+		if degree <= 12 && gauss.Text('g', 30) != expected.Text('g', 30) {
+			t.Errorf("Gauss rule wrong for degree %d: %s", degree, gauss.Text('g', 30))
+		}
+	}
+}
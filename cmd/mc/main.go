@@ -7,19 +7,43 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"runtime"
+	"strings"
 
 	"github.com/chzyer/readline"
 	"github.com/soudy/mathcat"
 )
 
 var (
-	precision   = flag.Uint("precision", 64, "bits of precision used in decimal float results")
-	literalMode = flag.String("mode", "decimal", "type of literal used as result. can be decimal (default), hex, binary or octal")
+	precision   = flag.Uint("precision", 64, "bits of precision used in decimal float results and special functions")
+	literalMode = flag.String("mode", "decimal", "type of literal used as result. can be decimal (default), hex, binary, octal or float")
+	angleUnit   = flag.String("angle", "rad", "unit of angles used by trigonometric functions. can be rad (default), deg or grad")
+	numbers     = flag.String("numbers", "exact", "kind of numbers to calculate with. can be exact (default), bigfloat or float64")
+	rounding    = flag.String("rounding", "ToNearestEven", "rounding mode of bigfloat numbers. can be ToNearestEven (default), ToNearestAway, ToZero, AwayFromZero, ToNegativeInf or ToPositiveInf")
+	csv         = flag.Bool("csv", false, "print matrix results as comma separated values, like tables of odetable")
+	genFunc     = flag.String("gen", "", "generate a Go function with this name evaluating the expression given as arguments, for go generate")
+	genPackage  = flag.String("package", "main", "package of generated Go code")
+	genType     = flag.String("type", "rat", "number type of generated Go code. can be rat (default) or float64")
+	output      = flag.String("o", "", "file to write generated Go code to instead of stdout")
 )
 
+var roundingModes = map[string]big.RoundingMode{
+	"ToNearestEven": big.ToNearestEven,
+	"ToNearestAway": big.ToNearestAway,
+	"ToZero":        big.ToZero,
+	"AwayFromZero":  big.AwayFromZero,
+	"ToNegativeInf": big.ToNegativeInf,
+	"ToPositiveInf": big.ToPositiveInf,
+}
+
+var goTypes = map[string]mathcat.GoType{
+	"rat":     mathcat.GoRat,
+	"float64": mathcat.GoFloat64,
+}
+
 func getHomeDir() string {
 	if runtime.GOOS == "windows" {
 		home := os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
@@ -33,8 +57,7 @@ func getHomeDir() string {
 	return os.Getenv("HOME")
 }
 
-func repl(mode Mode) {
-	p := mathcat.New()
+func repl(mode Mode, p *mathcat.Parser) {
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:      "mc> ",
 		HistoryFile: getHomeDir() + "/.mathcat_history",
@@ -52,35 +75,180 @@ func repl(mode Mode) {
 			break
 		}
 
-		res, err := p.Run(line)
+		if res, ok, err := runCommand(p, line); ok {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+			} else {
+				fmt.Println(res)
+			}
+			continue
+		}
+
+		val, err := p.RunValue(line)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			continue
 		}
 
-		switch mode {
-		case Decimal:
-			if res.IsInt() {
-				fmt.Println(res.Num())
+		if m, ok := val.(*mathcat.Matrix); ok {
+			if *csv {
+				printCSV(m, mode)
 			} else {
-				stringResult := new(big.Float).
-					SetPrec(*precision).
-					SetRat(res).
-					Text('f', -1)
-				fmt.Println(stringResult)
+				printMatrix(m, mode)
 			}
+			continue
+		}
+
+		res := val.(*big.Rat)
+		switch mode {
+		case Decimal:
+			fmt.Println(formatDecimal(res))
+		case Float:
+			fmt.Println(formatDecimal(res))
+			printFloat(res, mathcat.Float32)
+			printFloat(res, mathcat.Float64)
 		case Hex, Binary, Octal:
-			formats := map[Mode]string{
-				Hex:    "%#x",
-				Binary: "%b",
-				Octal:  "%#o",
+			fmt.Println(formatInteger(res, mode))
+		}
+	}
+}
+
+// runCommand prints the expression of simplify(expr) instead of evaluating it.
+// expand(expr) and pfactor(expr) are printed too if expr has undefined
+// variables, and evaluated like other functions otherwise.
+func runCommand(p *mathcat.Parser, line string) (mathcat.Node, bool, error) {
+	name, expr, ok := commandArg(line)
+	if !ok {
+		return nil, false, nil
+	}
+
+	switch {
+	case name == "simplify":
+		return mathcat.SimplifyNode(expr), true, nil
+	case !hasUndefined(p, expr):
+		return nil, false, nil
+	case name == "expand":
+		return mathcat.ExpandNode(expr), true, nil
+	}
+
+	res, err := mathcat.FactorNode(expr)
+	return res, true, err
+}
+
+// commandArg returns the name and expression of a command like simplify(expr)
+func commandArg(line string) (string, mathcat.Node, bool) {
+	tree, err := mathcat.Parse(line)
+	if err != nil {
+		return "", nil, false
+	}
+
+	call, ok := tree.(*mathcat.CallNode)
+	if !ok || len(call.Args) != 1 {
+		return "", nil, false
+	}
+
+	switch name := call.Func.Value; name {
+	case "simplify", "expand", "pfactor":
+		return name, call.Args[0], true
+	}
+
+	return "", nil, false
+}
+
+// hasUndefined checks if an expression uses variables the parser doesn't have
+func hasUndefined(p *mathcat.Parser, expr mathcat.Node) bool {
+	undefined := false
+	mathcat.Inspect(expr, func(n mathcat.Node) bool {
+		if ident, ok := n.(*mathcat.IdentNode); ok {
+			_, isVar := p.Variables[ident.Name()]
+			_, isMatrix := p.Matrices[ident.Name()]
+			undefined = !isVar && !isMatrix
+		}
+		return !undefined
+	})
+
+	return undefined
+}
+
+func formatDecimal(res *big.Rat) string {
+	if res.IsInt() {
+		return res.Num().String()
+	}
+
+	return new(big.Float).
+		SetPrec(*precision).
+		SetRat(res).
+		Text('f', -1)
+}
+
+func formatInteger(res *big.Rat, mode Mode) string {
+	formats := map[Mode]string{
+		Hex:    "%#x",
+		Binary: "%b",
+		Octal:  "%#o",
+	}
+	integer := mathcat.RationalToInteger(res)
+	return fmt.Sprintf(formats[mode], integer)
+}
+
+// formatCells formats the elements of a matrix in the given mode
+func formatCells(m *mathcat.Matrix, mode Mode) []string {
+	cells := make([]string, len(m.Data))
+	for i, x := range m.Data {
+		if mode == Hex || mode == Binary || mode == Octal {
+			cells[i] = formatInteger(x, mode)
+		} else {
+			cells[i] = formatDecimal(x)
+		}
+	}
+
+	return cells
+}
+
+// printMatrix prints a matrix one row per line, with the columns aligned
+func printMatrix(m *mathcat.Matrix, mode Mode) {
+	cells := formatCells(m, mode)
+	widths := make([]int, m.Cols)
+	for i := range cells {
+		if col := i % m.Cols; len(cells[i]) > widths[col] {
+			widths[col] = len(cells[i])
+		}
+	}
+
+	for i := 0; i < m.Rows; i++ {
+		fmt.Print("[")
+		for j := 0; j < m.Cols; j++ {
+			if j > 0 {
+				fmt.Print("  ")
 			}
-			integer := mathcat.RationalToInteger(res)
-			fmt.Printf(formats[mode]+"\n", integer)
+			fmt.Printf("%*s", widths[j], cells[i*m.Cols+j])
 		}
+		fmt.Println("]")
 	}
 }
 
+// printCSV prints a matrix one row per line, with commas between the columns
+func printCSV(m *mathcat.Matrix, mode Mode) {
+	cells := formatCells(m, mode)
+	for i := 0; i < m.Rows; i++ {
+		fmt.Println(strings.Join(cells[i*m.Cols:(i+1)*m.Cols], ","))
+	}
+}
+
+// printFloat prints the IEEE-754 encoding of a result in the given format,
+// broken down into its sign, exponent and mantissa
+func printFloat(res *big.Rat, format mathcat.FloatFormat) {
+	bits, exact := format.Encode(res)
+	sign, _, mant := format.Fields(bits)
+
+	fmt.Printf("%s: 0x%0*x (sign %d, exponent %d, mantissa %#x)",
+		format.Name, format.Bits()/4, bits, sign, format.Exponent(bits), mant)
+	if !exact {
+		fmt.Print(" inexact")
+	}
+	fmt.Println()
+}
+
 func main() {
 	var mode Mode
 	var ok bool
@@ -92,5 +260,65 @@ func main() {
 		os.Exit(-1)
 	}
 
-	repl(mode)
+	unit, err := mathcat.ParseAngleUnit(*angleUnit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(-1)
+	}
+
+	if *genFunc != "" {
+		if err := generate(unit); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		return
+	}
+
+	p := mathcat.New()
+	p.Precision = *precision
+	p.AngleUnit = unit
+	if p.Mode, err = mathcat.ParseNumberMode(*numbers); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(-1)
+	}
+	if p.RoundingMode, ok = roundingModes[*rounding]; !ok {
+		fmt.Fprintf(os.Stderr, "Invalid rounding mode ‘%s’\n", *rounding)
+		os.Exit(-1)
+	}
+
+	repl(mode, p)
+}
+
+// generate writes a Go function evaluating the expression given as arguments,
+// or read from stdin
+func generate(unit mathcat.AngleUnit) error {
+	typ, ok := goTypes[*genType]
+	if !ok {
+		return fmt.Errorf("Invalid Go type ‘%s’", *genType)
+	}
+
+	expr := strings.Join(flag.Args(), " ")
+	if expr == "" {
+		in, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		expr = strings.TrimSpace(string(in))
+	}
+
+	src, err := mathcat.GenerateGo(expr, mathcat.GoOptions{
+		Package:   *genPackage,
+		Func:      *genFunc,
+		Type:      typ,
+		AngleUnit: unit,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(*output, src, 0644)
 }
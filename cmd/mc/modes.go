@@ -11,6 +11,7 @@ const (
 	Hex
 	Binary
 	Octal
+	Float
 )
 
 var modes = map[string]Mode{
@@ -18,4 +19,5 @@ var modes = map[string]Mode{
 	"hex":     Hex,
 	"binary":  Binary,
 	"octal":   Octal,
+	"float":   Float,
 }
@@ -0,0 +1,391 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+const (
+	// maxResultBits limits the estimated size of combinatorial results, so
+	// something like ncr(10**9, 5*10**8) errors out instead of hanging
+	maxResultBits = 1 << 20
+	// maxSteps limits the number of big integer operations done by the
+	// recurrences for Stirling, Bell and partition numbers
+	maxSteps = 1 << 22
+	// maxWords limits the number of machine words processed by those
+	// recurrences, as their numbers grow large
+	maxWords = 1 << 28
+)
+
+var (
+	ErrResultTooLarge = errors.New("Result too large")
+	ErrNegativeInput  = errors.New("Expecting a non-negative integer")
+)
+
+// checkBits errors if a result estimated at log2 bits is too large
+func checkBits(log2 float64) error {
+	if log2 > maxResultBits || math.IsNaN(log2) || math.IsInf(log2, 0) {
+		return ErrResultTooLarge
+	}
+
+	return nil
+}
+
+// checkSteps errors if a recurrence takes more than maxSteps steps
+func checkSteps(steps float64) error {
+	if steps > maxSteps {
+		return ErrResultTooLarge
+	}
+
+	return nil
+}
+
+// checkWords errors if a recurrence processes more than maxWords words
+func checkWords(words float64) error {
+	if words > maxWords {
+		return ErrResultTooLarge
+	}
+
+	return nil
+}
+
+func intFloat(n *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
+
+// Binomial calculates the binomial coefficient n choose k. Negative n are
+// supported through the identity C(n, k) = (-1)**k * C(k - n - 1, k).
+func Binomial(n, k *big.Int) (*big.Int, error) {
+	if k.Sign() < 0 {
+		return new(big.Int), nil
+	}
+
+	if n.Sign() < 0 {
+		m := new(big.Int).Sub(k, n)
+		res, err := Binomial(m.Sub(m, bigOne), k)
+		if err != nil {
+			return nil, err
+		}
+		if k.Bit(0) == 1 {
+			res.Neg(res)
+		}
+		return res, nil
+	}
+
+	if k.Cmp(n) > 0 {
+		return new(big.Int), nil
+	}
+
+	// Use the symmetry C(n, k) = C(n, n - k) to do less multiplications
+	if nk := new(big.Int).Sub(n, k); nk.Cmp(k) < 0 {
+		k = nk
+	}
+
+	// C(n, k) <= (e * n / k)**k
+	if fk := intFloat(k); fk > 0 {
+		if err := checkBits(fk * math.Log2(math.E*intFloat(n)/fk)); err != nil {
+			return nil, err
+		}
+	}
+
+	res := big.NewInt(1)
+	factor := new(big.Int).Sub(n, k)
+	for i := int64(1); i <= k.Int64(); i++ {
+		// res = res * (n - k + i) / i, which is always exact
+		factor.Add(factor, bigOne)
+		res.Mul(res, factor)
+		res.Quo(res, big.NewInt(i))
+	}
+
+	return res, nil
+}
+
+// Permutations calculates the number of ordered arrangements of k out of n
+// items, n! / (n - k)!
+func Permutations(n, k *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 || k.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	if k.Cmp(n) > 0 {
+		return new(big.Int), nil
+	}
+
+	return FallingFactorial(n, k)
+}
+
+// Multinomial calculates the multinomial coefficient (k1 + k2 + ...)! /
+// (k1! * k2! * ...)
+func Multinomial(ks ...*big.Int) (*big.Int, error) {
+	sum := new(big.Int)
+	res := big.NewInt(1)
+
+	// Build the result as a product of binomial coefficients
+	for _, k := range ks {
+		if k.Sign() < 0 {
+			return nil, ErrNegativeInput
+		}
+
+		sum.Add(sum, k)
+		binom, err := Binomial(sum, k)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Mul(res, binom)
+		if err := checkBits(float64(res.BitLen())); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// Catalan calculates the n-th Catalan number, C(2n, n) / (n + 1)
+func Catalan(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	binom, err := Binomial(new(big.Int).Lsh(n, 1), n)
+	if err != nil {
+		return nil, err
+	}
+
+	return binom.Quo(binom, new(big.Int).Add(n, bigOne)), nil
+}
+
+// fibPair calculates F(n) and F(n + 1) using fast doubling
+func fibPair(n *big.Int) (*big.Int, *big.Int) {
+	a, b := big.NewInt(0), big.NewInt(1)
+
+	for i := n.BitLen() - 1; i >= 0; i-- {
+		// F(2k) = F(k) * (2F(k+1) - F(k))
+		// F(2k+1) = F(k)**2 + F(k+1)**2
+		c := new(big.Int).Lsh(b, 1)
+		c.Sub(c, a)
+		c.Mul(c, a)
+		d := new(big.Int).Mul(a, a)
+		d.Add(d, new(big.Int).Mul(b, b))
+
+		if n.Bit(i) == 0 {
+			a, b = c, d
+		} else {
+			a, b = d, c.Add(c, d)
+		}
+	}
+
+	return a, b
+}
+
+// Fibonacci calculates the n-th Fibonacci number. Negative n use
+// F(-n) = (-1)**(n+1) * F(n).
+func Fibonacci(n *big.Int) (*big.Int, error) {
+	abs := new(big.Int).Abs(n)
+	if err := checkBits(intFloat(abs) * math.Log2(math.Phi)); err != nil {
+		return nil, err
+	}
+
+	res, _ := fibPair(abs)
+	if n.Sign() < 0 && abs.Bit(0) == 0 {
+		res.Neg(res)
+	}
+
+	return res, nil
+}
+
+// Lucas calculates the n-th Lucas number. Negative n use
+// L(-n) = (-1)**n * L(n).
+func Lucas(n *big.Int) (*big.Int, error) {
+	abs := new(big.Int).Abs(n)
+	if err := checkBits(intFloat(abs) * math.Log2(math.Phi)); err != nil {
+		return nil, err
+	}
+
+	// L(n) = 2F(n+1) - F(n)
+	f, f1 := fibPair(abs)
+	res := f1.Lsh(f1, 1)
+	res.Sub(res, f)
+	if n.Sign() < 0 && abs.Bit(0) == 1 {
+		res.Neg(res)
+	}
+
+	return res, nil
+}
+
+// Stirling1 calculates the signed Stirling number of the first kind s(n, k)
+func Stirling1(n, k *big.Int) (*big.Int, error) {
+	return stirling(n, k, true)
+}
+
+// Stirling2 calculates the Stirling number of the second kind S(n, k), the
+// number of ways to partition n items into k non-empty subsets
+func Stirling2(n, k *big.Int) (*big.Int, error) {
+	return stirling(n, k, false)
+}
+
+func stirling(n, k *big.Int, first bool) (*big.Int, error) {
+	if n.Sign() < 0 || k.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	if k.Cmp(n) > 0 {
+		return new(big.Int), nil
+	}
+
+	if err := checkSteps(intFloat(n) * (intFloat(k) + 1)); err != nil {
+		return nil, err
+	}
+
+	// The numbers in the rows are bounded by |s(n, k)| <= n! and
+	// S(n, k) <= k**n, and each step works on numbers that large
+	fn, fk := intFloat(n), intFloat(k)
+	bits := fn * math.Log2(math.Max(fk, 1))
+	if first {
+		lg, _ := math.Lgamma(fn + 1)
+		bits = lg / math.Ln2
+	}
+	if err := checkBits(bits); err != nil {
+		return nil, err
+	}
+	if err := checkWords(fn * fk * bits / 64); err != nil {
+		return nil, err
+	}
+
+	nn, kk := int(n.Int64()), int(k.Int64())
+
+	// row holds s(i, 0..k) and is updated in place from right to left
+	row := make([]*big.Int, kk+1)
+	for j := range row {
+		row[j] = new(big.Int)
+	}
+	row[0].SetInt64(1)
+
+	tmp := new(big.Int)
+	for i := 1; i <= nn; i++ {
+		for j := kk; j >= 1; j-- {
+			if first {
+				// s(i, j) = s(i-1, j-1) - (i-1) * s(i-1, j)
+				tmp.Mul(row[j], big.NewInt(int64(i-1)))
+				row[j].Sub(row[j-1], tmp)
+			} else {
+				// S(i, j) = S(i-1, j-1) + j * S(i-1, j)
+				tmp.Mul(row[j], big.NewInt(int64(j)))
+				row[j].Add(row[j-1], tmp)
+			}
+		}
+		row[0].SetInt64(0)
+	}
+
+	return row[kk], nil
+}
+
+// Bell calculates the n-th Bell number, the number of partitions of a set with
+// n items
+func Bell(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	fn := intFloat(n)
+	if err := checkSteps(fn * fn / 2); err != nil {
+		return nil, err
+	}
+
+	// Bell triangle, every row starts with the last number of the previous row
+	row := []*big.Int{big.NewInt(1)}
+	for i := int64(0); i < n.Int64(); i++ {
+		next := make([]*big.Int, len(row)+1)
+		next[0] = row[len(row)-1]
+		for j := range row {
+			next[j+1] = new(big.Int).Add(next[j], row[j])
+		}
+		row = next
+	}
+
+	return row[0], nil
+}
+
+// Partitions calculates the number of ways to write n as a sum of positive
+// integers, using Euler's pentagonal number theorem
+func Partitions(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return new(big.Int), nil
+	}
+
+	fn := intFloat(n)
+	if err := checkSteps(fn * math.Sqrt(fn)); err != nil {
+		return nil, err
+	}
+
+	nn := int(n.Int64())
+	p := make([]*big.Int, nn+1)
+	p[0] = big.NewInt(1)
+
+	for i := 1; i <= nn; i++ {
+		p[i] = new(big.Int)
+		for k := 1; ; k++ {
+			// Generalized pentagonal numbers k(3k-1)/2 and k(3k+1)/2
+			g1 := k * (3*k - 1) / 2
+			if g1 > i {
+				break
+			}
+
+			if k%2 == 1 {
+				p[i].Add(p[i], p[i-g1])
+			} else {
+				p[i].Sub(p[i], p[i-g1])
+			}
+
+			if g2 := k * (3*k + 1) / 2; g2 <= i {
+				if k%2 == 1 {
+					p[i].Add(p[i], p[i-g2])
+				} else {
+					p[i].Sub(p[i], p[i-g2])
+				}
+			}
+		}
+	}
+
+	return p[nn], nil
+}
+
+// RisingFactorial calculates x * (x + 1) * ... * (x + n - 1)
+func RisingFactorial(x, n *big.Int) (*big.Int, error) {
+	return factorialPower(x, n, 1)
+}
+
+// FallingFactorial calculates x * (x - 1) * ... * (x - n + 1)
+func FallingFactorial(x, n *big.Int) (*big.Int, error) {
+	return factorialPower(x, n, -1)
+}
+
+func factorialPower(x, n *big.Int, step int64) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	fx, fn := math.Abs(intFloat(x)), intFloat(n)
+	if err := checkBits(fn * math.Log2(fx+fn+1)); err != nil {
+		return nil, err
+	}
+	if err := checkSteps(fn); err != nil {
+		return nil, err
+	}
+
+	res := big.NewInt(1)
+	factor := new(big.Int).Set(x)
+	bigStep := big.NewInt(step)
+	for i := int64(0); i < n.Int64(); i++ {
+		res.Mul(res, factor)
+		factor.Add(factor, bigStep)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,393 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Diff differentiates an expression with respect to variable x, returning the
+// simplified derivative. Trigonometric functions take angles in radians.
+//
+// Example:
+//     deriv, err := mathcat.Diff("x**2 * sin(x)", "x")
+//     fmt.Println(deriv) // x**2*cos(x) + 2*x*sin(x)
+func Diff(expr, x string) (Node, error) {
+	tree, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffNode(tree, x)
+}
+
+// DiffNode differentiates a parsed expression with respect to variable x,
+// returning the simplified derivative like Diff
+func DiffNode(n Node, x string) (Node, error) {
+	d := &differ{x: x, unit: Radians}
+	deriv, err := d.diff(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return SimplifyNode(deriv), nil
+}
+
+// differ differentiates expressions with respect to variable x, with angles
+// of trigonometric functions in unit
+type differ struct {
+	x    string
+	unit AngleUnit
+}
+
+// dependsOn checks if n contains variable x
+func dependsOn(n Node, x string) bool {
+	found := false
+	Inspect(n, func(n Node) bool {
+		if ident, ok := n.(*IdentNode); ok && ident.Name() == x {
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
+func (d *differ) diff(n Node) (Node, error) {
+	if _, ok := n.(*MatrixNode); !ok && !dependsOn(n, d.x) {
+		return intNode(0, n.Pos()), nil
+	}
+
+	switch n := n.(type) {
+	case *IdentNode:
+		return intNode(1, n.Pos()), nil
+	case *UnaryNode:
+		if !n.Op.Is(UnaryMin) {
+			break
+		}
+		dx, err := d.diff(n.X)
+		if err != nil {
+			return nil, err
+		}
+		return neg(dx), nil
+	case *BinaryNode:
+		return d.binary(n)
+	case *CallNode:
+		return d.call(n)
+	case *MatrixNode:
+		res := &MatrixNode{Lbracket: n.Lbracket, Elems: make([]Node, len(n.Elems)), Breaks: n.Breaks}
+		for i, elem := range n.Elems {
+			delem, err := d.diff(elem)
+			if err != nil {
+				return nil, err
+			}
+			res.Elems[i] = delem
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+}
+
+func (d *differ) binary(n *BinaryNode) (Node, error) {
+	switch n.Op.Type {
+	case Add, Sub, Mul, Div, Pow, Rem:
+	case EqEq, NotEq, Gt, GtEq, Lt, LtEq:
+		// Comparisons are constant except where they jump
+		return intNode(0, n.Pos()), nil
+	default:
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", n.Op)
+	}
+
+	u, v := n.Lhs, n.Rhs
+	du, err := d.diff(u)
+	if err != nil {
+		return nil, err
+	}
+	dv, err := d.diff(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op.Type {
+	case Add:
+		return add(du, dv), nil
+	case Sub:
+		return sub(du, dv), nil
+	case Mul:
+		return add(mul(du, v), mul(u, dv)), nil
+	case Div:
+		if !dependsOn(v, d.x) {
+			return div(du, v), nil
+		}
+		return div(sub(mul(du, v), mul(u, dv)), pow(v, intNode(2, v.Pos()))), nil
+	case Rem:
+		// u % v = u - v*floor(u/v), with floor(u/v) constant between jumps
+		return sub(du, mul(dv, callNode("floor", n.Pos(), div(u, v)))), nil
+	}
+
+	// Pow
+	switch {
+	case !dependsOn(v, d.x):
+		return mul(mul(v, du), pow(u, sub(v, intNode(1, v.Pos())))), nil
+	case !dependsOn(u, d.x):
+		if ident, ok := u.(*IdentNode); ok && ident.Name() == "e" {
+			return mul(dv, n), nil
+		}
+		return mul(mul(dv, n), callNode("ln", u.Pos(), u)), nil
+	}
+
+	// u**v = e**(v*ln(u))
+	ln := callNode("ln", u.Pos(), u)
+	return mul(n, add(mul(dv, ln), div(mul(v, du), u))), nil
+}
+
+// angleFactor returns the factor the derivative of trigonometric functions
+// gets in the angle unit, or nil for radians. The inverse functions get the
+// reciprocal.
+func (d *differ) angleFactor(pos int, inverse bool) Node {
+	var turn int64
+	switch d.unit {
+	case Degrees:
+		turn = 180
+	case Gradians:
+		turn = 200
+	default:
+		return nil
+	}
+
+	pi, half := identNode("pi", pos), intNode(turn, pos)
+	if inverse {
+		return binary(Div, half, pi)
+	}
+	return binary(Div, pi, half)
+}
+
+// continuousDists are the distributions of which the cdf is differentiable
+var continuousDists = []string{"norm", "t", "chi2", "f", "exp", "unif"}
+
+// call differentiates a function call using the chain rule
+func (d *differ) call(n *CallNode) (Node, error) {
+	name, pos := n.Func.Value, n.Pos()
+	if _, ok := funcs[name]; !ok {
+		return nil, fmt.Errorf("Undefined function ‘%s’", n.Func)
+	}
+
+	var args, dargs []Node
+	for _, arg := range n.Args {
+		darg, err := d.diff(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		dargs = append(dargs, darg)
+	}
+	if len(args) == 0 {
+		return intNode(0, pos), nil
+	}
+
+	call := func(name string, args ...Node) Node {
+		return callNode(name, pos, args...)
+	}
+	num := func(x int64) Node {
+		return intNode(x, pos)
+	}
+	u, du := args[0], dargs[0]
+	sq := pow(u, num(2))
+
+	// Functions of two arguments
+	switch name {
+	case "atan2":
+		y, x, dy, dx := args[0], args[1], dargs[0], dargs[1]
+		res := div(sub(mul(x, dy), mul(y, dx)), add(pow(x, num(2)), pow(y, num(2))))
+		return d.scale(res, true), nil
+	case "hypot":
+		a, b, da, db := args[0], args[1], dargs[0], dargs[1]
+		return div(add(mul(a, da), mul(b, db)), n), nil
+	case "beta":
+		a, b, da, db := args[0], args[1], dargs[0], dargs[1]
+		ab := call("digamma", add(a, b))
+		da = mul(da, sub(call("digamma", a), ab))
+		db = mul(db, sub(call("digamma", b), ab))
+		return mul(add(da, db), n), nil
+	case "logn":
+		return d.diff(div(call("ln", args[1]), call("ln", args[0])))
+	case "max", "min":
+		// The derivative of the argument that's taken
+		a, b, da, db := args[0], args[1], dargs[0], dargs[1]
+		op := GtEq
+		if name == "min" {
+			op = LtEq
+		}
+		first := binary(op, a, b)
+		return add(mul(first, da), mul(sub(num(1), first), db)), nil
+	}
+
+	for _, dist := range continuousDists {
+		if name != dist+"cdf" && name != dist+"pdf" {
+			continue
+		}
+		for _, darg := range dargs[1:] {
+			if !isNum(darg, 0) {
+				return nil, fmt.Errorf("Can't differentiate ‘%s’ with respect to its parameters", name)
+			}
+		}
+		if name == dist+"pdf" {
+			return mul(mul(du, pdfSlope(dist, args, pos)), n), nil
+		}
+		// The cdf of a continuous distribution is the integral of its pdf
+		return mul(du, call(dist+"pdf", args...)), nil
+	}
+
+	if len(args) > 1 {
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", name)
+	}
+
+	var deriv Node
+	switch name {
+	case "sin":
+		deriv = d.scale(call("cos", u), false)
+	case "cos":
+		deriv = neg(d.scale(call("sin", u), false))
+	case "tan":
+		deriv = d.scale(pow(call("sec", u), num(2)), false)
+	case "sec":
+		deriv = d.scale(mul(call("sec", u), call("tan", u)), false)
+	case "csc":
+		deriv = neg(d.scale(mul(call("csc", u), call("cot", u)), false))
+	case "cot":
+		deriv = neg(d.scale(pow(call("csc", u), num(2)), false))
+	case "asin":
+		deriv = d.scale(div(num(1), call("sqrt", sub(num(1), sq))), true)
+	case "acos":
+		deriv = neg(d.scale(div(num(1), call("sqrt", sub(num(1), sq))), true))
+	case "atan":
+		deriv = d.scale(div(num(1), add(num(1), sq)), true)
+	case "deg":
+		deriv = binary(Div, num(180), identNode("pi", pos))
+	case "rad":
+		deriv = binary(Div, identNode("pi", pos), num(180))
+	case "sinh":
+		deriv = call("cosh", u)
+	case "cosh":
+		deriv = call("sinh", u)
+	case "tanh":
+		deriv = sub(num(1), pow(call("tanh", u), num(2)))
+	case "asinh":
+		deriv = div(num(1), call("sqrt", add(sq, num(1))))
+	case "acosh":
+		deriv = div(num(1), call("sqrt", sub(sq, num(1))))
+	case "atanh":
+		deriv = div(num(1), sub(num(1), sq))
+	case "ln":
+		return div(du, u), nil
+	case "log":
+		return div(du, mul(u, call("ln", num(10)))), nil
+	case "sqrt":
+		return div(du, mul(num(2), n)), nil
+	case "abs":
+		return div(mul(u, du), n), nil
+	case "floor", "ceil":
+		deriv = num(0)
+	case "erf", "erfc":
+		// 2/sqrt(pi) * e**-(u**2)
+		deriv = mul(div(num(2), call("sqrt", identNode("pi", pos))), pow(identNode("e", pos), neg(sq)))
+		if name == "erfc" {
+			deriv = neg(deriv)
+		}
+	case "erfinv":
+		deriv = mul(div(call("sqrt", identNode("pi", pos)), num(2)), pow(identNode("e", pos), pow(n, num(2))))
+	case "gamma":
+		deriv = mul(n, call("digamma", u))
+	case "fact":
+		deriv = mul(n, call("digamma", add(u, num(1))))
+	case "lgamma":
+		deriv = call("digamma", u)
+	case "j0":
+		deriv = neg(call("j1", u))
+	case "j1":
+		deriv = sub(call("j0", u), div(n, u))
+	case "digamma", "zeta":
+		return nil, fmt.Errorf("Can't differentiate ‘%s’, its derivative isn't a built-in function", name)
+	default:
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", name)
+	}
+
+	return mul(du, deriv), nil
+}
+
+// pdfSlope returns the derivative of the logarithm of the pdf of a
+// distribution in its point, which is the derivative of the pdf divided by
+// the pdf
+func pdfSlope(dist string, args []Node, pos int) Node {
+	num := func(x int64) Node {
+		return intNode(x, pos)
+	}
+	x := args[0]
+
+	switch dist {
+	case "norm":
+		mean, sd := num(0), num(1)
+		if len(args) > 1 {
+			mean = args[1]
+		}
+		if len(args) > 2 {
+			sd = args[2]
+		}
+		// -(x - mean)/sd**2
+		return neg(div(sub(x, mean), pow(sd, num(2))))
+	case "t":
+		// -(df + 1)*x/(df + x**2)
+		df := args[1]
+		return neg(div(mul(add(df, num(1)), x), add(df, pow(x, num(2)))))
+	case "chi2":
+		// (k/2 - 1)/x - 1/2
+		k := args[1]
+		return sub(div(sub(div(k, num(2)), num(1)), x), div(num(1), num(2)))
+	case "f":
+		// (d1/2 - 1)/x - (d1 + d2)*d1/(2*(d2 + d1*x))
+		d1, d2 := args[1], args[2]
+		return sub(div(sub(div(d1, num(2)), num(1)), x),
+			div(mul(add(d1, d2), d1), mul(num(2), add(d2, mul(d1, x)))))
+	case "exp":
+		return neg(args[1])
+	}
+
+	// The uniform pdf is constant between the bounds
+	return num(0)
+}
+
+// scale multiplies the derivative of a trigonometric function by the factor
+// of the angle unit
+func (d *differ) scale(deriv Node, inverse bool) Node {
+	if c := d.angleFactor(deriv.Pos(), inverse); c != nil {
+		return mul(c, deriv)
+	}
+
+	return deriv
+}
+
+// diff evaluates the derivative of f with respect to x at a, like
+// diff(x**2 * sin(x), x, pi)
+func diff(p *Parser, args []*Expr) (Value, error) {
+	name, err := args[1].Ident()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &differ{x: name, unit: p.AngleUnit}
+	deriv, err := d.diff(args[0].Node())
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := args[2].EvalRat(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.evalNode(deriv, args[0].bind(map[string]*big.Rat{name: at}))
+}
@@ -0,0 +1,125 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	derivs := map[string]string{
+		"x**2 * sin(x)":    "x**2*cos(x) + 2*x*sin(x)",
+		"3*x**3 - 2*x + 7": "9*x**2 - 2",
+		"y*x + y":          "y",
+		"5":                "0",
+		"1/x":              "-1/x**2",
+		"x**x":             "(ln(x) + 1)*x**x",
+		"2**x":             "ln(2)*2**x",
+		"e**(x**2)":        "2*x*e**(x**2)",
+		"sin(x**2)":        "2*x*cos(x**2)",
+		"cos(x)*y":         "-y*sin(x)",
+		"tan(x)":           "sec(x)**2",
+		"acos(x)":          "-1/sqrt(1 - x**2)",
+		"atan2(x, 1)":      "1/(x**2 + 1)",
+		"ln(x**2 + 1)":     "2*x/(x**2 + 1)",
+		"log(x)":           "1/(x*ln(10))",
+		"logn(2, x)":       "1/(x*ln(2))",
+		"sqrt(x)":          "1/(2*sqrt(x))",
+		"abs(x)":           "x/abs(x)",
+		"x % 3":            "1",
+		"x > 2":            "0",
+		"max(x, 2)":        "x >= 2",
+		"normcdf(x, 1, 2)": "normpdf(x, 1, 2)",
+		"erf(x)":           "2*e**-(x**2)/sqrt(pi)",
+		"tanh(x)":          "1 - tanh(x)**2",
+		"gamma(2*x)":       "2*digamma(2*x)*gamma(2*x)",
+		"fact(2*x)":        "2*digamma(2*x + 1)*fact(2*x)",
+		"(x**2 + 1)**3":    "6*x*(x**2 + 1)**2",
+		"[x, x**2; 1, y]":  "[1, 2*x; 0, 0]",
+		"-x":               "-1",
+		"x*cos(x)*y":       "y*(cos(x) - x*sin(x))",
+		"j1(x)":            "j0(x) - j1(x)/x",
+		"floor(x) + 1.5*x": "3/2",
+		"x/3 + x**2/1.5":   "4*x/3 + 1/3",
+		"ln(x)*x - x":      "ln(x)",
+		"x/(x + 1)":        "1/(x + 1)**2",
+		"sqrt(x**2 + 1)":   "x/sqrt(x**2 + 1)",
+		"beta(x, 2)":       "beta(x, 2)*(digamma(x) - digamma(x + 2))",
+		"normpdf(x)":       "-x*normpdf(x)",
+		"tpdf(x, 3)":       "-4*x*tpdf(x, 3)/(x**2 + 3)",
+		"exppdf(x, 2)":     "-2*exppdf(x, 2)",
+		"unifpdf(x, 0, 2)": "0",
+	}
+
+	for expr, expected := range derivs {
+		deriv, err := Diff(expr, "x")
+		if err != nil {
+			t.Errorf("unexpected error differentiating '%s': %s", expr, err)
+			continue
+		}
+
+		if deriv.String() != expected {
+			t.Errorf("wrong derivative of '%s' (expected %s, got %s)", expr, expected, deriv)
+		}
+
+		// Derivatives have to be valid expressions
+		if _, err := Parse(deriv.String()); err != nil {
+			t.Errorf("derivative of '%s' doesn't parse: %s", expr, err)
+		}
+	}
+
+	badExprs := []string{
+		"x & 1", "~x", "x = 2", "foo(x)", "digamma(x)", "zeta(x)", "normcdf(1, x)",
+		"normpdf(1, x)", "1 +",
+	}
+
+	for _, expr := range badExprs {
+		if _, err := Diff(expr, "x"); err == nil {
+			t.Errorf("expected error differentiating '%s'", expr)
+		}
+	}
+}
+
+func TestDiffFunction(t *testing.T) {
+	calls := map[string]string{
+		"diff(x**2 * sin(x), x, 2)": "1.97260236111416",
+		"diff(x**x, x, 2)":          "6.77258872223978",
+		"diff(sqrt(x), x, 1/4)":     "1.00000000000000",
+		"diff(a*x**2, x, 3)":        "18.00000000000000",
+		"diff(hypot(x, 4), x, 3)":   "0.60000000000000",
+		"diff(normcdf(x), x, 0)":    "0.39894228040143",
+		"diff(fact(x), x, 3)":       "7.53670601059080",
+		"diff(beta(x, 2), x, 3)":    "-0.04861111111111",
+		"diff(chi2pdf(x, 4), x, 1)": "0.07581633246408",
+		"diff(fpdf(x, 3, 5), x, 1)": "-0.36117447894229",
+		"diff(tpdf(x, 3), x, 1)":    "-0.20674833578317",
+	}
+
+	for expr, expected := range calls {
+		p := New()
+		p.Run("a = 3")
+		res, err := p.Run(expr)
+		if err != nil {
+			t.Errorf("unexpected error differentiating '%s': %s", expr, err)
+			continue
+		}
+
+		if res.FloatString(14) != expected {
+			t.Errorf("wrong derivative '%s' (expected %s, got %s)", expr, expected, res.FloatString(14))
+		}
+	}
+
+	// Trigonometric functions in degrees
+	p := New()
+	p.AngleUnit = Degrees
+	res, err := p.Run("diff(sin(x), x, 60)")
+	if err != nil || res.FloatString(14) != "0.00872664625997" {
+		t.Errorf("wrong derivative in degrees: %v, %v", res, err)
+	}
+	res, err = p.Run("diff(atan(x), x, 1)")
+	if err != nil || res.FloatString(14) != "28.64788975654116" {
+		t.Errorf("wrong derivative of inverse in degrees: %v, %v", res, err)
+	}
+}
@@ -0,0 +1,689 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Distribution is a probability distribution with its parameters set. All
+// calculations are done with prec bits of mantissa.
+type Distribution interface {
+	// PDF calculates the probability density function at x. For discrete
+	// distributions this is the probability mass function.
+	PDF(x *big.Float, prec uint) (*big.Float, error)
+	// CDF calculates the cumulative distribution function at x
+	CDF(x *big.Float, prec uint) (*big.Float, error)
+	// Quantile calculates the inverse of the CDF at 0 < p < 1. For discrete
+	// distributions, this is the smallest k with CDF(k) >= p.
+	Quantile(p *big.Float, prec uint) (*big.Float, error)
+}
+
+var (
+	ErrInvalidParam       = errors.New("Invalid distribution parameter")
+	ErrInvalidProbability = errors.New("Probability must be between 0 and 1")
+)
+
+// checkProbability errors if p is not strictly between 0 and 1
+func checkProbability(p *big.Float) error {
+	if p.Sign() <= 0 || p.Cmp(big.NewFloat(1)) >= 0 {
+		return ErrInvalidProbability
+	}
+
+	return nil
+}
+
+func positive(params ...*big.Float) error {
+	for _, param := range params {
+		if param.Sign() <= 0 {
+			return ErrInvalidParam
+		}
+	}
+
+	return nil
+}
+
+// sqrtTwoPi returns sqrt(2 * pi)
+func sqrtTwoPi(prec uint) *big.Float {
+	res := bigPi(prec)
+	res.SetMantExp(res, 1)
+	return res.Sqrt(res)
+}
+
+// half returns x / 2
+func half(x *big.Float) *big.Float {
+	return new(big.Float).SetMantExp(x, -1)
+}
+
+type normal struct {
+	mu, sigma *big.Float
+}
+
+// NewNormal creates a normal distribution with mean mu and standard deviation
+// sigma
+func NewNormal(mu, sigma *big.Float) (Distribution, error) {
+	if err := positive(sigma); err != nil {
+		return nil, err
+	}
+
+	return normal{mu, sigma}, nil
+}
+
+// z calculates (x - mu) / sigma
+func (d normal) z(x *big.Float, wp uint) *big.Float {
+	z := new(big.Float).SetPrec(wp).Sub(x, d.mu)
+	return z.Quo(z, d.sigma)
+}
+
+func (d normal) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+
+	// e**(-z**2 / 2) / (sigma * sqrt(2 * pi))
+	z := d.z(x, wp)
+	z = half(z.Mul(z, z))
+	res := bigExp(z.Neg(z), wp)
+	res.Quo(res, sqrtTwoPi(wp))
+
+	return res.Quo(res, d.sigma).SetPrec(prec), nil
+}
+
+func (d normal) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+
+	// erfc(-z / sqrt(2)) / 2
+	z := d.z(x, wp)
+	z.Quo(z.Neg(z), newFloat(wp, 2).Sqrt(newFloat(wp, 2)))
+	res, err := Erfc(z, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return half(res).SetPrec(prec), nil
+}
+
+func (d normal) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	if err := checkProbability(p); err != nil {
+		return nil, err
+	}
+
+	wp := prec + guardBits
+
+	// mu - sigma * sqrt(2) * erfcinv(2p)
+	res, err := Erfcinv(new(big.Float).SetPrec(wp).SetMantExp(p, 1), wp)
+	if err != nil {
+		return nil, err
+	}
+	res.Mul(res, newFloat(wp, 2).Sqrt(newFloat(wp, 2)))
+	res.Mul(res, d.sigma)
+
+	return res.Sub(d.mu, res).SetPrec(prec), nil
+}
+
+type studentT struct {
+	df *big.Float
+}
+
+// NewStudentT creates a Student's t-distribution with df degrees of freedom
+func NewStudentT(df *big.Float) (Distribution, error) {
+	if err := positive(df); err != nil {
+		return nil, err
+	}
+
+	return studentT{df}, nil
+}
+
+func (d studentT) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+
+	// (1 + x**2/df)**(-(df+1)/2) / (sqrt(df) * B(df/2, 1/2))
+	base := new(big.Float).SetPrec(wp).Mul(x, x)
+	base.Quo(base, d.df)
+	base.Add(base, newFloat(wp, 1))
+	exp := new(big.Float).SetPrec(wp).Add(d.df, newFloat(wp, 1))
+	exp = half(exp)
+	res, err := bigPow(base, exp.Neg(exp), wp)
+	if err != nil {
+		return nil, err
+	}
+
+	beta, err := Beta(half(d.df), big.NewFloat(0.5), wp)
+	if err != nil {
+		return nil, err
+	}
+	res.Quo(res, beta)
+
+	return res.Quo(res, new(big.Float).SetPrec(wp).Sqrt(d.df)).SetPrec(prec), nil
+}
+
+func (d studentT) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+
+	if x.Sign() == 0 {
+		return big.NewFloat(0.5).SetPrec(prec), nil
+	}
+
+	// The tail is I_(df / (df + x**2))(df/2, 1/2) / 2
+	t := new(big.Float).SetPrec(wp).Mul(x, x)
+	t.Add(t, d.df)
+	t.Quo(d.df, t)
+	tail, err := BetaInc(t, half(d.df), big.NewFloat(0.5), wp)
+	if err != nil {
+		return nil, err
+	}
+	tail = half(tail)
+
+	if x.Sign() < 0 {
+		return tail.SetPrec(prec), nil
+	}
+
+	return tail.Sub(newFloat(wp, 1), tail).SetPrec(prec), nil
+}
+
+func (d studentT) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	return invertCDF(d, p, false, prec)
+}
+
+type chiSquared struct {
+	k *big.Float
+}
+
+// NewChiSquared creates a chi-squared distribution with k degrees of freedom
+func NewChiSquared(k *big.Float) (Distribution, error) {
+	if err := positive(k); err != nil {
+		return nil, err
+	}
+
+	return chiSquared{k}, nil
+}
+
+func (d chiSquared) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() <= 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	wp := prec + guardBits
+
+	// e**((k/2 - 1) * ln(x) - x/2 - (k/2) * ln(2) - lgamma(k/2))
+	halfK := half(d.k)
+	lnX, _ := bigLog(x, wp)
+	exp := new(big.Float).SetPrec(wp).Sub(halfK, newFloat(wp, 1))
+	exp.Mul(exp, lnX)
+	exp.Sub(exp, half(x))
+	exp.Sub(exp, new(big.Float).SetPrec(wp).Mul(halfK, ln2Cache.get(wp)))
+	lg, err := Lgamma(halfK, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return bigExp(exp.Sub(exp, lg), prec), nil
+}
+
+func (d chiSquared) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() <= 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// P(k/2, x/2)
+	return GammaInc(half(d.k), half(x), prec)
+}
+
+func (d chiSquared) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	return invertCDF(d, p, true, prec)
+}
+
+type fDist struct {
+	d1, d2 *big.Float
+}
+
+// NewF creates an F-distribution with d1 and d2 degrees of freedom
+func NewF(d1, d2 *big.Float) (Distribution, error) {
+	if err := positive(d1, d2); err != nil {
+		return nil, err
+	}
+
+	return fDist{d1, d2}, nil
+}
+
+func (d fDist) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() <= 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	wp := prec + guardBits
+
+	// e**((d1/2) * ln(d1*x) + (d2/2) * ln(d2) - ((d1+d2)/2) * ln(d1*x + d2)) /
+	// (x * B(d1/2, d2/2))
+	d1x := new(big.Float).SetPrec(wp).Mul(d.d1, x)
+	lnD1x, _ := bigLog(d1x, wp)
+	lnD2, _ := bigLog(d.d2, wp)
+	lnSum, _ := bigLog(new(big.Float).SetPrec(wp).Add(d1x, d.d2), wp)
+
+	exp := lnD1x.Mul(lnD1x, half(d.d1))
+	exp.Add(exp, lnD2.Mul(lnD2, half(d.d2)))
+	exp.Sub(exp, lnSum.Mul(lnSum, half(new(big.Float).SetPrec(wp).Add(d.d1, d.d2))))
+
+	beta, err := Beta(half(d.d1), half(d.d2), wp)
+	if err != nil {
+		return nil, err
+	}
+
+	res := bigExp(exp, wp)
+	res.Quo(res, x)
+
+	return res.Quo(res, beta).SetPrec(prec), nil
+}
+
+func (d fDist) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() <= 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	wp := prec + guardBits
+
+	// I_(d1*x / (d1*x + d2))(d1/2, d2/2)
+	d1x := new(big.Float).SetPrec(wp).Mul(d.d1, x)
+	t := new(big.Float).SetPrec(wp).Add(d1x, d.d2)
+
+	return BetaInc(t.Quo(d1x, t), half(d.d1), half(d.d2), prec)
+}
+
+func (d fDist) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	return invertCDF(d, p, true, prec)
+}
+
+type exponential struct {
+	rate *big.Float
+}
+
+// NewExponential creates an exponential distribution with given rate
+func NewExponential(rate *big.Float) (Distribution, error) {
+	if err := positive(rate); err != nil {
+		return nil, err
+	}
+
+	return exponential{rate}, nil
+}
+
+func (d exponential) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() < 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// rate * e**(-rate * x)
+	wp := prec + guardBits
+	exp := new(big.Float).SetPrec(wp).Mul(d.rate, x)
+	res := bigExp(exp.Neg(exp), wp)
+
+	return res.Mul(res, d.rate).SetPrec(prec), nil
+}
+
+func (d exponential) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() < 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// 1 - e**(-rate * x)
+	wp := prec + guardBits
+	exp := new(big.Float).SetPrec(wp).Mul(d.rate, x)
+	res := bigExp(exp.Neg(exp), wp)
+
+	return res.Sub(newFloat(wp, 1), res).SetPrec(prec), nil
+}
+
+func (d exponential) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	if err := checkProbability(p); err != nil {
+		return nil, err
+	}
+
+	// -ln(1 - p) / rate
+	wp := prec + guardBits
+	res, err := bigLog(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), p), wp)
+	if err != nil {
+		return nil, err
+	}
+	res.Quo(res, d.rate)
+
+	return res.Neg(res).SetPrec(prec), nil
+}
+
+type uniform struct {
+	a, b *big.Float
+}
+
+// NewUniform creates a continuous uniform distribution on [a, b]
+func NewUniform(a, b *big.Float) (Distribution, error) {
+	if a.Cmp(b) >= 0 {
+		return nil, ErrInvalidParam
+	}
+
+	return uniform{a, b}, nil
+}
+
+func (d uniform) width(wp uint) *big.Float {
+	return new(big.Float).SetPrec(wp).Sub(d.b, d.a)
+}
+
+func (d uniform) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Cmp(d.a) < 0 || x.Cmp(d.b) > 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	return newFloat(prec, 1).Quo(newFloat(prec, 1), d.width(prec+guardBits)), nil
+}
+
+func (d uniform) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	switch {
+	case x.Cmp(d.a) < 0:
+		return newFloat(prec, 0), nil
+	case x.Cmp(d.b) > 0:
+		return newFloat(prec, 1), nil
+	}
+
+	res := new(big.Float).SetPrec(prec+guardBits).Sub(x, d.a)
+	return res.Quo(res, d.width(prec+guardBits)).SetPrec(prec), nil
+}
+
+func (d uniform) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	if err := checkProbability(p); err != nil {
+		return nil, err
+	}
+
+	res := new(big.Float).SetPrec(prec+guardBits).Mul(p, d.width(prec+guardBits))
+	return res.Add(res, d.a).SetPrec(prec), nil
+}
+
+type binomialDist struct {
+	n *big.Int
+	p *big.Float
+}
+
+// NewBinomialDist creates a binomial distribution of n trials with success
+// probability p
+func NewBinomialDist(n *big.Int, p *big.Float) (Distribution, error) {
+	if n.Sign() < 0 || p.Sign() < 0 || p.Cmp(big.NewFloat(1)) > 0 {
+		return nil, ErrInvalidParam
+	}
+
+	return binomialDist{n, p}, nil
+}
+
+// count converts x to an integer k, reporting whether x was one
+func count(x *big.Float) (*big.Int, bool) {
+	k, acc := x.Int(nil)
+	if x.Sign() < 0 && acc != big.Exact {
+		// Round towards negative infinity
+		k.Sub(k, bigOne)
+	}
+
+	return k, acc == big.Exact
+}
+
+func (d binomialDist) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	k, isInt := count(x)
+	if !isInt || k.Sign() < 0 || k.Cmp(d.n) > 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	wp := prec + guardBits
+	q := new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), d.p)
+
+	// C(n, k) * p**k * (1-p)**(n-k), with the binomial coefficient calculated
+	// through lgamma if it's too large
+	nk := new(big.Int).Sub(d.n, k)
+	binom, err := Binomial(d.n, k)
+	if err != nil {
+		return d.pdfLog(k, nk, q, prec)
+	}
+
+	res := new(big.Float).SetPrec(wp).SetInt(binom)
+	if d.n.IsInt64() && d.n.Int64() < 1<<16 {
+		res.Mul(res, bigPowInt(d.p, k.Int64(), wp))
+		res.Mul(res, bigPowInt(q, nk.Int64(), wp))
+		return res.SetPrec(prec), nil
+	}
+
+	return d.pdfLog(k, nk, q, prec)
+}
+
+// pdfLog calculates the probability mass function through logarithms, for
+// large n
+func (d binomialDist) pdfLog(k, nk *big.Int, q *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits + uint(d.n.BitLen())
+
+	// Edge cases where ln(p) or ln(1-p) are undefined
+	if d.p.Sign() == 0 || q.Sign() == 0 {
+		if (d.p.Sign() == 0 && k.Sign() == 0) || (q.Sign() == 0 && nk.Sign() == 0) {
+			return newFloat(prec, 1), nil
+		}
+		return newFloat(prec, 0), nil
+	}
+
+	fn := new(big.Float).SetPrec(wp).SetInt(d.n)
+	fk := new(big.Float).SetPrec(wp).SetInt(k)
+	fnk := new(big.Float).SetPrec(wp).SetInt(nk)
+
+	exp, _ := Lgamma(fn.Add(fn, newFloat(wp, 1)), wp)
+	lg, _ := Lgamma(new(big.Float).SetPrec(wp).Add(fk, newFloat(wp, 1)), wp)
+	exp.Sub(exp, lg)
+	lg, _ = Lgamma(new(big.Float).SetPrec(wp).Add(fnk, newFloat(wp, 1)), wp)
+	exp.Sub(exp, lg)
+
+	lnP, _ := bigLog(d.p, wp)
+	lnQ, _ := bigLog(q, wp)
+	exp.Add(exp, lnP.Mul(lnP, fk))
+	exp.Add(exp, lnQ.Mul(lnQ, fnk))
+
+	return bigExp(exp, prec), nil
+}
+
+func (d binomialDist) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	k, _ := count(x)
+	switch {
+	case k.Sign() < 0:
+		return newFloat(prec, 0), nil
+	case k.Cmp(d.n) >= 0:
+		return newFloat(prec, 1), nil
+	}
+
+	wp := prec + guardBits
+
+	// I_(1-p)(n-k, k+1)
+	q := new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), d.p)
+	a := new(big.Float).SetPrec(wp).SetInt(new(big.Int).Sub(d.n, k))
+	b := new(big.Float).SetPrec(wp).SetInt(new(big.Int).Add(k, bigOne))
+
+	return BetaInc(q, a, b, prec)
+}
+
+func (d binomialDist) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	if err := checkProbability(p); err != nil {
+		return nil, err
+	}
+
+	return searchQuantile(d, p, big.NewInt(0), d.n, prec)
+}
+
+type poisson struct {
+	lambda *big.Float
+}
+
+// NewPoisson creates a Poisson distribution with mean lambda
+func NewPoisson(lambda *big.Float) (Distribution, error) {
+	if err := positive(lambda); err != nil {
+		return nil, err
+	}
+
+	return poisson{lambda}, nil
+}
+
+func (d poisson) PDF(x *big.Float, prec uint) (*big.Float, error) {
+	k, isInt := count(x)
+	if !isInt || k.Sign() < 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// e**(k * ln(lambda) - lambda - lgamma(k + 1))
+	wp := prec + guardBits + uint(k.BitLen())
+	fk := new(big.Float).SetPrec(wp).SetInt(k)
+	exp, _ := bigLog(d.lambda, wp)
+	exp.Mul(exp, fk)
+	exp.Sub(exp, d.lambda)
+	lg, err := Lgamma(fk.Add(fk, newFloat(wp, 1)), wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return bigExp(exp.Sub(exp, lg), prec), nil
+}
+
+func (d poisson) CDF(x *big.Float, prec uint) (*big.Float, error) {
+	k, _ := count(x)
+	if k.Sign() < 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// Q(k + 1, lambda)
+	a := new(big.Float).SetPrec(prec + guardBits).SetInt(k.Add(k, bigOne))
+	return GammaIncComp(a, d.lambda, prec)
+}
+
+func (d poisson) Quantile(p *big.Float, prec uint) (*big.Float, error) {
+	if err := checkProbability(p); err != nil {
+		return nil, err
+	}
+
+	// Find an upper bound by doubling
+	hi := big.NewInt(1)
+	for {
+		cdf, err := d.CDF(new(big.Float).SetInt(hi), prec)
+		if err != nil {
+			return nil, err
+		}
+		if cdf.Cmp(p) >= 0 {
+			break
+		}
+		if hi.BitLen() > maxBitWidth {
+			return nil, ErrNoConvergence
+		}
+		hi.Lsh(hi, 1)
+	}
+
+	return searchQuantile(d, p, big.NewInt(0), hi, prec)
+}
+
+// searchQuantile finds the smallest integer k in [lo, hi] with CDF(k) >= p
+// using binary search
+func searchQuantile(d Distribution, p *big.Float, lo, hi *big.Int, prec uint) (*big.Float, error) {
+	lo, hi = new(big.Int).Set(lo), new(big.Int).Set(hi)
+	for lo.Cmp(hi) < 0 {
+		mid := new(big.Int).Add(lo, hi)
+		mid.Rsh(mid, 1)
+
+		cdf, err := d.CDF(new(big.Float).SetInt(mid), prec)
+		if err != nil {
+			return nil, err
+		}
+
+		if cdf.Cmp(p) >= 0 {
+			hi = mid
+		} else {
+			lo = mid.Add(mid, bigOne)
+		}
+	}
+
+	return new(big.Float).SetPrec(prec).SetInt(lo), nil
+}
+
+// invertCDF calculates the quantile of a continuous distribution numerically,
+// using Newton's method safeguarded with bisection. Distributions on [0, inf)
+// are marked with positive, others are assumed to be on (-inf, inf).
+func invertCDF(d Distribution, p *big.Float, positive bool, prec uint) (*big.Float, error) {
+	if err := checkProbability(p); err != nil {
+		return nil, err
+	}
+
+	wp := prec + guardBits
+	cdfAt := func(x *big.Float) (int, error) {
+		cdf, err := d.CDF(x, wp)
+		if err != nil {
+			return 0, err
+		}
+		return cdf.Cmp(p), nil
+	}
+
+	// Find a bracket [lo, hi] containing the quantile by doubling
+	lo, hi := newFloat(wp, -1), newFloat(wp, 1)
+	if positive {
+		lo.SetInt64(0)
+	}
+	for i := 0; ; i++ {
+		if i > maxBitWidth {
+			return nil, ErrNoConvergence
+		}
+
+		cmp, err := cdfAt(hi)
+		if err != nil {
+			return nil, err
+		}
+		if cmp >= 0 {
+			break
+		}
+		lo.Set(hi)
+		hi.SetMantExp(hi, 1)
+	}
+	for i := 0; !positive; i++ {
+		if i > maxBitWidth {
+			return nil, ErrNoConvergence
+		}
+
+		cmp, err := cdfAt(lo)
+		if err != nil {
+			return nil, err
+		}
+		if cmp <= 0 {
+			break
+		}
+		hi.Set(lo)
+		lo.SetMantExp(lo, 1)
+	}
+
+	x := half(new(big.Float).SetPrec(wp).Add(lo, hi))
+	for i := 0; i < maxIterations; i++ {
+		cdf, err := d.CDF(x, wp)
+		if err != nil {
+			return nil, err
+		}
+		pdf, err := d.PDF(x, wp)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := cdf.Sub(cdf, p)
+		if diff.Sign() < 0 {
+			lo.Set(x)
+		} else {
+			hi.Set(x)
+		}
+
+		// Newton step if it stays inside the bracket, bisection otherwise
+		next := new(big.Float).SetPrec(wp)
+		if pdf.Sign() != 0 {
+			next.Sub(x, diff.Quo(diff, pdf))
+		}
+		if pdf.Sign() == 0 || next.Cmp(lo) <= 0 || next.Cmp(hi) >= 0 {
+			next = half(next.Add(lo, hi))
+		}
+
+		step := new(big.Float).SetPrec(wp).Sub(next, x)
+		x = next
+		width := new(big.Float).SetPrec(wp).Sub(hi, lo)
+		if negligible(step, x, prec+8) || negligible(width, x, prec+8) {
+			return x.SetPrec(prec), nil
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math/big"
+)
+
+// ErrExpectingIdent is returned when a variable name is expected as argument
+var ErrExpectingIdent = errors.New("Expecting a variable name")
+
+// Expr is an unevaluated argument of a function taking expressions, see
+// ExprFunc. It can be evaluated any number of times with variables bound to
+// different values. Variables that are bound shadow the parser's variables
+// without changing them.
+type Expr struct {
+	node  Node
+	p     *Parser
+	scope map[string]*big.Rat
+}
+
+// Node returns the parsed expression
+func (e *Expr) Node() Node {
+	return e.node
+}
+
+// Ident returns the variable name if the expression is a single variable
+func (e *Expr) Ident() (string, error) {
+	if ident, ok := e.node.(*IdentNode); ok {
+		return ident.Name(), nil
+	}
+
+	return "", ErrExpectingIdent
+}
+
+// Eval evaluates the expression with the given variables bound on top of the
+// variables of the parser. The bindings only exist during the evaluation.
+func (e *Expr) Eval(vars map[string]*big.Rat) (Value, error) {
+	return e.p.evalNode(e.node, e.bind(vars))
+}
+
+// EvalRat evaluates the expression like Eval, erroring if the result isn't a
+// number
+func (e *Expr) EvalRat(vars map[string]*big.Rat) (*big.Rat, error) {
+	res, err := e.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return scalar(res)
+}
+
+// bind returns the scope of e extended with vars
+func (e *Expr) bind(vars map[string]*big.Rat) map[string]*big.Rat {
+	if len(vars) == 0 {
+		return e.scope
+	}
+	if len(e.scope) == 0 {
+		return vars
+	}
+
+	scope := make(map[string]*big.Rat, len(e.scope)+len(vars))
+	for name, val := range e.scope {
+		scope[name] = val
+	}
+	for name, val := range vars {
+		scope[name] = val
+	}
+
+	return scope
+}
@@ -0,0 +1,399 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Float64Tolerance is the relative difference between results on float64 and
+// exact results that is expected for well-conditioned expressions. Results can
+// differ more after catastrophic cancellation, like in 1e20 + 1 - 1e20.
+const Float64Tolerance = 1e-12
+
+// EvalFloat64 evaluates an expression on float64 instead of rational numbers,
+// with the variables in vars. Operations follow IEEE 754, so division by zero
+// gives an infinity instead of an error and functions give NaN outside of
+// their domain. Use Program.RunFloat64 to evaluate an expression many times.
+//
+// Example:
+//     res, err := mathcat.EvalFloat64("x / 0", map[string]float64{"x": 1}) // +Inf
+func EvalFloat64(expr string, vars map[string]float64) (float64, error) {
+	p := New()
+	prog, err := p.Compile(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	for name, val := range vars {
+		if !IsValidIdent(name) {
+			return 0, fmt.Errorf("Invalid variable name: ‘%s’", name)
+		}
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return 0, fmt.Errorf("Invalid value for ‘%s’: %v", name, val)
+		}
+		p.Variables[name] = new(big.Rat).SetFloat64(val)
+	}
+
+	return prog.RunFloat64()
+}
+
+// RunFloat64 runs the program on float64, with the variables returned by Vars
+// bound to vars, like Run. Operations follow IEEE 754 like in EvalFloat64.
+// Programs that only use arithmetic and functions from the math package run
+// without allocating.
+func (prog *Program) RunFloat64(vars ...float64) (float64, error) {
+	if len(vars) > prog.nvars {
+		return 0, fmt.Errorf("Too many variables (expected at most %d, got %d)", prog.nvars, len(vars))
+	}
+
+	copy(prog.fslots, vars)
+	bound := len(vars)
+
+	stack, sp := prog.fstack, 0
+	for i, in := range prog.code {
+		switch in.op {
+		case opConst:
+			stack[sp] = prog.fconsts[in.arg]
+			sp++
+		case opLoad:
+			if int(in.arg) < bound {
+				stack[sp] = prog.fslots[in.arg]
+			} else {
+				x, err := prog.lookupFloat64(i)
+				if err != nil {
+					return 0, err
+				}
+				stack[sp] = x
+			}
+			sp++
+		case opStore:
+			x := stack[sp-1]
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return 0, fmt.Errorf("Can't assign %v to ‘%s’", x, prog.names[in.arg])
+			}
+			prog.p.setVar(prog.names[in.arg], new(big.Rat).SetFloat64(x))
+			if int(in.arg) < bound {
+				prog.fslots[in.arg] = x
+			}
+		case opNeg:
+			stack[sp-1] = -stack[sp-1]
+		case opAdd:
+			sp--
+			stack[sp-1] = stack[sp] + stack[sp-1]
+		case opSub:
+			sp--
+			stack[sp-1] = stack[sp] - stack[sp-1]
+		case opMul:
+			sp--
+			stack[sp-1] = stack[sp] * stack[sp-1]
+		case opDiv:
+			sp--
+			stack[sp-1] = stack[sp] / stack[sp-1]
+		case opPow:
+			sp--
+			stack[sp-1] = math.Pow(stack[sp], stack[sp-1])
+		case opRem:
+			sp--
+			stack[sp-1] = floatMod(stack[sp], stack[sp-1])
+		case opCmp:
+			sp--
+			stack[sp-1] = compareFloat64(prog.toks[i].Type, stack[sp], stack[sp-1])
+		case opOp:
+			var lhs float64
+			if !operators[prog.toks[i].Type].unary {
+				sp--
+				lhs = stack[sp]
+			}
+			res, err := float64Op(prog.toks[i], lhs, stack[sp-1])
+			if err != nil {
+				return 0, err
+			}
+			stack[sp-1] = res
+		case opCall:
+			call := prog.calls[in.arg]
+			sp -= call.arity
+			res, err := prog.callFloat64(prog.toks[i], call, stack[sp:sp+call.arity])
+			if err != nil {
+				return 0, err
+			}
+			stack[sp] = res
+			sp++
+		case opEval:
+			scope := make(map[string]*big.Rat)
+			for j, x := range prog.fslots[:bound] {
+				if !math.IsNaN(x) && !math.IsInf(x, 0) {
+					scope[prog.names[j]] = new(big.Rat).SetFloat64(x)
+				}
+			}
+			res, err := prog.p.evalCall(prog.evals[in.arg], scope)
+			if err != nil {
+				return 0, err
+			}
+			x, err := scalar(res)
+			if err != nil {
+				return 0, err
+			}
+			stack[sp], _ = x.Float64()
+			sp++
+		}
+	}
+
+	return stack[0], nil
+}
+
+// lookupFloat64 looks up the variable loaded by instruction i in the parser's
+// variables. Conversions are cached as long as the variable isn't assigned.
+func (prog *Program) lookupFloat64(i int) (float64, error) {
+	slot := prog.code[i].arg
+	x, err := prog.p.lookup(prog.toks[i])
+	if err != nil {
+		return 0, err
+	}
+
+	if prog.fcache[slot] != x {
+		prog.fcache[slot] = x
+		prog.fcacheVals[slot], _ = x.Float64()
+	}
+
+	return prog.fcacheVals[slot], nil
+}
+
+// floatMod returns x % y with the sign of the divisor, like Mod
+func floatMod(x, y float64) float64 {
+	res := math.Mod(x, y)
+	if res != 0 && (res < 0) != (y < 0) {
+		res += y
+	}
+
+	return res
+}
+
+// compareFloat64 gives 1 if the comparison op of lhs and rhs is true, and 0
+// otherwise. Comparisons with NaN are false, except for !=.
+func compareFloat64(op TokenType, lhs, rhs float64) float64 {
+	var res bool
+	switch op {
+	case EqEq:
+		res = lhs == rhs
+	case NotEq:
+		res = lhs != rhs
+	case Gt:
+		res = lhs > rhs
+	case GtEq:
+		res = lhs >= rhs
+	case Lt:
+		res = lhs < rhs
+	case LtEq:
+		res = lhs <= rhs
+	}
+
+	if res {
+		return 1
+	}
+	return 0
+}
+
+// baseOps are the operators compound assignments apply
+var baseOps = map[TokenType]TokenType{
+	AddEq: Add, SubEq: Sub, MulEq: Mul, DivEq: Div, PowEq: Pow, RemEq: Rem,
+	AndEq: And, OrEq: Or, XorEq: Xor, LshEq: Lsh, RshEq: Rsh,
+}
+
+// float64Op executes an operator on float64. Bitwise operators need integers
+// that fit in an int64.
+func float64Op(operator *Token, lhs, rhs float64) (float64, error) {
+	op := operator.Type
+	if base, ok := baseOps[op]; ok {
+		op = base
+	}
+
+	switch op {
+	case Add:
+		return lhs + rhs, nil
+	case Sub:
+		return lhs - rhs, nil
+	case Mul:
+		return lhs * rhs, nil
+	case Div:
+		return lhs / rhs, nil
+	case Pow:
+		return math.Pow(lhs, rhs), nil
+	case Rem:
+		return floatMod(lhs, rhs), nil
+	case Eq:
+		return rhs, nil
+	case UnaryMin:
+		return -rhs, nil
+	}
+
+	// Only rhs is used by unary operators
+	if !isInt64(rhs) || !operators[op].unary && !isInt64(lhs) {
+		return 0, fmt.Errorf("Expecting integers for ‘%s’", operator)
+	}
+
+	switch op {
+	case And:
+		return float64(int64(lhs) & int64(rhs)), nil
+	case Or:
+		return float64(int64(lhs) | int64(rhs)), nil
+	case Xor:
+		return float64(int64(lhs) ^ int64(rhs)), nil
+	case Lsh:
+		return math.Ldexp(lhs, int(rhs)), nil
+	case Rsh:
+		return math.Floor(math.Ldexp(lhs, -int(rhs))), nil
+	case Not:
+		return -rhs - 1, nil
+	}
+
+	return 0, fmt.Errorf("Invalid operator ‘%s’", operator)
+}
+
+// isInt64 checks if x is an integer that fits in an int64
+func isInt64(x float64) bool {
+	return x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64
+}
+
+// callFloat64 calls a function on float64. Functions without a float64
+// implementation are called on the exact values, giving NaN for NaN and
+// infinite arguments.
+func (prog *Program) callFloat64(tok *Token, call compiledCall, args []float64) (float64, error) {
+	if err := prog.p.checkCall(tok, call.function, call.arity); err != nil {
+		return 0, err
+	}
+
+	if call.float64 != nil {
+		return call.float64(prog.p, args), nil
+	}
+
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		if math.IsNaN(arg) || math.IsInf(arg, 0) {
+			return math.NaN(), nil
+		}
+		values[i] = new(big.Rat).SetFloat64(arg)
+	}
+
+	res, err := prog.p.call(tok, call.function, values)
+	if err != nil {
+		return 0, err
+	}
+	x, err := scalar(res)
+	if err != nil {
+		return 0, err
+	}
+
+	f, _ := x.Float64()
+	return f, nil
+}
+
+// float64Func is a function implemented on float64
+type float64Func func(p *Parser, args []float64) float64
+
+func mathFunc(fn func(float64) float64) float64Func {
+	return func(p *Parser, args []float64) float64 {
+		return fn(args[0])
+	}
+}
+
+func mathFunc2(fn func(float64, float64) float64) float64Func {
+	return func(p *Parser, args []float64) float64 {
+		return fn(args[0], args[1])
+	}
+}
+
+// toRadiansFloat64 converts an angle in the given unit to radians. Angles are
+// reduced to a full turn first, so multiples of a quarter turn stay exact.
+func (a AngleUnit) toRadiansFloat64(angle float64) float64 {
+	if turn := a.fullTurnFloat64(); turn != 0 {
+		return math.Mod(angle, turn) / turn * 2 * math.Pi
+	}
+
+	return angle
+}
+
+// fromRadiansFloat64 converts an angle in radians to the given unit
+func (a AngleUnit) fromRadiansFloat64(angle float64) float64 {
+	if turn := a.fullTurnFloat64(); turn != 0 {
+		return angle / (2 * math.Pi) * turn
+	}
+
+	return angle
+}
+
+func (a AngleUnit) fullTurnFloat64() float64 {
+	switch a {
+	case Degrees:
+		return 360
+	case Gradians:
+		return 400
+	}
+
+	return 0
+}
+
+// float64Funcs are the functions with a float64 implementation
+var float64Funcs = map[string]float64Func{
+	"abs": mathFunc(math.Abs), "ceil": mathFunc(math.Ceil),
+	"floor": mathFunc(math.Floor), "hypot": mathFunc2(math.Hypot),
+	"sinh": mathFunc(math.Sinh), "cosh": mathFunc(math.Cosh),
+	"tanh": mathFunc(math.Tanh), "asinh": mathFunc(math.Asinh),
+	"acosh": mathFunc(math.Acosh), "atanh": mathFunc(math.Atanh),
+	"ln": mathFunc(math.Log), "log": mathFunc(math.Log10),
+	"max": mathFunc2(math.Max), "min": mathFunc2(math.Min),
+	"sqrt": mathFunc(math.Sqrt), "gamma": mathFunc(math.Gamma),
+	"erf": mathFunc(math.Erf), "erfc": mathFunc(math.Erfc),
+	"erfinv": mathFunc(math.Erfinv), "j0": mathFunc(math.J0),
+	"j1": mathFunc(math.J1),
+	"sin": func(p *Parser, args []float64) float64 {
+		return math.Sin(p.AngleUnit.toRadiansFloat64(args[0]))
+	},
+	"cos": func(p *Parser, args []float64) float64 {
+		return math.Cos(p.AngleUnit.toRadiansFloat64(args[0]))
+	},
+	"tan": func(p *Parser, args []float64) float64 {
+		return math.Tan(p.AngleUnit.toRadiansFloat64(args[0]))
+	},
+	"sec": func(p *Parser, args []float64) float64 {
+		return 1 / math.Cos(p.AngleUnit.toRadiansFloat64(args[0]))
+	},
+	"csc": func(p *Parser, args []float64) float64 {
+		return 1 / math.Sin(p.AngleUnit.toRadiansFloat64(args[0]))
+	},
+	"cot": func(p *Parser, args []float64) float64 {
+		return 1 / math.Tan(p.AngleUnit.toRadiansFloat64(args[0]))
+	},
+	"asin": func(p *Parser, args []float64) float64 {
+		return p.AngleUnit.fromRadiansFloat64(math.Asin(args[0]))
+	},
+	"acos": func(p *Parser, args []float64) float64 {
+		return p.AngleUnit.fromRadiansFloat64(math.Acos(args[0]))
+	},
+	"atan": func(p *Parser, args []float64) float64 {
+		return p.AngleUnit.fromRadiansFloat64(math.Atan(args[0]))
+	},
+	"atan2": func(p *Parser, args []float64) float64 {
+		return p.AngleUnit.fromRadiansFloat64(math.Atan2(args[0], args[1]))
+	},
+	"deg": func(p *Parser, args []float64) float64 {
+		return args[0] * (180 / math.Pi)
+	},
+	"rad": func(p *Parser, args []float64) float64 {
+		return args[0] * (math.Pi / 180)
+	},
+	"logn": func(p *Parser, args []float64) float64 {
+		return math.Log(args[1]) / math.Log(args[0])
+	},
+	"fact": func(p *Parser, args []float64) float64 {
+		return math.Gamma(args[0] + 1)
+	},
+	"lgamma": func(p *Parser, args []float64) float64 {
+		res, _ := math.Lgamma(args[0])
+		return res
+	},
+}
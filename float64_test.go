@@ -0,0 +1,137 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+// float64Corpus is evaluated both exactly and on float64
+var float64Corpus = []string{
+	"3*x**2 - 2*x*y + (x + 1) % 5 - y/7",
+	"-x**2 - -y / 3",
+	"(x + 1) % -y + x % 0.75",
+	"x < y == (y >= x)",
+	"~x & 0xff | y << 2 ^ x >> 1",
+	"pi * x**2 / y + e**y - tau",
+	"max(x, y) + sqrt(abs(x)) - hypot(x, y)",
+	"sin(x) * cos(y) + tan(x/7) - atan2(y, x)",
+	"asin(1/y) + acos(-1/y) + atan(x)",
+	"ln(abs(x) + 1) + log(y) + logn(2, y**3)",
+	"fact(y) + gamma(y + 1/2) - erf(x/10)",
+	"sum(i, 1, y, i * x)",
+	"x**0.5 + y**-2 + (x/3)**3",
+	"gcd(x, 6) + isprime(y) + ncr(y + 5, 2)",
+	"a = x * 1.1 + 0.3",
+}
+
+func TestFloat64Corpus(t *testing.T) {
+	vals := [][2]int64{{1, 1}, {3, 4}, {12, 3}, {100, 7}}
+
+	for _, expr := range float64Corpus {
+		for _, v := range vals {
+			vars := map[string]float64{"x": float64(v[0]), "y": float64(v[1])}
+			res, err := EvalFloat64(expr, vars)
+			if err != nil {
+				t.Errorf("unexpected error evaluating '%s' with %v: %s", expr, v, err)
+				continue
+			}
+
+			expected, err := Exec(expr, map[string]*big.Rat{
+				"x": big.NewRat(v[0], 1),
+				"y": big.NewRat(v[1], 1),
+			})
+			if err != nil {
+				t.Errorf("unexpected error evaluating '%s' exactly with %v: %s", expr, v, err)
+				continue
+			}
+
+			exact, _ := expected.Float64()
+			if diff := math.Abs(res - exact); diff > Float64Tolerance*math.Max(1, math.Abs(exact)) {
+				t.Errorf("wrong result of '%s' with %v (expected %v, got %v)", expr, v, exact, res)
+			}
+		}
+	}
+}
+
+func TestEvalFloat64(t *testing.T) {
+	exprs := map[string]float64{
+		"1 / 0":          math.Inf(1),
+		"-1 / (x - x)":   math.Inf(-1),
+		"2**1024":        math.Inf(1),
+		"1 / 2**-1075":   math.Inf(1),
+		"sqrt(-1) != x":  1,
+		"ln(0)":          math.Inf(-1),
+		"x % 0 == x % 0": 0,
+		"7 % -3":         -2,
+		"-7 >> 1":        -4,
+		"1 << 70":        math.Ldexp(1, 70),
+		"fact(1/2)":      math.Gamma(1.5),
+		"fact(-1/2)":     math.Gamma(0.5),
+		"fact(-1)":       math.Inf(1),
+		"fact(5)":        120,
+		"isprime(1/0)":   math.NaN(),
+	}
+
+	for expr, expected := range exprs {
+		res, err := EvalFloat64(expr, map[string]float64{"x": 2})
+		if err != nil {
+			t.Errorf("unexpected error evaluating '%s': %s", expr, err)
+			continue
+		}
+		if res != expected && !(math.IsNaN(res) && math.IsNaN(expected)) {
+			t.Errorf("wrong result of '%s' (expected %v, got %v)", expr, expected, res)
+		}
+	}
+
+	errs := []string{"0.5 & 1", "2**64 | 1", "[1, 2]", "y + 1", "a = 1/0"}
+	for _, expr := range errs {
+		if res, err := EvalFloat64(expr, nil); err == nil {
+			t.Errorf("expected error evaluating '%s' (got %v)", expr, res)
+		}
+	}
+
+	// Deterministic mode is checked when running
+	p := New()
+	prog, _ := p.Compile("x + rand()")
+	p.Deterministic = true
+	if res, err := prog.RunFloat64(1); err == nil {
+		t.Errorf("expected error running random program in deterministic mode (got %v)", res)
+	}
+
+	// Angles are in the unit of the parser
+	p = New()
+	p.AngleUnit = Degrees
+	prog, _ = p.Compile("sin(x) + acos(0)")
+	if res, err := prog.RunFloat64(390); err != nil || math.Abs(res-90.5) > 1e-12 {
+		t.Errorf("wrong result in degrees (expected 90.5, got %v, %v)", res, err)
+	}
+}
+
+func TestRunFloat64Allocs(t *testing.T) {
+	p := New()
+	prog, err := p.Compile("pi * x**2 - sin(y) / max(x, y) + (x + 1) % 5 == y")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		prog.RunFloat64(1.5, 2.5)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations (got %v)", allocs)
+	}
+}
+
+func BenchmarkRunFloat64(b *testing.B) {
+	prog, _ := New().Compile(benchExpr)
+	for i := 0; i < b.N; i++ {
+		if _, err := prog.RunFloat64(float64(i%1000), float64(i%7)/2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
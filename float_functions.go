@@ -0,0 +1,197 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// FloatFormat describes the layout of an IEEE-754 binary floating point format
+type FloatFormat struct {
+	Name     string
+	ExpBits  uint // number of exponent bits
+	MantBits uint // number of stored mantissa bits, without the implicit bit
+}
+
+var (
+	Float16 = FloatFormat{"float16", 5, 10}
+	Float32 = FloatFormat{"float32", 8, 23}
+	Float64 = FloatFormat{"float64", 11, 52}
+
+	ErrNotFinite = errors.New("Result is not a finite number")
+
+	floatFormats = map[int64]FloatFormat{
+		16: Float16,
+		32: Float32,
+		64: Float64,
+	}
+)
+
+// Bits returns the total number of bits of the format
+func (f FloatFormat) Bits() uint {
+	return 1 + f.ExpBits + f.MantBits
+}
+
+func (f FloatFormat) bias() int {
+	return 1<<(f.ExpBits-1) - 1
+}
+
+// Encode rounds x to the nearest value representable in the format (ties to
+// even) and returns its bit pattern. exact reports whether x was representable
+// without rounding. Values too large for the format become infinity.
+func (f FloatFormat) Encode(x *big.Rat) (bits uint64, exact bool) {
+	var sign uint64
+	if x.Sign() < 0 {
+		sign = 1 << (f.Bits() - 1)
+	}
+
+	if x.Sign() == 0 {
+		return 0, true
+	}
+
+	abs := new(big.Rat).Abs(x)
+	bias := f.bias()
+
+	// Find e so that 2**e <= abs < 2**(e+1)
+	e := abs.Num().BitLen() - abs.Denom().BitLen()
+	if abs.Cmp(pow2(e)) < 0 {
+		e--
+	}
+
+	// Numbers below the smallest normal number are stored with the minimum
+	// exponent and without implicit bit
+	if e < 1-bias {
+		e = 1 - bias
+	}
+
+	scaled := new(big.Rat).Mul(abs, pow2(int(f.MantBits)-e))
+	n := roundHalfEven(scaled)
+	exact = scaled.IsInt()
+
+	implicit := new(big.Int).Lsh(big.NewInt(1), f.MantBits)
+
+	// Rounding up can carry into the next binade
+	if n.BitLen() > int(f.MantBits)+1 {
+		n.Rsh(n, 1)
+		e++
+	}
+
+	if e > bias {
+		return sign | f.infinity(), false
+	}
+
+	if n.Cmp(implicit) < 0 {
+		// Subnormal
+		return sign | n.Uint64(), exact
+	}
+
+	exp := uint64(e + bias)
+	mant := n.Sub(n, implicit).Uint64()
+
+	return sign | exp<<f.MantBits | mant, exact
+}
+
+// Decode converts a bit pattern of the format to the exact rational number it
+// represents. Infinities and NaNs give ErrNotFinite.
+func (f FloatFormat) Decode(bits uint64) (*big.Rat, error) {
+	sign, exp, mant := f.Fields(bits)
+
+	if exp == 1<<f.ExpBits-1 {
+		return nil, ErrNotFinite
+	}
+
+	res := new(big.Rat).SetInt(new(big.Int).SetUint64(mant))
+	e := int(exp) - f.bias()
+	if exp == 0 {
+		// Subnormals have no implicit bit and use the minimum exponent
+		e = 1 - f.bias()
+	} else {
+		res.Add(res, new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), f.MantBits)))
+	}
+
+	res.Mul(res, pow2(e-int(f.MantBits)))
+	if sign == 1 {
+		res.Neg(res)
+	}
+
+	return res, nil
+}
+
+// Fields splits a bit pattern of the format into its sign bit, biased exponent
+// and stored mantissa
+func (f FloatFormat) Fields(bits uint64) (sign, exp, mant uint64) {
+	sign = bits >> (f.Bits() - 1) & 1
+	exp = bits >> f.MantBits & (1<<f.ExpBits - 1)
+	mant = bits & (1<<f.MantBits - 1)
+	return
+}
+
+// Exponent returns the unbiased exponent of a bit pattern of the format
+func (f FloatFormat) Exponent(bits uint64) int {
+	_, exp, _ := f.Fields(bits)
+	if exp == 0 {
+		return 1 - f.bias()
+	}
+
+	return int(exp) - f.bias()
+}
+
+func (f FloatFormat) infinity() uint64 {
+	return (1<<f.ExpBits - 1) << f.MantBits
+}
+
+// pow2 returns 2**e as a rational number
+func pow2(e int) *big.Rat {
+	if e < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), uint(-e)))
+	}
+
+	return new(big.Rat).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(e)))
+}
+
+// roundHalfEven rounds a non-negative rational number to the nearest integer,
+// rounding ties to even
+func roundHalfEven(x *big.Rat) *big.Int {
+	quo, rem := new(big.Int).QuoRem(x.Num(), x.Denom(), new(big.Int))
+	switch new(big.Int).Lsh(rem, 1).Cmp(x.Denom()) {
+	case 1:
+		quo.Add(quo, big.NewInt(1))
+	case 0:
+		if quo.Bit(0) == 1 {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	return quo
+}
+
+// floatFormat gets the optional float format argument at index i, defaulting
+// to float64
+func floatFormat(args []*big.Rat, i int) (FloatFormat, error) {
+	if i >= len(args) {
+		return Float64, nil
+	}
+
+	if args[i].IsInt() {
+		if format, ok := floatFormats[args[i].Num().Int64()]; ok {
+			return format, nil
+		}
+	}
+
+	return FloatFormat{}, fmt.Errorf("Invalid float width ‘%s’ (expected 16, 32 or 64)", args[i].RatString())
+}
+
+// floatBits gets the bit pattern argument at index i, which has to fit in the
+// given format
+func floatBits(format FloatFormat, args []*big.Rat, i int) (uint64, error) {
+	bits := args[i].Num()
+	if bits.Sign() < 0 || bits.BitLen() > int(format.Bits()) {
+		return 0, fmt.Errorf("‘%s’ is not a valid %s bit pattern", bits, format.Name)
+	}
+
+	return bits.Uint64(), nil
+}
@@ -8,154 +8,1239 @@ import (
 	"fmt"
 	"math"
 	"math/big"
-	"math/rand"
 )
 
 type function struct {
-	arity int
-	fn    func(args []*big.Rat) *big.Rat
+	// arity is the number of arguments the function takes. If maxArity is
+	// larger than arity, the remaining arguments are optional.
+	arity, maxArity int
+	// integer functions only accept integer arguments
+	integer bool
+	// random functions give a different result on every call, and aren't
+	// allowed in deterministic mode
+	random bool
+	fn     func(p *Parser, args []*big.Rat) (*big.Rat, error)
+	// valueFn is used instead of fn by functions that take matrices
+	valueFn func(p *Parser, args []Value) (Value, error)
+	// exprFn is used instead of fn by functions that take unevaluated
+	// expressions, like solve
+	exprFn ExprFunc
 }
 
+// ExprFunc is a function that gets its arguments as unevaluated expressions.
+// It decides when to evaluate them, and can evaluate them any number of times
+// with variables bound to different values, like integrate(x**2, x, 0, 1)
+// does for x.
+type ExprFunc func(p *Parser, args []*Expr) (Value, error)
+
 type functions map[string]function
 
+// variadic is used as maxArity for functions taking any number of arguments
+const variadic = math.MaxInt32
+
 // FunctionNames holds all the function names that are available for use
 var FunctionNames []string
 
 var funcs = make(functions)
 
+// RegisterFunction adds a function that can be called in expressions, taking
+// between arity and maxArity arguments. A maxArity of -1 allows any number of
+// arguments. Functions can only be registered once, and registering isn't
+// safe while expressions are being evaluated.
+//
+// Example:
+//     // at(f, x, a) evaluates f with x bound to a
+//     mathcat.RegisterFunction("at", 3, 3, func(p *mathcat.Parser, args []*mathcat.Expr) (mathcat.Value, error) {
+//         name, err := args[1].Ident()
+//         if err != nil {
+//             return nil, err
+//         }
+//         a, err := args[2].EvalRat(nil)
+//         if err != nil {
+//             return nil, err
+//         }
+//         return args[0].Eval(map[string]*big.Rat{name: a})
+//     })
+//     res, err := mathcat.Eval("at(x**2 + 1, x, 3)") // 10
+func RegisterFunction(name string, arity, maxArity int, fn ExprFunc) error {
+	if name == "" || !IsValidIdent(name) {
+		return fmt.Errorf("Invalid function name ‘%s’", name)
+	}
+	if _, ok := funcs[name]; ok {
+		return fmt.Errorf("Function ‘%s’ is already defined", name)
+	}
+	if maxArity < 0 {
+		maxArity = variadic
+	}
+
+	funcs.register(name, function{arity: arity, maxArity: maxArity, exprFn: fn})
+	return nil
+}
+
+// Call calls a function on numbers, like a call in an expression. Functions
+// taking expressions as arguments can't be called.
+//
+// Example:
+//     p := mathcat.New()
+//     res, err := p.Call("max", big.NewRat(3, 1), big.NewRat(7, 2)) // 7/2
+func (p *Parser) Call(name string, args ...*big.Rat) (*big.Rat, error) {
+	tok := &Token{Type: Ident, Value: name}
+	function, ok := funcs[name]
+	if !ok {
+		return nil, fmt.Errorf("Undefined function ‘%s’", tok)
+	}
+
+	if err := p.checkCall(tok, function, len(args)); err != nil {
+		return nil, err
+	}
+	if function.exprFn != nil {
+		return nil, fmt.Errorf("‘%s’ takes expressions as arguments", tok)
+	}
+
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+
+	res, err := p.call(tok, function, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return scalar(res)
+}
+
 func (f functions) register(name string, function function) {
+	if function.maxArity < function.arity {
+		function.maxArity = function.arity
+	}
+
 	FunctionNames = append(FunctionNames, name)
 	f[name] = function
 }
 
+// bitWidth gets the optional bit width argument at index i, falling back to the
+// parser's configured bit width if it's absent
+func bitWidth(p *Parser, args []*big.Rat, i int) (uint, error) {
+	if i >= len(args) {
+		if p.BitWidth == 0 {
+			return DefaultBitWidth, nil
+		}
+		return p.BitWidth, nil
+	}
+
+	width := args[i].Num()
+	if width.Sign() <= 0 || width.Cmp(big.NewInt(maxBitWidth)) > 0 {
+		return 0, ErrInvalidBitWidth
+	}
+
+	return uint(width.Int64()), nil
+}
+
+// floatPrec returns the precision used for functions calculated with
+// big.Floats
+func floatPrec(p *Parser) uint {
+	if p.Precision == 0 {
+		return DefaultPrecision
+	}
+
+	return p.Precision
+}
+
+// floatFunc creates a function that is calculated with big.Floats at the
+// parser's precision
+func floatFunc(arity int, fn func(args []*big.Float, prec uint) (*big.Float, error)) function {
+	return function{
+		arity: arity,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			prec := floatPrec(p)
+			floatArgs := make([]*big.Float, len(args))
+			for i, arg := range args {
+				floatArgs[i] = ratFloat(arg, prec+guardBits)
+			}
+
+			res, err := fn(floatArgs, prec)
+			if err != nil {
+				return nil, err
+			}
+
+			return floatToRat(res)
+		},
+	}
+}
+
+// minResultExp is how far below its precision a result is flushed to 0, as
+// the exact rational of something like 2**-(10**9) has a denominator of a
+// billion bits
+const minResultExp = 1 << 12
+
+// floatToRat converts a big.Float result to a rational number
+func floatToRat(f *big.Float) (*big.Rat, error) {
+	if f.IsInf() {
+		return nil, ErrNotFinite
+	}
+	if f.MantExp(nil) < -int(f.Prec())-minResultExp {
+		return new(big.Rat), nil
+	}
+
+	res, _ := f.Rat(nil)
+	return res, nil
+}
+
+// registerDist registers the pdf, cdf and inv functions of a distribution,
+// which take the point x followed by the distribution's parameters. Parameters
+// after the required ones get their values from defaults.
+func (f functions) registerDist(prefix string, params int, defaults []int64, newDist func(params []*big.Float) (Distribution, error)) {
+	methods := map[string]func(d Distribution, x *big.Float, prec uint) (*big.Float, error){
+		"pdf": Distribution.PDF,
+		"cdf": Distribution.CDF,
+		"inv": Distribution.Quantile,
+	}
+
+	for _, suffix := range []string{"pdf", "cdf", "inv"} {
+		method := methods[suffix]
+		f.register(prefix+suffix, function{
+			arity:    params + 1,
+			maxArity: params + len(defaults) + 1,
+			fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+				prec := floatPrec(p)
+				floatArgs := make([]*big.Float, 0, params+len(defaults)+1)
+				for _, arg := range args {
+					floatArgs = append(floatArgs, ratFloat(arg, prec+guardBits))
+				}
+				for _, def := range defaults[len(args)-params-1:] {
+					floatArgs = append(floatArgs, newFloat(prec+guardBits, def))
+				}
+
+				dist, err := newDist(floatArgs[1:])
+				if err != nil {
+					return nil, err
+				}
+
+				res, err := method(dist, floatArgs[0], prec)
+				if err != nil {
+					return nil, err
+				}
+
+				return floatToRat(res)
+			},
+		})
+	}
+}
+
+// registerMatrix registers a function that only takes matrices
+func (f functions) registerMatrix(name string, arity int, fn func(p *Parser, args []*Matrix) (Value, error)) {
+	f.register(name, function{
+		arity: arity,
+		valueFn: func(p *Parser, args []Value) (Value, error) {
+			matrices := make([]*Matrix, len(args))
+			for i, arg := range args {
+				m, ok := arg.(*Matrix)
+				if !ok {
+					return nil, fmt.Errorf("Expecting matrices for ‘%s’", name)
+				}
+				matrices[i] = m
+			}
+
+			return fn(p, matrices)
+		},
+	})
+}
+
+// registerIntVector registers a function of integers that produces several
+// values, which are returned as a row vector
+func (f functions) registerIntVector(name string, arity, maxArity int, fn func(args []*big.Int) ([]*big.Int, error)) {
+	f.register(name, function{
+		arity:    arity,
+		maxArity: maxArity,
+		valueFn: func(_ *Parser, args []Value) (Value, error) {
+			ints := make([]*big.Int, len(args))
+			for i, arg := range args {
+				x, ok := arg.(*big.Rat)
+				if !ok {
+					return nil, fmt.Errorf("Expecting numbers for ‘%s’", name)
+				}
+				if !x.IsInt() {
+					return nil, fmt.Errorf("Expecting integers for ‘%s’", name)
+				}
+				ints[i] = x.Num()
+			}
+
+			values, err := fn(ints)
+			if err != nil {
+				return nil, err
+			}
+
+			res := NewMatrix(1, len(values))
+			for i, x := range values {
+				res.Data[i].SetInt(x)
+			}
+			return res, nil
+		},
+	})
+}
+
+// bitPos gets the bit position argument at index i
+func bitPos(args []*big.Rat, i int) (uint, error) {
+	pos := args[i].Num()
+	if pos.Sign() < 0 {
+		return 0, ErrNegativeBitPos
+	}
+	if pos.Cmp(big.NewInt(maxBitWidth)) >= 0 {
+		return 0, ErrInvalidBitWidth
+	}
+
+	return uint(pos.Int64()), nil
+}
+
 func init() {
 	funcs.register("abs", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return new(big.Rat).Abs(args[0])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return new(big.Rat).Abs(args[0]), nil
 		},
 	})
 	funcs.register("ceil", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Ceil(args[0])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Ceil(args[0]), nil
 		},
 	})
 	funcs.register("floor", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Floor(args[0])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Floor(args[0]), nil
 		},
 	})
 	funcs.register("sin", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Sin(float))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return angleSin(p.AngleUnit, args[0])
 		},
 	})
 	funcs.register("cos", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Cos(float))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return angleCos(p.AngleUnit, args[0])
 		},
 	})
 	funcs.register("tan", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Tan(float))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return angleTan(p.AngleUnit, args[0])
+		},
+	})
+	funcs.register("sec", function{
+		arity: 1,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return reciprocal(angleCos(p.AngleUnit, args[0]))
+		},
+	})
+	funcs.register("csc", function{
+		arity: 1,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return reciprocal(angleSin(p.AngleUnit, args[0]))
+		},
+	})
+	funcs.register("cot", function{
+		arity: 1,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return angleCot(p.AngleUnit, args[0])
 		},
 	})
 	funcs.register("asin", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
 			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Asin(float))
+			return p.AngleUnit.inverse(func() (*big.Rat, bool) {
+				return exactAsin(args[0])
+			}, math.Asin(float))
 		},
 	})
 	funcs.register("acos", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
 			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Acos(float))
+			return p.AngleUnit.inverse(func() (*big.Rat, bool) {
+				// acos(x) = 1/4 turn - asin(x)
+				asin, ok := exactAsin(args[0])
+				if !ok {
+					return nil, false
+				}
+				return asin.Sub(big.NewRat(1, 4), asin), true
+			}, math.Acos(float))
 		},
 	})
 	funcs.register("atan", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
 			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Atan(float))
+			return p.AngleUnit.inverse(func() (*big.Rat, bool) {
+				return exactAtan2(args[0], big.NewRat(1, 1))
+			}, math.Atan(float))
+		},
+	})
+	funcs.register("atan2", function{
+		arity: 2,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			y, _ := args[0].Float64()
+			x, _ := args[1].Float64()
+			return p.AngleUnit.inverse(func() (*big.Rat, bool) {
+				return exactAtan2(args[0], args[1])
+			}, math.Atan2(y, x))
+		},
+	})
+	funcs.register("hypot", function{
+		arity: 2,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			x, _ := args[0].Float64()
+			y, _ := args[1].Float64()
+			return floatResult(math.Hypot(x, y))
 		},
 	})
+	funcs.register("deg", function{
+		arity: 1,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res := new(big.Rat).Mul(args[0], big.NewRat(180, 1))
+			return res.Quo(res, defaultVariables["pi"]), nil
+		},
+	})
+	funcs.register("rad", function{
+		arity: 1,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res := new(big.Rat).Mul(args[0], defaultVariables["pi"])
+			return res.Quo(res, big.NewRat(180, 1)), nil
+		},
+	})
+	hyperbolic := []struct {
+		name string
+		fn   func(float64) float64
+	}{
+		{"sinh", math.Sinh}, {"cosh", math.Cosh}, {"tanh", math.Tanh},
+		{"asinh", math.Asinh}, {"acosh", math.Acosh}, {"atanh", math.Atanh},
+	}
+	for _, h := range hyperbolic {
+		fn := h.fn
+		funcs.register(h.name, function{
+			arity: 1,
+			fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+				float, _ := args[0].Float64()
+				return floatResult(fn(float))
+			},
+		})
+	}
 	funcs.register("ln", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
 			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Log(float))
+			return floatResult(math.Log(float))
 		},
 	})
 	funcs.register("log", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
 			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Log10(float))
+			return floatResult(math.Log10(float))
 		},
 	})
 	funcs.register("logn", function{
 		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
 			base, _ := args[0].Float64()
 			arg, _ := args[1].Float64()
-			return new(big.Rat).SetFloat64(math.Log10(arg) / math.Log10(base))
+			return floatResult(math.Log10(arg) / math.Log10(base))
 		},
 	})
 	funcs.register("max", function{
 		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Max(args[0], args[1])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Max(args[0], args[1]), nil
 		},
 	})
 	funcs.register("min", function{
 		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Min(args[0], args[1])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Min(args[0], args[1]), nil
 		},
 	})
 	funcs.register("sqrt", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
 			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Sqrt(float))
+			return floatResult(math.Sqrt(float))
 		},
 	})
 	funcs.register("rand", function{
-		arity: 0,
-		fn: func(_ []*big.Rat) *big.Rat {
-			return new(big.Rat).SetFloat64(rand.Float64())
+		arity:  0,
+		random: true,
+		fn: func(p *Parser, _ []*big.Rat) (*big.Rat, error) {
+			return new(big.Rat).SetFloat64(p.random().Float64()), nil
+		},
+	})
+	funcs.register("randnorm", function{
+		arity:    0,
+		maxArity: 2,
+		random:   true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			mu, sigma := new(big.Rat), big.NewRat(1, 1)
+			if len(args) > 0 {
+				mu = args[0]
+			}
+			if len(args) > 1 {
+				sigma = args[1]
+			}
+			if sigma.Sign() <= 0 {
+				return nil, ErrInvalidParam
+			}
+
+			res := new(big.Rat).SetFloat64(p.random().NormFloat64())
+			res.Mul(res, sigma)
+			return res.Add(res, mu), nil
+		},
+	})
+	funcs.register("randint", function{
+		arity:   2,
+		integer: true,
+		random:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			// Random integer in [a, b]
+			stop := new(big.Int).Add(args[1].Num(), bigOne)
+			res, err := randomRange(p.random(), args[0].Num(), stop, bigOne)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("randrange", function{
+		arity:    1,
+		maxArity: 3,
+		integer:  true,
+		random:   true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			// Like Python, randrange(stop) or randrange(start, stop[, step])
+			start, stop, step := new(big.Int), args[0].Num(), bigOne
+			if len(args) > 1 {
+				start, stop = args[0].Num(), args[1].Num()
+			}
+			if len(args) > 2 {
+				step = args[2].Num()
+			}
+
+			res, err := randomRange(p.random(), start, stop, step)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("choice", function{
+		arity:    1,
+		maxArity: variadic,
+		random:   true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return args[p.random().Intn(len(args))], nil
 		},
 	})
+	funcs.register("seed", function{
+		arity:   1,
+		integer: true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			p.Seed(seedValue(args[0].Num()))
+			return args[0], nil
+		},
+	})
+
+	funcs.registerDist("norm", 0, []int64{0, 1}, func(params []*big.Float) (Distribution, error) {
+		return NewNormal(params[0], params[1])
+	})
+	funcs.registerDist("t", 1, nil, func(params []*big.Float) (Distribution, error) {
+		return NewStudentT(params[0])
+	})
+	funcs.registerDist("chi2", 1, nil, func(params []*big.Float) (Distribution, error) {
+		return NewChiSquared(params[0])
+	})
+	funcs.registerDist("f", 2, nil, func(params []*big.Float) (Distribution, error) {
+		return NewF(params[0], params[1])
+	})
+	funcs.registerDist("binom", 2, nil, func(params []*big.Float) (Distribution, error) {
+		n, isInt := count(params[0])
+		if !isInt {
+			return nil, ErrInvalidParam
+		}
+		return NewBinomialDist(n, params[1])
+	})
+	funcs.registerDist("poiss", 1, nil, func(params []*big.Float) (Distribution, error) {
+		return NewPoisson(params[0])
+	})
+	funcs.registerDist("exp", 1, nil, func(params []*big.Float) (Distribution, error) {
+		return NewExponential(params[0])
+	})
+	funcs.registerDist("unif", 2, nil, func(params []*big.Float) (Distribution, error) {
+		return NewUniform(params[0], params[1])
+	})
 	funcs.register("fact", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Factorial(args[0])
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if args[0].IsInt() {
+				fact, err := factorialInt(args[0].Num())
+				if err != nil {
+					return nil, err
+				}
+				return new(big.Rat).SetInt(fact), nil
+			}
+
+			// fact(x) = gamma(x + 1) for non-integers
+			prec := floatPrec(p)
+			x := ratFloat(args[0], prec+guardBits)
+			res, err := Gamma(x.Add(x, newFloat(prec+guardBits, 1)), prec)
+			if err != nil {
+				return nil, err
+			}
+			return floatToRat(res)
 		},
 	})
+	funcs.register("gamma", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Gamma(args[0], prec)
+	}))
+	funcs.register("lgamma", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Lgamma(args[0], prec)
+	}))
+	funcs.register("beta", floatFunc(2, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Beta(args[0], args[1], prec)
+	}))
+	funcs.register("gammainc", floatFunc(2, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return GammaInc(args[0], args[1], prec)
+	}))
+	funcs.register("gammaincc", floatFunc(2, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return GammaIncComp(args[0], args[1], prec)
+	}))
+	funcs.register("betainc", floatFunc(3, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return BetaInc(args[0], args[1], args[2], prec)
+	}))
+	funcs.register("erf", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Erf(args[0], prec)
+	}))
+	funcs.register("erfc", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Erfc(args[0], prec)
+	}))
+	funcs.register("erfinv", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Erfinv(args[0], prec)
+	}))
+	funcs.register("zeta", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Zeta(args[0], prec)
+	}))
+	funcs.register("digamma", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return Digamma(args[0], prec)
+	}))
+	funcs.register("j0", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return BesselJ(0, args[0], prec)
+	}))
+	funcs.register("j1", floatFunc(1, func(args []*big.Float, prec uint) (*big.Float, error) {
+		return BesselJ(1, args[0], prec)
+	}))
 	funcs.register("gcd", function{
 		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Gcd(args[0], args[1])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Gcd(args[0], args[1]), nil
+		},
+	})
+	funcs.register("lcm", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return new(big.Rat).SetInt(Lcm(args[0].Num(), args[1].Num())), nil
 		},
 	})
+	funcs.register("isprime", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return boolToRat(IsPrime(args[0].Num())), nil
+		},
+	})
+	funcs.register("nextprime", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return new(big.Rat).SetInt(NextPrime(args[0].Num())), nil
+		},
+	})
+	funcs.register("prevprime", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			prime, err := PrevPrime(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(prime), nil
+		},
+	})
+	funcs.registerIntVector("factor", 1, 1, func(args []*big.Int) ([]*big.Int, error) {
+		if args[0].CmpAbs(bigTwo) < 0 {
+			return nil, ErrNoPrimeFactors
+		}
+		return Factor(args[0])
+	})
+	funcs.register("totient", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			totient, err := Totient(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(totient), nil
+		},
+	})
+	funcs.register("modpow", function{
+		arity:   3,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := ModPow(args[0].Num(), args[1].Num(), args[2].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("modinv", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := ModInverse(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.registerIntVector("egcd", 2, 2, func(args []*big.Int) ([]*big.Int, error) {
+		g, x, y := ExtendedGcd(args[0], args[1])
+		return []*big.Int{g, x, y}, nil
+	})
+	funcs.register("jacobi", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Jacobi(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return big.NewRat(int64(res), 1), nil
+		},
+	})
+	funcs.register("isqrt", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			if args[0].Sign() < 0 {
+				return nil, ErrNegativeRoot
+			}
+			return new(big.Rat).SetInt(Isqrt(args[0].Num())), nil
+		},
+	})
+	funcs.register("iroot", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			k := args[1].Num()
+			if k.Sign() <= 0 {
+				return nil, ErrInvalidRootDeg
+			}
+			if !k.IsInt64() || k.Int64() > maxBitWidth {
+				return nil, ErrRootDegTooLarge
+			}
+			res, err := Iroot(args[0].Num(), int(k.Int64()))
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.registerIntVector("digits", 1, 2, func(args []*big.Int) ([]*big.Int, error) {
+		base := big.NewInt(10)
+		if len(args) > 1 {
+			base = args[1]
+		}
+		return Digits(args[0], base)
+	})
+	funcs.register("ncr", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Binomial(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("binomial", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Binomial(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("npr", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Permutations(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("multinomial", function{
+		arity:    1,
+		maxArity: variadic,
+		integer:  true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			ks := make([]*big.Int, len(args))
+			for i, arg := range args {
+				ks[i] = arg.Num()
+			}
+			res, err := Multinomial(ks...)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("catalan", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Catalan(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("fib", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Fibonacci(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("lucas", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Lucas(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("stirling1", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Stirling1(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("stirling2", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Stirling2(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("bell", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Bell(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("partitions", function{
+		arity:   1,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := Partitions(args[0].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("rising", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := RisingFactorial(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("falling", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			res, err := FallingFactorial(args[0].Num(), args[1].Num())
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(res), nil
+		},
+	})
+	funcs.register("popcount", function{
+		arity:    1,
+		maxArity: 2,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return big.NewRat(int64(PopCount(args[0].Num(), width)), 1), nil
+		},
+	})
+	funcs.register("parity", function{
+		arity:    1,
+		maxArity: 2,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return big.NewRat(int64(Parity(args[0].Num(), width)), 1), nil
+		},
+	})
+	funcs.register("clz", function{
+		arity:    1,
+		maxArity: 2,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return big.NewRat(int64(LeadingZeros(args[0].Num(), width)), 1), nil
+		},
+	})
+	funcs.register("ctz", function{
+		arity:    1,
+		maxArity: 2,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return big.NewRat(int64(TrailingZeros(args[0].Num(), width)), 1), nil
+		},
+	})
+	funcs.register("rotl", function{
+		arity:    2,
+		maxArity: 3,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 2)
+			if err != nil {
+				return nil, err
+			}
+			n := new(big.Int).Rem(args[1].Num(), big.NewInt(int64(width))).Int64()
+			return new(big.Rat).SetInt(RotateLeft(args[0].Num(), n, width)), nil
+		},
+	})
+	funcs.register("rotr", function{
+		arity:    2,
+		maxArity: 3,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 2)
+			if err != nil {
+				return nil, err
+			}
+			n := new(big.Int).Rem(args[1].Num(), big.NewInt(int64(width))).Int64()
+			return new(big.Rat).SetInt(RotateRight(args[0].Num(), n, width)), nil
+		},
+	})
+	funcs.register("reverse_bits", function{
+		arity:    1,
+		maxArity: 2,
+		integer:  true,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			width, err := bitWidth(p, args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(ReverseBits(args[0].Num(), width)), nil
+		},
+	})
+	for _, width := range []uint{16, 32, 64} {
+		width := width
+		funcs.register(fmt.Sprintf("bswap%d", width), function{
+			arity:   1,
+			integer: true,
+			fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+				return new(big.Rat).SetInt(ReverseBytes(args[0].Num(), width)), nil
+			},
+		})
+	}
+	funcs.register("bit", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			pos, err := bitPos(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return big.NewRat(int64(args[0].Num().Bit(int(pos))), 1), nil
+		},
+	})
+	funcs.register("setbit", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			pos, err := bitPos(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(new(big.Int).SetBit(args[0].Num(), int(pos), 1)), nil
+		},
+	})
+	funcs.register("clearbit", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			pos, err := bitPos(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(new(big.Int).SetBit(args[0].Num(), int(pos), 0)), nil
+		},
+	})
+	funcs.register("togglebit", function{
+		arity:   2,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			pos, err := bitPos(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			x := args[0].Num()
+			return new(big.Rat).SetInt(new(big.Int).SetBit(x, int(pos), x.Bit(int(pos))^1)), nil
+		},
+	})
+	funcs.register("bits", function{
+		arity:   3,
+		integer: true,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			hi, err := bitPos(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			lo, err := bitPos(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			return new(big.Rat).SetInt(BitField(args[0].Num(), hi, lo)), nil
+		},
+	})
+	for _, width := range []int64{16, 32, 64} {
+		format := floatFormats[width]
+		funcs.register(fmt.Sprintf("f%dbits", width), function{
+			arity: 1,
+			fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+				bits, _ := format.Encode(args[0])
+				return new(big.Rat).SetInt(new(big.Int).SetUint64(bits)), nil
+			},
+		})
+		funcs.register(fmt.Sprintf("f%dfrombits", width), function{
+			arity:   1,
+			integer: true,
+			fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+				bits, err := floatBits(format, args, 0)
+				if err != nil {
+					return nil, err
+				}
+				return format.Decode(bits)
+			},
+		})
+		funcs.register(fmt.Sprintf("isf%d", width), function{
+			arity: 1,
+			fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+				_, exact := format.Encode(args[0])
+				return boolToRat(exact), nil
+			},
+		})
+	}
+	funcs.register("fsign", function{
+		arity:    1,
+		maxArity: 2,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			format, err := floatFormat(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			bits, _ := format.Encode(args[0])
+			sign, _, _ := format.Fields(bits)
+			return new(big.Rat).SetInt(new(big.Int).SetUint64(sign)), nil
+		},
+	})
+	funcs.register("fexponent", function{
+		arity:    1,
+		maxArity: 2,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			format, err := floatFormat(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			bits, _ := format.Encode(args[0])
+			return big.NewRat(int64(format.Exponent(bits)), 1), nil
+		},
+	})
+	funcs.register("fmantissa", function{
+		arity:    1,
+		maxArity: 2,
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			format, err := floatFormat(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			bits, _ := format.Encode(args[0])
+			_, _, mant := format.Fields(bits)
+			return new(big.Rat).SetInt(new(big.Int).SetUint64(mant)), nil
+		},
+	})
+	funcs.registerMatrix("det", 1, func(_ *Parser, args []*Matrix) (Value, error) {
+		return args[0].Det()
+	})
+	funcs.registerMatrix("inv", 1, func(_ *Parser, args []*Matrix) (Value, error) {
+		return args[0].Inverse()
+	})
+	funcs.registerMatrix("transpose", 1, func(_ *Parser, args []*Matrix) (Value, error) {
+		return args[0].Transpose(), nil
+	})
+	funcs.registerMatrix("rank", 1, func(_ *Parser, args []*Matrix) (Value, error) {
+		return big.NewRat(int64(args[0].Rank()), 1), nil
+	})
+	funcs.registerMatrix("rref", 1, func(_ *Parser, args []*Matrix) (Value, error) {
+		return args[0].Rref(), nil
+	})
+	funcs.registerMatrix("dot", 2, func(_ *Parser, args []*Matrix) (Value, error) {
+		return args[0].Dot(args[1])
+	})
+	funcs.registerMatrix("cross", 2, func(_ *Parser, args []*Matrix) (Value, error) {
+		return args[0].Cross(args[1])
+	})
+	funcs.registerMatrix("norm", 1, func(p *Parser, args []*Matrix) (Value, error) {
+		return ratSqrt(args[0].SquaredNorm(), floatPrec(p)), nil
+	})
+	funcs.registerMatrix("linsolve", 2, func(_ *Parser, args []*Matrix) (Value, error) {
+		return linsolve(args[0], args[1])
+	})
+	funcs.register("solve", function{
+		arity:    1,
+		maxArity: variadic,
+		exprFn:   solve,
+	})
+	funcs.register("integrate", function{
+		arity:  4,
+		exprFn: integrate,
+	})
+	funcs.register("nderiv", function{
+		arity:  3,
+		exprFn: nderiv,
+	})
+	funcs.register("sum", function{
+		arity:  4,
+		exprFn: sum,
+	})
+	funcs.register("prod", function{
+		arity:  4,
+		exprFn: prod,
+	})
+	funcs.register("minimize", function{
+		arity:    2,
+		maxArity: variadic,
+		exprFn:   minimize,
+	})
+	funcs.register("maximize", function{
+		arity:    2,
+		maxArity: variadic,
+		exprFn:   maximize,
+	})
+	funcs.register("argmin", function{
+		arity:    2,
+		maxArity: variadic,
+		exprFn:   argmin,
+	})
+	funcs.register("argmax", function{
+		arity:    2,
+		maxArity: variadic,
+		exprFn:   argmax,
+	})
+	funcs.register("ode", function{
+		arity:  6,
+		exprFn: ode,
+	})
+	funcs.register("odetable", function{
+		arity:    6,
+		maxArity: 7,
+		exprFn:   odetable,
+	})
+	funcs.register("diff", function{
+		arity:  3,
+		exprFn: diff,
+	})
+	funcs.register("expand", function{
+		arity:  1,
+		exprFn: expand,
+	})
+	funcs.register("pfactor", function{
+		arity:  1,
+		exprFn: pfactor,
+	})
+	funcs.register("coeffs", function{
+		arity:  2,
+		exprFn: coeffs,
+	})
+	funcs.register("roots", function{
+		arity:  2,
+		exprFn: roots,
+	})
 	funcs.register("list", function{
 		arity: 0,
-		fn: func(_ []*big.Rat) *big.Rat {
+		fn: func(_ *Parser, _ []*big.Rat) (*big.Rat, error) {
 			for _, name := range FunctionNames {
 				fmt.Print(name + " ")
 			}
 			fmt.Println()
-			return RatTrue
+			return RatTrue, nil
 		},
 	})
 }
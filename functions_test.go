@@ -9,6 +9,22 @@ import (
 	"testing"
 )
 
+// closeTo reports whether res is within a relative tolerance of the decimal
+// string expected
+func closeTo(res *big.Rat, expected string, tolerance float64) bool {
+	exp, _ := new(big.Rat).SetString(expected)
+	diff := new(big.Rat).Sub(res, exp)
+	diff.Abs(diff)
+
+	bound := new(big.Rat).Abs(exp)
+	if bound.Cmp(big.NewRat(1, 1)) < 0 {
+		bound.SetInt64(1)
+	}
+	bound.Mul(bound, new(big.Rat).SetFloat64(tolerance))
+
+	return diff.Cmp(bound) <= 0
+}
+
 func TestFunctions(t *testing.T) {
 	badCalls := []string{
 		"a()", "a(1, 2, 3)", "2 + 6 * (a(1, 2))", "abs(1, 2)", "abs()",
@@ -37,28 +53,28 @@ func TestFunctions(t *testing.T) {
 
 func TestFunctionsResult(t *testing.T) {
 	calls := map[string]*big.Rat{
-		"abs(-700)":                         big.NewRat(700, 1),
-		"ceil(813.23)":                      big.NewRat(814, 1),
-		"ceil(ceil(10 ** 16 + 0.1))":        big.NewRat(10000000000000001, 1),
-		"floor(813.23)":                     big.NewRat(813, 1),
-		"floor(-50.23)":                     big.NewRat(-51, 1),
-		"floor(-50)":                        big.NewRat(-50, 1),
-		"sin(74)":                           big.NewRat(-8873408663100473, 9007199254740992),
-		"cos(74)":                           big.NewRat(6186769253457135, 36028797018963968),
-		"tan(74)":                           big.NewRat(-6459313142528259, 1125899906842624),
-		"asin(-1)":                          big.NewRat(-884279719003555, 562949953421312),
-		"acos(-1)":                          big.NewRat(884279719003555, 281474976710656),
-		"atan(-1)":                          big.NewRat(-884279719003555, 1125899906842624),
-		"ln(3*100)":                         big.NewRat(802736019608251, 140737488355328),
-		"log(50)":                           big.NewRat(59777192800323, 35184372088832),
-		"logn(2, 50)":                       big.NewRat(6354417158300529, 1125899906842624),
-		"max(5, 8)":                         big.NewRat(8, 1),
-		"min(5, 8)":                         big.NewRat(5, 1),
-		"sqrt(144)":                         big.NewRat(12, 1),
-		"tan(144) + tan(-3) + sin(5)":       big.NewRat(-49720712606960177, 36028797018963968),
-		"fact(6) * fact(7) == fact(10)":     big.NewRat(1, 1),
-		"fact(6.5) * fact(7.3) == fact(10)": big.NewRat(1, 1),
-		"list()": big.NewRat(1, 1),
+		"abs(-700)":                     big.NewRat(700, 1),
+		"ceil(813.23)":                  big.NewRat(814, 1),
+		"ceil(ceil(10 ** 16 + 0.1))":    big.NewRat(10000000000000001, 1),
+		"floor(813.23)":                 big.NewRat(813, 1),
+		"floor(-50.23)":                 big.NewRat(-51, 1),
+		"floor(-50)":                    big.NewRat(-50, 1),
+		"sin(74)":                       big.NewRat(-8873408663100473, 9007199254740992),
+		"cos(74)":                       big.NewRat(6186769253457135, 36028797018963968),
+		"tan(74)":                       big.NewRat(-6459313142528259, 1125899906842624),
+		"asin(-1)":                      big.NewRat(-884279719003555, 562949953421312),
+		"acos(-1)":                      big.NewRat(884279719003555, 281474976710656),
+		"atan(-1)":                      big.NewRat(-884279719003555, 1125899906842624),
+		"ln(3*100)":                     big.NewRat(802736019608251, 140737488355328),
+		"log(50)":                       big.NewRat(59777192800323, 35184372088832),
+		"logn(2, 50)":                   big.NewRat(6354417158300529, 1125899906842624),
+		"max(5, 8)":                     big.NewRat(8, 1),
+		"min(5, 8)":                     big.NewRat(5, 1),
+		"sqrt(144)":                     big.NewRat(12, 1),
+		"tan(144) + tan(-3) + sin(5)":   big.NewRat(-49720712606960177, 36028797018963968),
+		"fact(6) * fact(7) == fact(10)": big.NewRat(1, 1),
+		"fact(4.5) == gamma(5.5)":       big.NewRat(1, 1),
+		"list()":                        big.NewRat(1, 1),
 	}
 
 	for expr, expected := range calls {
@@ -73,3 +89,680 @@ func TestFunctionsResult(t *testing.T) {
 		}
 	}
 }
+
+func TestTrigFunctions(t *testing.T) {
+	calls := map[string]string{
+		"sinh(1)":             "1.1752011936438014569",
+		"cosh(-2)":            "3.7621956910836314596",
+		"tanh(0.5)":           "0.46211715726000975850",
+		"asinh(1)":            "0.88137358701954302523",
+		"acosh(2)":            "1.3169578969248167086",
+		"atanh(0.5)":          "0.54930614433405484570",
+		"sec(1)":              "1.8508157176809256179",
+		"csc(1)":              "1.1883951057781212163",
+		"cot(1)":              "0.64209261593433070301",
+		"atan2(1, -1)":        "2.3561944901923449288",
+		"atan2(-2, 0)":        "-1.5707963267948966192",
+		"hypot(3, 4)":         "5",
+		"hypot(1e200, 1e200)": "1.4142135623730950488e200",
+		"deg(pi / 2)":         "90",
+		"rad(deg(1.5))":       "1.5",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if !closeTo(res, expected, 1e-15) {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res.FloatString(20))
+		}
+	}
+
+	// Angles with a rational result should be exact in degrees and gradians
+	exact := map[AngleUnit]map[string]*big.Rat{
+		Degrees: {
+			"sin(30)":         big.NewRat(1, 2),
+			"sin(-390)":       big.NewRat(-1, 2),
+			"cos(120)":        big.NewRat(-1, 2),
+			"cos(360*10**30)": big.NewRat(1, 1),
+			"tan(135)":        big.NewRat(-1, 1),
+			"sec(60)":         big.NewRat(2, 1),
+			"csc(-90)":        big.NewRat(-1, 1),
+			"cot(45)":         big.NewRat(1, 1),
+			"cot(90)":         big.NewRat(0, 1),
+			"asin(0.5)":       big.NewRat(30, 1),
+			"acos(-0.5)":      big.NewRat(120, 1),
+			"atan(-1)":        big.NewRat(-45, 1),
+			"atan2(-1, -1)":   big.NewRat(-135, 1),
+			"atan2(0, -3)":    big.NewRat(180, 1),
+		},
+		Gradians: {
+			"sin(100)":    big.NewRat(1, 1),
+			"cos(200)":    big.NewRat(-1, 1),
+			"atan(1)":     big.NewRat(50, 1),
+			"asin(-1)":    big.NewRat(-100, 1),
+			"atan2(5, 0)": big.NewRat(100, 1),
+		},
+	}
+
+	for unit, calls := range exact {
+		p := New()
+		p.AngleUnit = unit
+		for expr, expected := range calls {
+			res, err := p.Run(expr)
+			if err != nil {
+				t.Errorf("unexpected error on ok function call '%s' in %s: %s", expr, unit, err)
+				continue
+			}
+
+			if res.Cmp(expected) != 0 {
+				t.Errorf("wrong result in function call '%s' in %s (expected %s, got %s)",
+					expr, unit, expected.RatString(), res.RatString())
+			}
+		}
+	}
+
+	p := New()
+	p.AngleUnit = Degrees
+	res, err := p.Run("sin(40)")
+	if err != nil {
+		t.Fatalf("unexpected error on ok function call 'sin(40)' in deg: %s", err)
+	}
+	if !closeTo(res, "0.64278760968653932632", 1e-15) {
+		t.Errorf("wrong result in function call 'sin(40)' in deg (got %s)", res.FloatString(20))
+	}
+
+	badCalls := []string{
+		"asin(2)", "acos(-1.5)", "acosh(0.5)", "atanh(1)", "atanh(-3)",
+		"csc(0)", "cot(0)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad trigonometric function call '%s'", expr)
+		}
+	}
+
+	for _, expr := range []string{"tan(90)", "sec(270)", "csc(180)", "cot(0)"} {
+		if _, err := p.Run(expr); err == nil {
+			t.Errorf("expected error on bad trigonometric function call '%s' in deg", expr)
+		}
+	}
+
+	if _, err := ParseAngleUnit("turns"); err == nil {
+		t.Error("expected error on parsing invalid angle unit 'turns'")
+	}
+}
+
+func TestBitFunctions(t *testing.T) {
+	calls := map[string]*big.Rat{
+		"popcount(0xff)":                big.NewRat(8, 1),
+		"popcount(-1)":                  big.NewRat(64, 1),
+		"popcount(-1, 8)":               big.NewRat(8, 1),
+		"parity(0b1011)":                big.NewRat(1, 1),
+		"clz(1)":                        big.NewRat(63, 1),
+		"clz(1, 32)":                    big.NewRat(31, 1),
+		"ctz(0b1000)":                   big.NewRat(3, 1),
+		"ctz(0, 16)":                    big.NewRat(16, 1),
+		"rotl(0x80000001, 1, 32)":       big.NewRat(3, 1),
+		"rotr(1, 1, 8)":                 big.NewRat(0x80, 1),
+		"rotr(1, -1, 8)":                big.NewRat(2, 1),
+		"bswap16(0x1234)":               big.NewRat(0x3412, 1),
+		"bswap32(0x12345678)":           big.NewRat(0x78563412, 1),
+		"bswap64(0x0102030405060708)":   big.NewRat(0x0807060504030201, 1),
+		"bit(0b100, 2)":                 big.NewRat(1, 1),
+		"setbit(0, 4)":                  big.NewRat(16, 1),
+		"clearbit(0xff, 0)":             big.NewRat(0xfe, 1),
+		"togglebit(togglebit(5, 1), 1)": big.NewRat(5, 1),
+		"bits(0xabcd, 11, 4)":           big.NewRat(0xbc, 1),
+		"reverse_bits(1, 8)":            big.NewRat(0x80, 1),
+		"reverse_bits(0b110, 4)":        big.NewRat(6, 1),
+		"setbit(0, 100) == 2**100":      RatTrue,
+		"bits(2**100 + 2**99, 100, 99)": big.NewRat(3, 1),
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	badCalls := []string{
+		"popcount(1.5)", "clz(1, 0)", "clz(1, -8)", "rotl(1, 0.5)",
+		"bit(1, -1)", "bits(1.1, 2, 1)", "bswap32(3, 2)", "rotl(1)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad bit function call '%s'", expr)
+		}
+	}
+
+	p := New()
+	p.BitWidth = 8
+	if res, err := p.Run("clz(1)"); err != nil || res.Cmp(big.NewRat(7, 1)) != 0 {
+		t.Errorf("configured bit width not used (got %s, %v)", res, err)
+	}
+}
+
+func TestFloatFunctions(t *testing.T) {
+	calls := map[string]*big.Rat{
+		"f32frombits(0x3FC00000)":   big.NewRat(3, 2),
+		"f64bits(1.5)":              new(big.Rat).SetInt64(0x3FF8000000000000),
+		"f32bits(-2)":               big.NewRat(0xC0000000, 1),
+		"f16bits(1)":                big.NewRat(0x3C00, 1),
+		"f16bits(65504)":            big.NewRat(0x7BFF, 1),
+		"f16bits(65520)":            big.NewRat(0x7C00, 1),
+		"f16frombits(1)":            big.NewRat(1, 16777216),
+		"f16frombits(0x3555)":       big.NewRat(1365, 4096),
+		"f64frombits(f64bits(0.1))": new(big.Rat).SetFloat64(0.1),
+		"f32bits(0.1)":              big.NewRat(0x3DCCCCCD, 1),
+		"f32bits(1 + 2**-24)":       big.NewRat(0x3F800000, 1),
+		"f32bits(1 + 3 * 2**-24)":   big.NewRat(0x3F800002, 1),
+		"f64bits(2**-1074)":         big.NewRat(1, 1),
+		"isf32(0.1)":                RatFalse,
+		"isf32(0.5)":                RatTrue,
+		"isf64(2**53 + 1)":          RatFalse,
+		"isf64(2**53)":              RatTrue,
+		"isf16(2**16)":              RatFalse,
+		"fsign(-3)":                 big.NewRat(1, 1),
+		"fexponent(1.5, 32)":        RatZero,
+		"fexponent(0.001)":          big.NewRat(-10, 1),
+		"fmantissa(1.5, 32)":        big.NewRat(0x400000, 1),
+		"fmantissa(1.5, 16)":        big.NewRat(0x200, 1),
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	badCalls := []string{
+		"f32frombits(0x7F800000)", "f16frombits(0x7E00)", "f32frombits(2**32)",
+		"f64frombits(-1)", "f32frombits(1.5)", "fsign(1, 8)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad float function call '%s'", expr)
+		}
+	}
+}
+
+func TestNumberTheoryFunctions(t *testing.T) {
+	calls := map[string]*big.Rat{
+		"lcm(4, 6)":                        big.NewRat(12, 1),
+		"lcm(-4, 6)":                       big.NewRat(12, 1),
+		"lcm(0, 6)":                        RatZero,
+		"isprime(2**127 - 1)":              RatTrue,
+		"isprime(2**128 + 1)":              RatFalse,
+		"isprime(1)":                       RatFalse,
+		"nextprime(13)":                    big.NewRat(17, 1),
+		"nextprime(-5)":                    big.NewRat(2, 1),
+		"prevprime(13)":                    big.NewRat(11, 1),
+		"prevprime(3)":                     big.NewRat(2, 1),
+		"totient(36)":                      big.NewRat(12, 1),
+		"totient(97)":                      big.NewRat(96, 1),
+		"modpow(4, 13, 497)":               big.NewRat(445, 1),
+		"modpow(3, -1, 7)":                 big.NewRat(5, 1),
+		"modpow(2, 10**20, 10**9 + 7)":     big.NewRat(855473248, 1),
+		"modinv(3, 11)":                    big.NewRat(4, 1),
+		"jacobi(1001, 9907)":               big.NewRat(-1, 1),
+		"isqrt(10**40 + 1) == 10**20":      RatTrue,
+		"isqrt(15)":                        big.NewRat(3, 1),
+		"iroot(10**30, 3) == 10**10":       RatTrue,
+		"iroot(10**30 - 1, 3) == 10**10-1": RatTrue,
+		"iroot(-27, 3)":                    big.NewRat(-3, 1),
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	// Functions with several results give a row vector
+	vectors := map[string]string{
+		"factor(360)":                      "[2, 2, 2, 3, 3, 5]",
+		"factor(-12)":                      "[2, 2, 3]",
+		"factor(2**64 + 1)":                "[274177, 67280421310721]",
+		"factor(1000003 * 1000033)":        "[1000003, 1000033]",
+		"egcd(240, 46)":                    "[2, -9, 47]",
+		"digits(12345)":                    "[1, 2, 3, 4, 5]",
+		"digits(255, 16)":                  "[15, 15]",
+		"digits(0)":                        "[0]",
+		"factor(360) * [1; 1; 1; 1; 1; 1]": "[17]",
+	}
+
+	for expr, expected := range vectors {
+		res, err := EvalValue(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	badCalls := []string{
+		"lcm(1.5, 2)", "isprime(7.5)", "prevprime(2)", "factor(12, 4)",
+		"factor(12.5)", "totient(0)", "modpow(2, 3, 0)", "modinv(2, 4)",
+		"jacobi(3, 8)", "isqrt(-1)", "iroot(-8, 2)", "iroot(8, 0)",
+		"digits(10, 1)", "modpow(2, 3.5, 7)", "factor(0)", "factor(-1)",
+		"factor(1)", "egcd(4, 6, 2)", "egcd([1, 2], 3)", "digits(255, 16, 1)",
+		"factor(12) + 1",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad number theory call '%s'", expr)
+		}
+	}
+
+	if _, err := Eval("iroot(8, 2**20)"); err != ErrRootDegTooLarge {
+		t.Errorf("expected ErrRootDegTooLarge, got %v", err)
+	}
+}
+
+func TestCombinatoricsFunctions(t *testing.T) {
+	calls := map[string]*big.Rat{
+		"ncr(10, 3)":      big.NewRat(120, 1),
+		"binomial(10, 7)": big.NewRat(120, 1),
+		"ncr(5, 6)":       RatZero,
+		"ncr(-4, 3)":      big.NewRat(-20, 1),
+		"ncr(100, 50)":    new(big.Rat).SetFrac(new(big.Int).Binomial(100, 50), big.NewInt(1)),
+		"ncr(10**30, 2) == (10**30 * (10**30 - 1)) / 2": RatTrue,
+		"npr(10, 3)":           big.NewRat(720, 1),
+		"npr(3, 5)":            RatZero,
+		"multinomial(2, 3, 4)": big.NewRat(1260, 1),
+		"multinomial(5)":       big.NewRat(1, 1),
+		"catalan(10)":          big.NewRat(16796, 1),
+		"fib(0)":               RatZero,
+		"fib(10)":              big.NewRat(55, 1),
+		"fib(-10)":             big.NewRat(-55, 1),
+		"fib(90)":              big.NewRat(2880067194370816120, 1),
+		"lucas(10)":            big.NewRat(123, 1),
+		"lucas(-5)":            big.NewRat(-11, 1),
+		"stirling1(5, 2)":      big.NewRat(-50, 1),
+		"stirling1(0, 0)":      big.NewRat(1, 1),
+		"stirling2(5, 2)":      big.NewRat(15, 1),
+		"stirling2(10, 0)":     RatZero,
+		"bell(10)":             big.NewRat(115975, 1),
+		"bell(0)":              big.NewRat(1, 1),
+		"partitions(100)":      big.NewRat(190569292, 1),
+		"partitions(0)":        big.NewRat(1, 1),
+		"rising(3, 4)":         big.NewRat(360, 1),
+		"falling(10, 3)":       big.NewRat(720, 1),
+		"falling(-2, 2)":       big.NewRat(6, 1),
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	badCalls := []string{
+		"ncr(10**9, 5*10**8)", "npr(10**9, 5*10**8)", "fib(10**12)",
+		"bell(10**6)", "partitions(10**9)", "stirling2(10**6, 10**5)",
+		"ncr(2.5, 1)", "multinomial()", "multinomial(2, -1)", "npr(-1, 2)",
+		"rising(2, 10**9)", "stirling1(300000, 1)", "stirling1(10**6, 1)",
+		"stirling2(10**6, 3)", "stirling1(20000, 200)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad combinatorics call '%s'", expr)
+		}
+	}
+}
+
+func TestSpecialFunctions(t *testing.T) {
+	calls := map[string]string{
+		"gamma(0.5)":         "1.7724538509055160272981674833411",
+		"gamma(-0.5)":        "-3.5449077018110320545963349666823",
+		"gamma(5)":           "24",
+		"gamma(100.5)":       "9.3209631040827166e156",
+		"fact(0.5)":          "0.88622692545275801364908374167057",
+		"fact(4.5)":          "52.342777784553520181149008492418",
+		"lgamma(100)":        "359.13420536957539877604401046028",
+		"lgamma(-2.5)":       "-0.056243716497674054",
+		"beta(2, 3)":         "0.083333333333333333333333333333333",
+		"beta(0.5, 0.5)":     "3.1415926535897932384626433832795",
+		"gammainc(2, 1)":     "0.26424111765711535680895245967707",
+		"gammaincc(0.5, 9)":  "0.000022090496998585441372776129582",
+		"gammainc(3, 20)":    "0.999999544485049441",
+		"betainc(0.5, 2, 3)": "0.6875",
+		"betainc(0.9, 2, 3)": "0.9963",
+		"erf(1)":             "0.84270079294971486934122063508261",
+		"erf(-0.1)":          "-0.11246291601828489220327507174397",
+		"erfc(3)":            "0.000022090496998585441372776129582",
+		"erfc(-1)":           "1.8427007929497148693412206350826",
+		"erfinv(0.5)":        "0.47693627620446987338141835364313",
+		"erfinv(-0.999)":     "-2.3267537655135246",
+		"zeta(2)":            "1.6449340668482264364724151666460",
+		"zeta(3)":            "1.2020569031595942853997381615114",
+		"zeta(0.5)":          "-1.4603545088095868128894991525152",
+		"zeta(-1)":           "-0.083333333333333333333333333333333",
+		"zeta(-2)":           "0",
+		"zeta(-1.5)":         "-0.025485201889833035949542",
+		"digamma(1)":         "-0.57721566490153286060651209008240",
+		"digamma(-0.5)":      "0.036489973978576520559023667001244",
+		"digamma(100)":       "4.6001618527380874001992472",
+		"j0(1)":              "0.76519768655796655145",
+		"j1(2.5)":            "0.4970941024642740",
+		"j0(50)":             "0.055812327669251813",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if !closeTo(res, expected, 1e-15) {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res.FloatString(20))
+		}
+	}
+
+	badCalls := []string{
+		"gamma(0)", "gamma(-3)", "fact(-1)", "lgamma(-2)", "zeta(1)",
+		"erfinv(1)", "erfinv(-2)", "gammainc(-1, 2)", "betainc(2, 1, 1)",
+		"digamma(0)", "fact(10**9)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad special function call '%s'", expr)
+		}
+	}
+
+	// Results should follow the parser's precision
+	p := New()
+	p.Precision = 256
+	piDigits := "3.141592653589793238462643383279502884197169399375105820974944592307816406286"
+	for expr, expected := range map[string]string{
+		"gamma(0.5) * gamma(0.5)": piDigits,
+		"6 * zeta(2)":             "9.869604401089358618834490999876151135313699407240790626413349376220044822419",
+		"erf(0.5)":                "0.520499877813046537682746653891964528736451575757963700058805725647193521716",
+	} {
+		res, err := p.Run(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if !closeTo(res, expected, 1e-70) {
+			t.Errorf("wrong result in high precision call '%s' (expected %s, got %s)",
+				expr, expected, res.FloatString(80))
+		}
+	}
+}
+
+func TestDistributionFunctions(t *testing.T) {
+	calls := map[string]string{
+		"normpdf(0)":             "0.39894228040143267793994605993438",
+		"normpdf(1)":             "0.24197072451914334980",
+		"normpdf(1, 2.5, 2)":     "0.15056871607740220247",
+		"normcdf(1.96)":          "0.97500210485177952067",
+		"normcdf(0, 3, 2)":       "0.066807201268858057177",
+		"norminv(0.975)":         "1.9599639845400542355",
+		"norminv(0.5, 10, 3)":    "10",
+		"tpdf(0, 1)":             "0.31830988618379067153776752674503",
+		"tpdf(1, 1)":             "0.15915494309189533577",
+		"tpdf(1, 3)":             "0.20674833578317201857",
+		"tcdf(1, 1)":             "0.75",
+		"tcdf(2, 5)":             "0.94903026058507",
+		"tinv(0.975, 10)":        "2.2281388519862747484",
+		"chi2cdf(2, 2)":          "0.63212055882855767840447622983854",
+		"chi2pdf(2, 2)":          "0.18393972058572116079776188508073",
+		"chi2inv(0.95, 2)":       "5.9914645471079810",
+		"fcdf(1, 2, 2)":          "0.5",
+		"finv(0.5, 7, 7)":        "1",
+		"binompdf(3, 10, 0.5)":   "0.1171875",
+		"binomcdf(3, 10, 0.5)":   "0.171875",
+		"binominv(0.5, 10, 0.5)": "5",
+		"poisspdf(2, 3)":         "0.22404180765538770",
+		"poisscdf(0, 3)":         "0.049787068367863942979342415650062",
+		"poissinv(0.5, 3)":       "3",
+		"exppdf(1, 2)":           "0.27067056647322538378799898994497",
+		"expcdf(1, 2)":           "0.86466471676338730810",
+		"expinv(0.5, 2)":         "0.34657359027997265470861606072909",
+		"unifpdf(1, 0, 4)":       "0.25",
+		"unifpdf(5, 0, 4)":       "0",
+		"unifcdf(1, 0, 4)":       "0.25",
+		"unifinv(0.25, 0, 4)":    "1",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if !closeTo(res, expected, 1e-14) {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res.FloatString(20))
+		}
+	}
+
+	// Quantiles should invert the cdf
+	for _, expr := range []string{
+		"tcdf(tinv(0.01, 3.5), 3.5)", "chi2cdf(chi2inv(0.01, 7), 7)",
+		"fcdf(finv(0.01, 5, 10), 5, 10)", "normcdf(norminv(0.01))",
+	} {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if !closeTo(res, "0.01", 1e-15) {
+			t.Errorf("quantile doesn't invert cdf in '%s' (got %s)", expr, res.FloatString(20))
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		res, err := Eval("randint(-2, 2)")
+		if err != nil {
+			t.Fatalf("unexpected error on ok function call 'randint(-2, 2)': %s", err)
+		}
+		if !res.IsInt() || res.Cmp(big.NewRat(-2, 1)) < 0 || res.Cmp(big.NewRat(2, 1)) > 0 {
+			t.Errorf("randint(-2, 2) out of range (got %s)", res.RatString())
+		}
+	}
+
+	badCalls := []string{
+		"norminv(0)", "norminv(1)", "normcdf(0, 0, 0)", "tcdf(1, -1)",
+		"chi2inv(1.5, 2)", "binompdf(1, 2.5, 0.5)", "binomcdf(1, 10, 2)",
+		"poisspdf(1, 0)", "unifcdf(1, 4, 0)", "randint(3, 1)", "randint(0.5, 2)",
+		"randnorm(0, -1)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad distribution function call '%s'", expr)
+		}
+	}
+
+	// Tiny tail probabilities are flushed to 0 instead of becoming fractions
+	// with huge denominators
+	for _, expr := range []string{"binomcdf(5, 10**6, 0.5)", "binomcdf(5, 10**9, 0.5)", "normcdf(-100)"} {
+		res, err := Eval(expr)
+		if err != nil || res.Sign() != 0 {
+			t.Errorf("expected 0 for tiny result '%s', got %v, %v", expr, res, err)
+		}
+	}
+	if res, err := Eval("normcdf(-30)"); err != nil || res.Sign() <= 0 || res.Denom().BitLen() > 1<<13 {
+		t.Errorf("wrong small result 'normcdf(-30)': %v, %v", res, err)
+	}
+}
+
+func TestRandomFunctions(t *testing.T) {
+	exprs := []string{
+		"rand()", "randnorm(5, 2)", "randint(-10**30, 10**30)",
+		"randrange(10, -10, -3)", "choice(1, 2, 3, 4, 5)",
+	}
+
+	// Parsers with the same seed should give the same results
+	a, b := New(), New()
+	a.Seed(42)
+	b.Run("seed(42)")
+	for i := 0; i < 10; i++ {
+		for _, expr := range exprs {
+			resA, err := a.Run(expr)
+			if err != nil {
+				t.Fatalf("unexpected error on ok function call '%s': %s", expr, err)
+			}
+			resB, err := b.Run(expr)
+			if err != nil {
+				t.Fatalf("unexpected error on ok function call '%s': %s", expr, err)
+			}
+
+			if resA.Cmp(resB) != 0 {
+				t.Errorf("seeded parsers differ on '%s' (%s and %s)", expr,
+					resA.RatString(), resB.RatString())
+			}
+		}
+	}
+
+	ranges := map[string][]int64{
+		"randrange(5)":           {0, 1, 2, 3, 4},
+		"randrange(-2, 2)":       {-2, -1, 0, 1},
+		"randrange(10, -10, -7)": {10, 3, -4},
+		"randint(7, 7)":          {7},
+		"choice(-1, 3, 9)":       {-1, 3, 9},
+	}
+
+	for expr, values := range ranges {
+		for i := 0; i < 20; i++ {
+			res, err := a.Run(expr)
+			if err != nil {
+				t.Fatalf("unexpected error on ok function call '%s': %s", expr, err)
+			}
+
+			found := false
+			for _, value := range values {
+				if res.Cmp(big.NewRat(value, 1)) == 0 {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("'%s' out of range (got %s)", expr, res.RatString())
+			}
+		}
+	}
+
+	badCalls := []string{
+		"randrange(0)", "randrange(5, 1)", "randrange(1, 5, -1)",
+		"randrange(1, 5, 0)", "randrange(2.5)", "choice()", "seed(0.5)",
+	}
+
+	for _, expr := range badCalls {
+		_, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error on bad random function call '%s'", expr)
+		}
+	}
+
+	p := New()
+	p.Deterministic = true
+	for _, expr := range append(exprs, "1 + rand()") {
+		if _, err := p.Run(expr); err == nil {
+			t.Errorf("expected error on '%s' in deterministic mode", expr)
+		}
+	}
+	if _, err := p.Run("normcdf(0) + sin(1)"); err != nil {
+		t.Errorf("unexpected error on deterministic expression in deterministic mode: %s", err)
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	// twice(f, x, a) evaluates f at x = a and x = 2a and adds the results
+	err := RegisterFunction("test_twice", 3, 3, func(p *Parser, args []*Expr) (Value, error) {
+		name, err := args[1].Ident()
+		if err != nil {
+			return nil, err
+		}
+		a, err := args[2].EvalRat(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		first, err := args[0].EvalRat(map[string]*big.Rat{name: a})
+		if err != nil {
+			return nil, err
+		}
+		second, err := args[0].EvalRat(map[string]*big.Rat{name: new(big.Rat).Add(a, a)})
+		if err != nil {
+			return nil, err
+		}
+
+		return new(big.Rat).Add(first, second), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error registering function: %s", err)
+	}
+
+	res, err := Eval("test_twice(x**2 + 1, x, 3) + 1")
+	if err != nil || res.Cmp(big.NewRat(48, 1)) != 0 {
+		t.Errorf("wrong result calling registered function: %v, %v", res, err)
+	}
+
+	if _, err := Eval("test_twice(x, 1, 3)"); err != ErrExpectingIdent {
+		t.Errorf("expected ErrExpectingIdent, got %v", err)
+	}
+
+	if err := RegisterFunction("sin", 1, 1, nil); err == nil {
+		t.Error("expected error registering existing function")
+	}
+	if err := RegisterFunction("", 1, 1, nil); err == nil {
+		t.Error("expected error registering function without name")
+	}
+}
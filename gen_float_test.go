@@ -0,0 +1,33 @@
+// Code generated by mathcat; DO NOT EDIT.
+
+package mathcat_test
+
+import "math"
+
+// FloatFormula evaluates sin(x)*e**y - sqrt(abs(x))/hypot(x, y) + x % 2 + (x >= y) + ln(2) + atan2(y, x) + lgamma(abs(y) + 1)
+func FloatFormula(x, y float64) float64 {
+	v0 := math.Abs(y)
+	v1 := v0 + 1.0
+	v2, _ := math.Lgamma(v1)
+	v3 := math.Atan2(y, x)
+	v4 := math.Log(2.0)
+	v5 := 0.0
+	if x >= y {
+		v5 = 1
+	}
+	v6 := x - 2.0*math.Floor(x/2.0)
+	v7 := math.Hypot(x, y)
+	v8 := math.Abs(x)
+	v9 := math.Sqrt(v8)
+	v10 := v9 / v7
+	v11 := math.Pow(math.E, y)
+	v12 := math.Sin(x)
+	v13 := v12 * v11
+	v14 := v13 - v10
+	v15 := v14 + v6
+	v16 := v15 + v5
+	v17 := v16 + v4
+	v18 := v17 + v3
+	v19 := v18 + v2
+	return v19
+}
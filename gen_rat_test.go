@@ -0,0 +1,106 @@
+// Code generated by mathcat; DO NOT EDIT.
+
+package mathcat_test
+
+import (
+	"math/big"
+
+	"github.com/soudy/mathcat"
+)
+
+var (
+	ratFormulaConst0 = big.NewRat(1, 2)
+	ratFormulaConst1 = big.NewRat(2, 1)
+	ratFormulaConst2 = big.NewRat(5, 1)
+	ratFormulaConst3 = big.NewRat(7, 1)
+	ratFormulaConst4 = big.NewRat(12, 1)
+	ratFormulaConst5 = big.NewRat(18, 1)
+	ratFormulaConst6 = big.NewRat(3, 1)
+)
+
+var ratFormulaParser = mathcat.New()
+
+// RatFormula evaluates 3*x**2 - x/y + max(x, y) % 3 + (x > y) + floor(x*y) - gcd(12, 18)**-2 + (7 & 5) + 2**0.5
+func RatFormula(x, y *big.Rat) (*big.Rat, error) {
+	v0, err := mathcat.Apply(mathcat.Pow, ratFormulaConst1, ratFormulaConst0)
+	if err != nil {
+		return nil, err
+	}
+	v1, err := mathcat.Apply(mathcat.And, ratFormulaConst3, ratFormulaConst2)
+	if err != nil {
+		return nil, err
+	}
+	v2, err := mathcat.Apply(mathcat.UnaryMin, nil, ratFormulaConst1)
+	if err != nil {
+		return nil, err
+	}
+	v3, err := ratFormulaParser.Call("gcd", ratFormulaConst4, ratFormulaConst5)
+	if err != nil {
+		return nil, err
+	}
+	v4, err := mathcat.Apply(mathcat.Pow, v3, v2)
+	if err != nil {
+		return nil, err
+	}
+	v5, err := mathcat.Apply(mathcat.Mul, x, y)
+	if err != nil {
+		return nil, err
+	}
+	v6, err := ratFormulaParser.Call("floor", v5)
+	if err != nil {
+		return nil, err
+	}
+	v7, err := mathcat.Apply(mathcat.Gt, x, y)
+	if err != nil {
+		return nil, err
+	}
+	v8, err := ratFormulaParser.Call("max", x, y)
+	if err != nil {
+		return nil, err
+	}
+	v9, err := mathcat.Apply(mathcat.Rem, v8, ratFormulaConst6)
+	if err != nil {
+		return nil, err
+	}
+	v10, err := mathcat.Apply(mathcat.Div, x, y)
+	if err != nil {
+		return nil, err
+	}
+	v11, err := mathcat.Apply(mathcat.Pow, x, ratFormulaConst1)
+	if err != nil {
+		return nil, err
+	}
+	v12, err := mathcat.Apply(mathcat.Mul, ratFormulaConst6, v11)
+	if err != nil {
+		return nil, err
+	}
+	v13, err := mathcat.Apply(mathcat.Sub, v12, v10)
+	if err != nil {
+		return nil, err
+	}
+	v14, err := mathcat.Apply(mathcat.Add, v13, v9)
+	if err != nil {
+		return nil, err
+	}
+	v15, err := mathcat.Apply(mathcat.Add, v14, v7)
+	if err != nil {
+		return nil, err
+	}
+	v16, err := mathcat.Apply(mathcat.Add, v15, v6)
+	if err != nil {
+		return nil, err
+	}
+	v17, err := mathcat.Apply(mathcat.Sub, v16, v4)
+	if err != nil {
+		return nil, err
+	}
+	v18, err := mathcat.Apply(mathcat.Add, v17, v1)
+	if err != nil {
+		return nil, err
+	}
+	v19, err := mathcat.Apply(mathcat.Add, v18, v0)
+	if err != nil {
+		return nil, err
+	}
+	return v19, nil
+}
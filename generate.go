@@ -0,0 +1,459 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GoType is the number type of functions generated by GenerateGo
+type GoType int
+
+const (
+	// GoRat generates functions on *big.Rat, with the exact semantics of
+	// expressions. They use the mathcat package to execute operators and
+	// functions.
+	GoRat GoType = iota
+	// GoFloat64 generates functions on float64 using only the standard
+	// library. Where expressions give an error, like on division by zero,
+	// they return NaN or an infinity. Bitwise operators work on int64.
+	GoFloat64
+)
+
+// GoOptions configures the code generated by GenerateGo
+type GoOptions struct {
+	Package   string    // package of the file, main by default
+	Func      string    // name of the function, Eval by default
+	Type      GoType    // number type of the parameters and result
+	Params    []string  // parameters in order, by default the variables in alphabetical order
+	AngleUnit AngleUnit // unit of angles of trigonometric functions
+}
+
+// GenerateGo generates a Go source file with a function evaluating an
+// expression, taking its variables as parameters. Predefined variables like
+// pi are constants. Assignments, matrices, random functions and functions
+// taking expressions aren't supported.
+//
+// Example:
+//     src, err := mathcat.GenerateGo("pi*r**2", mathcat.GoOptions{Package: "geom", Func: "Area", Type: mathcat.GoFloat64})
+//     // func Area(r float64) float64
+func GenerateGo(expr string, opts GoOptions) ([]byte, error) {
+	tree, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Package == "" {
+		opts.Package = "main"
+	}
+	if opts.Func == "" {
+		opts.Func = "Eval"
+	}
+	if !isGoIdent(opts.Package) || !isGoIdent(opts.Func) {
+		return nil, fmt.Errorf("Invalid Go name ‘%s’ or ‘%s’", opts.Package, opts.Func)
+	}
+
+	g := &generator{
+		opts:   opts,
+		p:      New(),
+		params: make(map[string]string),
+		consts: make(map[string]string),
+		prefix: string(unicode.ToLower(rune(opts.Func[0]))) + opts.Func[1:],
+	}
+	g.p.AngleUnit = opts.AngleUnit
+
+	if opts.Params == nil {
+		g.opts.Params = freeVariables(tree)
+	}
+	for _, param := range g.opts.Params {
+		if !IsValidIdent(param) {
+			return nil, fmt.Errorf("Invalid identifier ‘%s’", param)
+		}
+		g.params[param] = goName(param)
+	}
+
+	res, err := g.gen(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(g.file(tree, res))
+}
+
+// freeVariables returns the variables of an expression that aren't
+// predefined, in alphabetical order
+func freeVariables(n Node) []string {
+	seen := make(map[string]bool)
+	var names []string
+	Inspect(n, func(n Node) bool {
+		ident, ok := n.(*IdentNode)
+		if !ok || seen[ident.Name()] {
+			return true
+		}
+		if _, ok := defaultVariables[ident.Name()]; !ok {
+			names = append(names, ident.Name())
+		}
+		seen[ident.Name()] = true
+		return true
+	})
+
+	sort.Strings(names)
+	return names
+}
+
+func isGoIdent(name string) bool {
+	return IsValidIdent(name) && !token.Lookup(name).IsKeyword()
+}
+
+// tempName matches the names of temporary variables in generated code
+var tempName = regexp.MustCompile(`^v[0-9]+$`)
+
+// goName returns the Go name of a variable, renamed if it's a keyword or
+// clashes with names in generated code
+func goName(name string) string {
+	switch {
+	case token.Lookup(name).IsKeyword(), tempName.MatchString(name):
+	case name == "err", name == "math", name == "big", name == "mathcat":
+	default:
+		return name
+	}
+
+	return name + "_"
+}
+
+// generator generates the statements of a function evaluating an expression.
+// Every operation is assigned to a temporary variable, evaluating the right
+// hand side of binary operators first like Eval does.
+type generator struct {
+	opts   GoOptions
+	p      *Parser
+	body   bytes.Buffer
+	temps  int
+	params map[string]string // Go names of the parameters
+
+	// Constants of *big.Rat functions, declared at package level
+	consts     map[string]string
+	constDecls []string
+	prefix     string
+	usesParser bool
+}
+
+func (g *generator) temp() string {
+	name := fmt.Sprintf("v%d", g.temps)
+	g.temps++
+	return name
+}
+
+// gen generates the statements evaluating n, returning the Go expression of
+// its value
+func (g *generator) gen(n Node) (string, error) {
+	switch n := n.(type) {
+	case *NumberNode:
+		return g.number(n.Value), nil
+	case *IdentNode:
+		if name, ok := g.params[n.Name()]; ok {
+			return name, nil
+		}
+		if val, ok := defaultVariables[n.Name()]; ok {
+			return g.constant(n.Name(), val), nil
+		}
+		return "", fmt.Errorf("Undefined variable ‘%s’", n.Name())
+	case *UnaryNode:
+		x, err := g.gen(n.X)
+		if err != nil {
+			return "", err
+		}
+		return g.operator(n.Op, "", x)
+	case *BinaryNode:
+		if n.Op.IsAssignment() {
+			return "", fmt.Errorf("Can't generate Go for assignment ‘%s’", n.Op)
+		}
+		rhs, err := g.gen(n.Rhs)
+		if err != nil {
+			return "", err
+		}
+		lhs, err := g.gen(n.Lhs)
+		if err != nil {
+			return "", err
+		}
+		return g.operator(n.Op, lhs, rhs)
+	case *CallNode:
+		return g.call(n)
+	}
+
+	return "", fmt.Errorf("Can't generate Go for ‘%s’", n)
+}
+
+// constant returns the Go expression of a predefined variable
+func (g *generator) constant(name string, val *big.Rat) string {
+	if g.opts.Type == GoFloat64 {
+		switch name {
+		case "pi":
+			return "math.Pi"
+		case "e":
+			return "math.E"
+		case "phi":
+			return "math.Phi"
+		}
+	}
+
+	return g.number(val)
+}
+
+// number returns the Go expression of a number. Numbers of *big.Rat functions
+// are declared once at package level.
+func (g *generator) number(val *big.Rat) string {
+	if g.opts.Type == GoFloat64 {
+		f, _ := val.Float64()
+		if math.IsInf(f, 0) {
+			return fmt.Sprintf("math.Inf(%d)", val.Sign())
+		}
+		lit := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(lit, ".e") {
+			lit += ".0"
+		}
+		return lit
+	}
+
+	key := val.RatString()
+	if name, ok := g.consts[key]; ok {
+		return name
+	}
+
+	name := fmt.Sprintf("%sConst%d", g.prefix, len(g.consts))
+	var decl string
+	if val.Num().IsInt64() && val.Denom().IsInt64() {
+		decl = fmt.Sprintf("%s = big.NewRat(%s, %s)", name, val.Num(), val.Denom())
+	} else {
+		decl = fmt.Sprintf("%s, _ = new(big.Rat).SetString(%q)", name, key)
+	}
+	g.consts[key] = name
+	g.constDecls = append(g.constDecls, decl)
+	return name
+}
+
+// goOps are the names of operators in the mathcat package
+var goOps = map[TokenType]string{
+	Add: "Add", Sub: "Sub", Div: "Div", Mul: "Mul", Pow: "Pow", Rem: "Rem",
+	UnaryMin: "UnaryMin", And: "And", Or: "Or", Xor: "Xor", Lsh: "Lsh",
+	Rsh: "Rsh", Not: "Not", NotEq: "NotEq", EqEq: "EqEq", Gt: "Gt",
+	GtEq: "GtEq", Lt: "Lt", LtEq: "LtEq",
+}
+
+// ratAssign assigns the result of a call returning a *big.Rat and an error
+// to a new temporary variable
+func (g *generator) ratAssign(call string) string {
+	v := g.temp()
+	fmt.Fprintf(&g.body, "%s, err := %s\nif err != nil {\nreturn nil, err\n}\n", v, call)
+	return v
+}
+
+// floatAssign assigns a float64 expression to a new temporary variable
+func (g *generator) floatAssign(format string, args ...interface{}) string {
+	v := g.temp()
+	fmt.Fprintf(&g.body, "%s := "+format+"\n", append([]interface{}{v}, args...)...)
+	return v
+}
+
+// operator generates an operation, with an empty lhs for unary operators
+func (g *generator) operator(op *Token, lhs, rhs string) (string, error) {
+	name, ok := goOps[op.Type]
+	if !ok {
+		return "", fmt.Errorf("Can't generate Go for ‘%s’", op)
+	}
+
+	if g.opts.Type == GoRat {
+		if lhs == "" {
+			lhs = "nil"
+		}
+		return g.ratAssign(fmt.Sprintf("mathcat.Apply(mathcat.%s, %s, %s)", name, lhs, rhs)), nil
+	}
+
+	switch op.Type {
+	case Add, Sub, Mul, Div:
+		return g.floatAssign("%s %s %s", lhs, op.Value, rhs), nil
+	case UnaryMin:
+		if strings.HasPrefix(rhs, "-") {
+			return g.floatAssign("-(%s)", rhs), nil
+		}
+		return g.floatAssign("-%s", rhs), nil
+	case Pow:
+		return g.floatAssign("math.Pow(%s, %s)", lhs, rhs), nil
+	case Rem:
+		// The remainder has the sign of the divisor, like Mod
+		return g.floatAssign("%s - %s*math.Floor(%s/%s)", lhs, rhs, lhs, rhs), nil
+	case And, Or, Xor:
+		return g.floatAssign("float64(int64(%s) %s int64(%s))", lhs, op.Value, rhs), nil
+	case Lsh, Rsh:
+		return g.floatAssign("float64(int64(%s) %s uint64(%s))", lhs, op.Value, rhs), nil
+	case Not:
+		return g.floatAssign("float64(^int64(%s))", rhs), nil
+	}
+
+	// Comparisons give 1 or 0
+	v := g.floatAssign("0.0")
+	fmt.Fprintf(&g.body, "if %s %s %s {\n%s = 1\n}\n", lhs, op.Value, rhs, v)
+	return v, nil
+}
+
+func (g *generator) call(n *CallNode) (string, error) {
+	function, ok := funcs[n.Func.Value]
+	if !ok {
+		return "", fmt.Errorf("Undefined function ‘%s’", n.Func)
+	}
+	if err := g.p.checkCall(n.Func, function, len(n.Args)); err != nil {
+		return "", err
+	}
+	if function.fn == nil || function.random {
+		return "", fmt.Errorf("Can't generate Go for ‘%s’", n.Func)
+	}
+
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		val, err := g.gen(arg)
+		if err != nil {
+			return "", err
+		}
+		args[i] = val
+	}
+
+	if g.opts.Type == GoRat {
+		g.usesParser = true
+		call := fmt.Sprintf("%sParser.Call(%q, %s)", g.prefix, n.Func.Value, strings.Join(args, ", "))
+		return g.ratAssign(call), nil
+	}
+
+	return g.floatCall(n.Func, args)
+}
+
+// floatFuncs are the functions with a float64 equivalent in the math package
+var floatFuncs = map[string]string{
+	"abs": "math.Abs", "ceil": "math.Ceil", "floor": "math.Floor",
+	"hypot": "math.Hypot", "sinh": "math.Sinh", "cosh": "math.Cosh",
+	"tanh": "math.Tanh", "asinh": "math.Asinh", "acosh": "math.Acosh",
+	"atanh": "math.Atanh", "ln": "math.Log", "log": "math.Log10",
+	"max": "math.Max", "min": "math.Min", "sqrt": "math.Sqrt",
+	"gamma": "math.Gamma", "erf": "math.Erf", "erfc": "math.Erfc",
+	"erfinv": "math.Erfinv", "j0": "math.J0", "j1": "math.J1",
+}
+
+// floatCall generates a call of a function on float64
+func (g *generator) floatCall(fn *Token, args []string) (string, error) {
+	if f, ok := floatFuncs[fn.Value]; ok {
+		return g.floatAssign("%s(%s)", f, strings.Join(args, ", ")), nil
+	}
+
+	// Angles are converted from and to radians
+	toRad, fromRad := "", ""
+	switch g.opts.AngleUnit {
+	case Degrees:
+		toRad, fromRad = "*(math.Pi/180)", "*(180/math.Pi)"
+	case Gradians:
+		toRad, fromRad = "*(math.Pi/200)", "*(200/math.Pi)"
+	}
+
+	x := args[0]
+	switch fn.Value {
+	case "sin", "cos", "tan":
+		name := strings.ToUpper(fn.Value[:1]) + fn.Value[1:]
+		return g.floatAssign("math.%s(%s%s)", name, x, toRad), nil
+	case "sec":
+		return g.floatAssign("1 / math.Cos(%s%s)", x, toRad), nil
+	case "csc":
+		return g.floatAssign("1 / math.Sin(%s%s)", x, toRad), nil
+	case "cot":
+		return g.floatAssign("1 / math.Tan(%s%s)", x, toRad), nil
+	case "asin", "acos", "atan":
+		name := "A" + fn.Value[1:]
+		return g.floatAssign("math.%s(%s)%s", name, x, fromRad), nil
+	case "atan2":
+		return g.floatAssign("math.Atan2(%s, %s)%s", x, args[1], fromRad), nil
+	case "deg":
+		return g.floatAssign("%s * (180 / math.Pi)", x), nil
+	case "rad":
+		return g.floatAssign("%s * (math.Pi / 180)", x), nil
+	case "logn":
+		return g.floatAssign("math.Log(%s) / math.Log(%s)", args[1], x), nil
+	case "fact":
+		return g.floatAssign("math.Gamma(%s + 1)", x), nil
+	case "lgamma":
+		v := g.temp()
+		fmt.Fprintf(&g.body, "%s, _ := math.Lgamma(%s)\n", v, x)
+		return v, nil
+	}
+
+	return "", fmt.Errorf("Can't generate float64 Go for ‘%s’", fn)
+}
+
+// file returns the source of the generated file, with the function returning
+// res
+func (g *generator) file(tree Node, res string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by mathcat; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", g.opts.Package)
+
+	if g.opts.Type == GoFloat64 {
+		if strings.Contains(g.body.String()+res, "math.") {
+			buf.WriteString("import \"math\"\n\n")
+		}
+	} else {
+		buf.WriteString("import (\n\"math/big\"\n\n\"github.com/soudy/mathcat\"\n)\n\n")
+		if len(g.constDecls) > 0 {
+			fmt.Fprintf(&buf, "var (\n%s\n)\n\n", strings.Join(g.constDecls, "\n"))
+		}
+		if g.usesParser {
+			fmt.Fprintf(&buf, "var %sParser = mathcat.New()\n\n", g.prefix)
+			if unit := g.opts.AngleUnit; unit != Radians {
+				fmt.Fprintf(&buf, "func init() {\n%sParser.AngleUnit = mathcat.%s\n}\n\n", g.prefix, goAngleUnits[unit])
+			}
+		}
+	}
+
+	typ := "*big.Rat"
+	if g.opts.Type == GoFloat64 {
+		typ = "float64"
+	}
+	params := make([]string, len(g.opts.Params))
+	for i, param := range g.opts.Params {
+		params[i] = g.params[param]
+	}
+
+	fmt.Fprintf(&buf, "// %s evaluates %s\n", g.opts.Func, tree)
+	if g.opts.Type == GoFloat64 {
+		fmt.Fprintf(&buf, "func %s(%s) float64 {\n%sreturn %s\n}\n", g.opts.Func, paramList(params, typ), g.body.Bytes(), res)
+		return buf.Bytes()
+	}
+
+	// Parameters and constants are copied, so callers can't change them
+	if !tempName.MatchString(res) {
+		res = fmt.Sprintf("new(big.Rat).Set(%s)", res)
+	}
+	fmt.Fprintf(&buf, "func %s(%s) (*big.Rat, error) {\n%sreturn %s, nil\n}\n", g.opts.Func, paramList(params, typ), g.body.Bytes(), res)
+
+	return buf.Bytes()
+}
+
+// goAngleUnits are the names of angle units in the mathcat package
+var goAngleUnits = map[AngleUnit]string{
+	Radians: "Radians", Degrees: "Degrees", Gradians: "Gradians",
+}
+
+func paramList(params []string, typ string) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	return strings.Join(params, ", ") + " " + typ
+}
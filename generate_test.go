@@ -0,0 +1,108 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	src, err := GenerateGo("pi*r**2", GoOptions{Package: "geom", Func: "Area", Type: GoFloat64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `// Code generated by mathcat; DO NOT EDIT.
+
+package geom
+
+import "math"
+
+// Area evaluates pi*r**2
+func Area(r float64) float64 {
+	v0 := math.Pow(r, 2.0)
+	v1 := math.Pi * v0
+	return v1
+}
+`
+	if string(src) != expected {
+		t.Errorf("wrong generated code (expected\n%s\ngot\n%s)", expected, src)
+	}
+
+	// Parameters are given in order, renamed when they clash with Go names.
+	// Constants are declared once and copied when returned.
+	generated := []struct {
+		expr     string
+		opts     GoOptions
+		contains string
+	}{
+		{"x + type", GoOptions{Params: []string{"y", "x", "type"}}, "func Eval(y, x, type_ *big.Rat) (*big.Rat, error)"},
+		{"x + 0.5 - 0.5", GoOptions{}, "evalConst0 = big.NewRat(1, 2)\n)"},
+		{"sin(x)", GoOptions{}, `evalParser.Call("sin", x)`},
+		{"sin(x)", GoOptions{AngleUnit: Degrees}, "evalParser.AngleUnit = mathcat.Degrees"},
+		{"sin(x)", GoOptions{Type: GoFloat64, AngleUnit: Degrees}, "math.Sin(x * (math.Pi / 180))"},
+		{"0.5", GoOptions{}, "return new(big.Rat).Set(evalConst0), nil"},
+	}
+	for _, g := range generated {
+		src, err := GenerateGo(g.expr, g.opts)
+		if err != nil {
+			t.Errorf("unexpected error generating '%s': %s", g.expr, err)
+			continue
+		}
+
+		if !strings.Contains(string(src), g.contains) {
+			t.Errorf("generated code of '%s' doesn't contain '%s':\n%s", g.expr, g.contains, src)
+		}
+	}
+
+	errors := map[string]GoOptions{
+		"a = 2":           {},
+		"[1, 2]":          {},
+		"rand()":          {},
+		"sum(n, 1, 3, n)": {},
+		"isprime(x)":      {Type: GoFloat64},
+		"x + y":           {Params: []string{"x"}},
+		"max(1)":          {},
+		"x":               {Func: "func"},
+	}
+	for expr, opts := range errors {
+		if _, err := GenerateGo(expr, opts); err == nil {
+			t.Errorf("expected error generating '%s'", expr)
+		}
+	}
+}
+
+func TestApplyAndCall(t *testing.T) {
+	res, err := Apply(Pow, big.NewRat(2, 3), big.NewRat(2, 1))
+	if err != nil || res.Cmp(big.NewRat(4, 9)) != 0 {
+		t.Errorf("wrong result of Apply (expected 4/9, got %v, %v)", res, err)
+	}
+	res, err = Apply(UnaryMin, nil, big.NewRat(2, 3))
+	if err != nil || res.Cmp(big.NewRat(-2, 3)) != 0 {
+		t.Errorf("wrong result of Apply (expected -2/3, got %v, %v)", res, err)
+	}
+	if _, err := Apply(Div, big.NewRat(1, 1), new(big.Rat)); err != ErrDivisionByZero {
+		t.Errorf("expected division by zero error, got %v", err)
+	}
+	if _, err := Apply(Add, nil, big.NewRat(1, 1)); err == nil {
+		t.Error("expected error applying binary operator to one operand")
+	}
+
+	p := New()
+	res, err = p.Call("max", big.NewRat(3, 1), big.NewRat(7, 2))
+	if err != nil || res.Cmp(big.NewRat(7, 2)) != 0 {
+		t.Errorf("wrong result of Call (expected 7/2, got %v, %v)", res, err)
+	}
+	for _, name := range []string{"undefined", "sum", "inv"} {
+		if _, err := p.Call(name, big.NewRat(1, 1)); err == nil {
+			t.Errorf("expected error calling '%s'", name)
+		}
+	}
+	if _, err := p.Call("factor", big.NewRat(1, 2)); err == nil {
+		t.Error("expected error calling integer function with fraction")
+	}
+}
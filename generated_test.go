@@ -0,0 +1,64 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/soudy/mathcat"
+)
+
+//go:generate go run ./cmd/mc -gen RatFormula -package mathcat_test -o gen_rat_test.go "3*x**2 - x/y + max(x, y) % 3 + (x > y) + floor(x*y) - gcd(12, 18)**-2 + (7 & 5) + 2**0.5"
+//go:generate go run ./cmd/mc -gen FloatFormula -package mathcat_test -type float64 -o gen_float_test.go "sin(x)*e**y - sqrt(abs(x))/hypot(x, y) + x % 2 + (x >= y) + ln(2) + atan2(y, x) + lgamma(abs(y) + 1)"
+
+const (
+	ratFormula   = "3*x**2 - x/y + max(x, y) % 3 + (x > y) + floor(x*y) - gcd(12, 18)**-2 + (7 & 5) + 2**0.5"
+	floatFormula = "sin(x)*e**y - sqrt(abs(x))/hypot(x, y) + x % 2 + (x >= y) + ln(2) + atan2(y, x) + lgamma(abs(y) + 1)"
+)
+
+func TestGeneratedGo(t *testing.T) {
+	// Generated functions have to give the same results as Eval
+	points := [][2]*big.Rat{
+		{big.NewRat(7, 2), big.NewRat(-5, 3)},
+		{big.NewRat(-2, 1), big.NewRat(1, 4)},
+		{big.NewRat(1, 10), big.NewRat(3, 1)},
+		{big.NewRat(5, 1), big.NewRat(0, 1)},
+	}
+
+	for _, pt := range points {
+		p := mathcat.New()
+		p.Variables["x"], p.Variables["y"] = pt[0], pt[1]
+
+		expected, expectedErr := p.Run(ratFormula)
+		res, err := RatFormula(pt[0], pt[1])
+		switch {
+		case expectedErr != nil:
+			if err == nil || err.Error() != expectedErr.Error() {
+				t.Errorf("wrong error of RatFormula(%s, %s) (expected %s, got %v)", pt[0], pt[1], expectedErr, err)
+			}
+		case err != nil:
+			t.Errorf("unexpected error of RatFormula(%s, %s): %s", pt[0], pt[1], err)
+		case res.Cmp(expected) != 0:
+			t.Errorf("wrong result of RatFormula(%s, %s) (expected %s, got %s)", pt[0], pt[1], expected, res)
+		}
+
+		if pt[1].Sign() == 0 {
+			continue
+		}
+
+		expected, err = p.Run(floatFormula)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := expected.Float64()
+		x, _ := pt[0].Float64()
+		y, _ := pt[1].Float64()
+		if got := FloatFormula(x, y); math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+			t.Errorf("wrong result of FloatFormula(%g, %g) (expected %g, got %g)", x, y, want, got)
+		}
+	}
+}
@@ -141,6 +141,12 @@ loop:
 				l.emit(Rparen)
 			case ',':
 				l.emit(Comma)
+			case '[':
+				l.emit(Lbracket)
+			case ']':
+				l.emit(Rbracket)
+			case ';':
+				l.emit(Semicolon)
 			case '#', eol:
 				// Comment or EOL, stop scanning for tokens
 				l.emit(Eol)
@@ -241,7 +247,12 @@ func (l *lexer) readNumber() {
 }
 
 func (l lexer) isNegation() bool {
-	return l.tokens == nil || l.prev().Is(Lparen) || l.prev().IsOperator()
+	if l.tokens == nil {
+		return true
+	}
+
+	prev := l.prev()
+	return prev.Is(Lparen) || prev.Is(Comma) || prev.Is(Lbracket) || prev.Is(Semicolon) || prev.IsOperator()
 }
 
 func (l *lexer) switchEq(tokA, tokB TokenType) {
@@ -67,3 +67,21 @@ func TestUTF8(t *testing.T) {
 		t.Error("isIdent doesn't recognize unicode characters")
 	}
 }
+
+func TestBrackets(t *testing.T) {
+	res, err := Lex("[1, -2; -3, 4]")
+	expected := []TokenType{
+		Lbracket, Decimal, Comma, UnaryMin, Decimal, Semicolon, UnaryMin,
+		Decimal, Comma, Decimal, Rbracket, Eol,
+	}
+
+	if err != nil {
+		t.Errorf("unexpected lexer error occured: %s", err)
+	}
+
+	for k, v := range res {
+		if expected[k] != v.Type {
+			t.Errorf("mismatched token: expected %s, got %s", expected[k], v.Type)
+		}
+	}
+}
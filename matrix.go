@@ -0,0 +1,502 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Matrix is a matrix of rational numbers, stored in row-major order. Vectors
+// are matrices with a single row or column.
+type Matrix struct {
+	Rows, Cols int
+	Data       []*big.Rat
+}
+
+var (
+	ErrEmptyMatrix      = errors.New("Empty matrix")
+	ErrDimMismatch      = errors.New("Matrix dimensions don't match")
+	ErrNotSquare        = errors.New("Expecting a square matrix")
+	ErrSingular         = errors.New("Matrix is singular")
+	ErrNotVector        = errors.New("Expecting a vector")
+	ErrUnexpectedMatrix = errors.New("Expecting a number, got a matrix")
+	ErrNoSolution       = errors.New("System of equations has no solution")
+)
+
+// NewMatrix creates a rows by cols matrix filled with zeros
+func NewMatrix(rows, cols int) *Matrix {
+	m := &Matrix{Rows: rows, Cols: cols, Data: make([]*big.Rat, rows*cols)}
+	for i := range m.Data {
+		m.Data[i] = new(big.Rat)
+	}
+
+	return m
+}
+
+// Identity creates an n by n identity matrix
+func Identity(n int) *Matrix {
+	m := NewMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m.At(i, i).SetInt64(1)
+	}
+
+	return m
+}
+
+// At returns the element at row i and column j
+func (m *Matrix) At(i, j int) *big.Rat {
+	return m.Data[i*m.Cols+j]
+}
+
+// Copy returns a deep copy of m
+func (m *Matrix) Copy() *Matrix {
+	res := &Matrix{Rows: m.Rows, Cols: m.Cols, Data: make([]*big.Rat, len(m.Data))}
+	for i, x := range m.Data {
+		res.Data[i] = new(big.Rat).Set(x)
+	}
+
+	return res
+}
+
+// IsVector reports whether m has a single row or column
+func (m *Matrix) IsVector() bool {
+	return m.Rows == 1 || m.Cols == 1
+}
+
+// Equal reports whether m and n have the same dimensions and elements
+func (m *Matrix) Equal(n *Matrix) bool {
+	if m.Rows != n.Rows || m.Cols != n.Cols {
+		return false
+	}
+
+	for i, x := range m.Data {
+		if x.Cmp(n.Data[i]) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String formats m like a matrix literal, e.g. [1, 2; 3, 4]
+func (m *Matrix) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteByte('[')
+	for i := 0; i < m.Rows; i++ {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		for j := 0; j < m.Cols; j++ {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(m.At(i, j).RatString())
+		}
+	}
+	buf.WriteByte(']')
+
+	return buf.String()
+}
+
+// Add calculates m + n element-wise
+func (m *Matrix) Add(n *Matrix) (*Matrix, error) {
+	return m.elementWise(n, (*big.Rat).Add)
+}
+
+// Sub calculates m - n element-wise
+func (m *Matrix) Sub(n *Matrix) (*Matrix, error) {
+	return m.elementWise(n, (*big.Rat).Sub)
+}
+
+func (m *Matrix) elementWise(n *Matrix, op func(z, x, y *big.Rat) *big.Rat) (*Matrix, error) {
+	if m.Rows != n.Rows || m.Cols != n.Cols {
+		return nil, ErrDimMismatch
+	}
+
+	res := NewMatrix(m.Rows, m.Cols)
+	for i := range res.Data {
+		op(res.Data[i], m.Data[i], n.Data[i])
+	}
+
+	return res, nil
+}
+
+// Scale multiplies every element of m by x
+func (m *Matrix) Scale(x *big.Rat) *Matrix {
+	res := NewMatrix(m.Rows, m.Cols)
+	for i := range res.Data {
+		res.Data[i].Mul(m.Data[i], x)
+	}
+
+	return res
+}
+
+// Mul calculates the matrix product m * n
+func (m *Matrix) Mul(n *Matrix) (*Matrix, error) {
+	if m.Cols != n.Rows {
+		return nil, ErrDimMismatch
+	}
+
+	res := NewMatrix(m.Rows, n.Cols)
+	tmp := new(big.Rat)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < n.Cols; j++ {
+			sum := res.At(i, j)
+			for k := 0; k < m.Cols; k++ {
+				sum.Add(sum, tmp.Mul(m.At(i, k), n.At(k, j)))
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Pow calculates m**e for a square matrix m. Negative exponents use the
+// inverse of m.
+func (m *Matrix) Pow(e *big.Int) (*Matrix, error) {
+	if m.Rows != m.Cols {
+		return nil, ErrNotSquare
+	}
+
+	base := m
+	if e.Sign() < 0 {
+		inv, err := m.Inverse()
+		if err != nil {
+			return nil, err
+		}
+		base = inv
+	}
+
+	res := Identity(m.Rows)
+	exp := new(big.Int).Abs(e)
+	for i := exp.BitLen() - 1; i >= 0; i-- {
+		res, _ = res.Mul(res)
+		if exp.Bit(i) == 1 {
+			res, _ = res.Mul(base)
+		}
+	}
+
+	return res, nil
+}
+
+// Transpose returns the transpose of m
+func (m *Matrix) Transpose() *Matrix {
+	res := NewMatrix(m.Cols, m.Rows)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			res.At(j, i).Set(m.At(i, j))
+		}
+	}
+
+	return res
+}
+
+// eliminate brings m into reduced row echelon form in place using Gauss-Jordan
+// elimination, only considering the first cols columns for pivots. It returns
+// the pivot columns and the determinant factor picked up by row swaps and
+// scaling, so that det(original) = factor * det(result).
+func (m *Matrix) eliminate(cols int) (pivots []int, factor *big.Rat) {
+	factor = big.NewRat(1, 1)
+	tmp := new(big.Rat)
+
+	row := 0
+	for col := 0; col < cols && row < m.Rows; col++ {
+		// Find a row with a non-zero element in this column
+		pivot := -1
+		for i := row; i < m.Rows; i++ {
+			if m.At(i, col).Sign() != 0 {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+
+		if pivot != row {
+			for j := 0; j < m.Cols; j++ {
+				m.Data[pivot*m.Cols+j], m.Data[row*m.Cols+j] = m.Data[row*m.Cols+j], m.Data[pivot*m.Cols+j]
+			}
+			factor.Neg(factor)
+		}
+
+		// Scale the pivot row so the pivot becomes 1
+		scale := new(big.Rat).Set(m.At(row, col))
+		factor.Mul(factor, scale)
+		for j := col; j < m.Cols; j++ {
+			m.At(row, j).Quo(m.At(row, j), scale)
+		}
+
+		// Clear the column in all other rows
+		for i := 0; i < m.Rows; i++ {
+			if i == row || m.At(i, col).Sign() == 0 {
+				continue
+			}
+
+			coef := new(big.Rat).Set(m.At(i, col))
+			for j := col; j < m.Cols; j++ {
+				m.At(i, j).Sub(m.At(i, j), tmp.Mul(coef, m.At(row, j)))
+			}
+		}
+
+		pivots = append(pivots, col)
+		row++
+	}
+
+	return pivots, factor
+}
+
+// Rref returns the reduced row echelon form of m
+func (m *Matrix) Rref() *Matrix {
+	res := m.Copy()
+	res.eliminate(res.Cols)
+	return res
+}
+
+// Rank calculates the rank of m
+func (m *Matrix) Rank() int {
+	pivots, _ := m.Copy().eliminate(m.Cols)
+	return len(pivots)
+}
+
+// Det calculates the determinant of a square matrix
+func (m *Matrix) Det() (*big.Rat, error) {
+	if m.Rows != m.Cols {
+		return nil, ErrNotSquare
+	}
+
+	pivots, factor := m.Copy().eliminate(m.Cols)
+	if len(pivots) < m.Rows {
+		return new(big.Rat), nil
+	}
+
+	return factor, nil
+}
+
+// Inverse calculates the inverse of a square matrix
+func (m *Matrix) Inverse() (*Matrix, error) {
+	if m.Rows != m.Cols {
+		return nil, ErrNotSquare
+	}
+
+	// Eliminate [m | I], the right half becomes the inverse
+	n := m.Rows
+	aug := NewMatrix(n, 2*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			aug.At(i, j).Set(m.At(i, j))
+		}
+		aug.At(i, n+i).SetInt64(1)
+	}
+
+	if pivots, _ := aug.eliminate(n); len(pivots) < n {
+		return nil, ErrSingular
+	}
+
+	res := NewMatrix(n, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			res.At(i, j).Set(aug.At(i, n+j))
+		}
+	}
+
+	return res, nil
+}
+
+// Solution is the solution of a system of linear equations. If the system has
+// infinitely many solutions, every solution is Particular plus a linear
+// combination of the vectors in Basis.
+type Solution struct {
+	// Particular is a solution as column vector, with all free variables zero
+	Particular *Matrix
+	// Basis spans the null space of the coefficient matrix
+	Basis []*Matrix
+	// Free holds the indices of the free variables
+	Free []int
+}
+
+// Unique reports whether the solution is the only one
+func (s *Solution) Unique() bool {
+	return len(s.Free) == 0
+}
+
+// Describe describes the solution space using the given variable names, like
+// "x = 3 - 2*y, y free". The free variables act as parameters.
+func (s *Solution) Describe(names []string) string {
+	var buf bytes.Buffer
+
+	free := make(map[int]int, len(s.Free))
+	for i, col := range s.Free {
+		free[col] = i
+	}
+
+	for i, x := range s.Particular.Data {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if _, ok := free[i]; ok {
+			buf.WriteString(names[i] + " free")
+			continue
+		}
+
+		buf.WriteString(names[i] + " = ")
+		terms := 0
+		if x.Sign() != 0 {
+			buf.WriteString(x.RatString())
+			terms++
+		}
+		for j, col := range s.Free {
+			// The free variable contributes its basis vector's element
+			coef := s.Basis[j].Data[i]
+			if coef.Sign() == 0 {
+				continue
+			}
+
+			abs := new(big.Rat).Abs(coef)
+			switch {
+			case terms > 0 && coef.Sign() < 0:
+				buf.WriteString(" - ")
+			case terms > 0:
+				buf.WriteString(" + ")
+			case coef.Sign() < 0:
+				buf.WriteString("-")
+			}
+			if abs.Cmp(big.NewRat(1, 1)) != 0 {
+				buf.WriteString(abs.RatString() + "*")
+			}
+			buf.WriteString(names[col])
+			terms++
+		}
+		if terms == 0 {
+			buf.WriteString("0")
+		}
+	}
+
+	return buf.String()
+}
+
+// Solve solves the system of linear equations m * x = b for x, with b a vector
+// with an element for every row of m. If there is no solution, ErrNoSolution
+// is returned.
+func (m *Matrix) Solve(b *Matrix) (*Solution, error) {
+	if !b.IsVector() {
+		return nil, ErrNotVector
+	}
+	if len(b.Data) != m.Rows {
+		return nil, ErrDimMismatch
+	}
+
+	// Eliminate [m | b]
+	n := m.Cols
+	aug := NewMatrix(m.Rows, n+1)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < n; j++ {
+			aug.At(i, j).Set(m.At(i, j))
+		}
+		aug.At(i, n).Set(b.Data[i])
+	}
+
+	pivots, _ := aug.eliminate(n)
+
+	// A row 0 = c with c non-zero means the equations contradict each other
+	for i := len(pivots); i < m.Rows; i++ {
+		if aug.At(i, n).Sign() != 0 {
+			return nil, ErrNoSolution
+		}
+	}
+
+	sol := &Solution{Particular: NewMatrix(n, 1)}
+	isPivot := make([]bool, n)
+	for i, col := range pivots {
+		isPivot[col] = true
+		sol.Particular.Data[col].Set(aug.At(i, n))
+	}
+
+	for col := 0; col < n; col++ {
+		if isPivot[col] {
+			continue
+		}
+
+		vec := NewMatrix(n, 1)
+		vec.Data[col].SetInt64(1)
+		for i, pivot := range pivots {
+			vec.Data[pivot].Neg(aug.At(i, col))
+		}
+
+		sol.Free = append(sol.Free, col)
+		sol.Basis = append(sol.Basis, vec)
+	}
+
+	return sol, nil
+}
+
+// Dot calculates the dot product of two vectors of the same length
+func (m *Matrix) Dot(n *Matrix) (*big.Rat, error) {
+	if !m.IsVector() || !n.IsVector() {
+		return nil, ErrNotVector
+	}
+	if len(m.Data) != len(n.Data) {
+		return nil, ErrDimMismatch
+	}
+
+	sum := new(big.Rat)
+	tmp := new(big.Rat)
+	for i, x := range m.Data {
+		sum.Add(sum, tmp.Mul(x, n.Data[i]))
+	}
+
+	return sum, nil
+}
+
+// Cross calculates the cross product of two vectors of length 3. The result
+// has the shape of m.
+func (m *Matrix) Cross(n *Matrix) (*Matrix, error) {
+	if !m.IsVector() || !n.IsVector() {
+		return nil, ErrNotVector
+	}
+	if len(m.Data) != 3 || len(n.Data) != 3 {
+		return nil, fmt.Errorf("Cross product needs vectors of length 3")
+	}
+
+	a, b := m.Data, n.Data
+	res := NewMatrix(m.Rows, m.Cols)
+	tmp := new(big.Rat)
+	for i := 0; i < 3; i++ {
+		j, k := (i+1)%3, (i+2)%3
+		res.Data[i].Mul(a[j], b[k])
+		res.Data[i].Sub(res.Data[i], tmp.Mul(a[k], b[j]))
+	}
+
+	return res, nil
+}
+
+// SquaredNorm calculates the sum of the squares of all elements of m, the
+// square of its Euclidean (Frobenius) norm
+func (m *Matrix) SquaredNorm() *big.Rat {
+	sum := new(big.Rat)
+	tmp := new(big.Rat)
+	for _, x := range m.Data {
+		sum.Add(sum, tmp.Mul(x, x))
+	}
+
+	return sum
+}
+
+// ratSqrt calculates the square root of x >= 0. The result is exact if x is
+// the square of a rational, otherwise it's rounded to prec bits.
+func ratSqrt(x *big.Rat, prec uint) *big.Rat {
+	num, den := new(big.Int).Sqrt(x.Num()), new(big.Int).Sqrt(x.Denom())
+	if new(big.Int).Mul(num, num).Cmp(x.Num()) == 0 && new(big.Int).Mul(den, den).Cmp(x.Denom()) == 0 {
+		return new(big.Rat).SetFrac(num, den)
+	}
+
+	f := new(big.Float).SetPrec(prec + guardBits).SetRat(x)
+	res, _ := f.Sqrt(f).SetPrec(prec).Rat(nil)
+	return res
+}
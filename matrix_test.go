@@ -0,0 +1,129 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestMatrixValues(t *testing.T) {
+	exprs := map[string]string{
+		"[1, 2; 3, 4]":                "[1, 2; 3, 4]",
+		"[[1, 2], [3, 4]]":            "[1, 2; 3, 4]",
+		"[1; 2; 3]":                   "[1; 2; 3]",
+		"[1, -2, 3/4]":                "[1, -2, 3/4]",
+		"-[1, 2]":                     "[-1, -2]",
+		"[1, 2] + [3, 4]":             "[4, 6]",
+		"[1, 2] - [3, 4]":             "[-2, -2]",
+		"2 * [1, 2]":                  "[2, 4]",
+		"[1, 2] / 4":                  "[1/4, 1/2]",
+		"[1, 2; 3, 4] * [5; 6]":       "[17; 39]",
+		"[1, 1; 1, 0] ** 10":          "[89, 55; 55, 34]",
+		"[2, 1; 1, 3] ** -1":          "[3/5, -1/5; -1/5, 2/5]",
+		"inv([2, 1; 1, 3])":           "[3/5, -1/5; -1/5, 2/5]",
+		"transpose([1, 2, 3])":        "[1; 2; 3]",
+		"rref([1, 2, 3; 4, 5, 6])":    "[1, 0, -1; 0, 1, 2]",
+		"cross([1, 0, 0], [0, 1, 0])": "[0, 0, 1]",
+	}
+
+	for expr, expected := range exprs {
+		res, err := EvalValue(expr)
+		if err != nil {
+			t.Errorf("unexpected error on matrix expression '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong result in matrix expression '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+}
+
+func TestMatrixFunctions(t *testing.T) {
+	calls := map[string]*big.Rat{
+		"det([2, 1; 1, 3])":         big.NewRat(5, 1),
+		"det([1, 2; 2, 4])":         RatZero,
+		"det([0, 1; 1, 0])":         big.NewRat(-1, 1),
+		"det([1/2, 1/3; 1/4, 1/5])": big.NewRat(1, 60),
+		"rank([1, 2; 2, 4])":        big.NewRat(1, 1),
+		"rank([1, 2, 3; 4, 5, 6])":  big.NewRat(2, 1),
+		"dot([1, 2, 3], [4; 5; 6])": big.NewRat(32, 1),
+		"norm([3, 4])":              big.NewRat(5, 1),
+		"norm([1/3, 0])":            big.NewRat(1, 3),
+		"[1, 2] == [1, 2]":          RatTrue,
+		"[1, 2] == [1; 2]":          RatFalse,
+		"[1, 2] != 3":               RatTrue,
+		"inv([2, 1; 1, 3]) * [2, 1; 1, 3] == [1, 0; 0, 1]": RatTrue,
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on ok function call '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result in function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	badExprs := []string{
+		"inv([1, 2; 2, 4])", "det([1, 2, 3])", "[1, 2] + [1, 2, 3]",
+		"[1, 2] * [3, 4]", "cross([1, 2], [3, 4])", "dot([1, 2; 3, 4], [1, 2])",
+		"[1, 2] ** 2", "[1, 0; 0, 1] ** 0.5", "2 / [1, 2]", "[1, 2] / 0",
+		"[1, 2] < [3, 4]", "[1, 2; 3]", "[]", "[1, 2", "1, 2]", "(1; 2)",
+		"sin([1, 2])", "det(5)", "[1, 2] + 1", "[1, [2, 3]]",
+	}
+
+	for _, expr := range badExprs {
+		_, err := EvalValue(expr)
+		if err == nil {
+			t.Errorf("expected error on bad matrix expression '%s'", expr)
+		}
+	}
+}
+
+func TestMatrixVariables(t *testing.T) {
+	p := New()
+
+	if _, err := p.RunValue("m = [2, 1; 1, 3]"); err != nil {
+		t.Fatalf("unexpected error assigning matrix: %s", err)
+	}
+
+	m, err := p.GetMatrix("m")
+	if err != nil {
+		t.Fatalf("unexpected error getting matrix: %s", err)
+	}
+	if m.Rows != 2 || m.Cols != 2 || m.At(1, 1).Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("wrong matrix stored in variable: %s", m)
+	}
+
+	if _, err := p.Run("m"); err != ErrMatrixResult {
+		t.Errorf("expected ErrMatrixResult running matrix expression, got %v", err)
+	}
+	if _, err := p.Run("m + 1"); err == nil {
+		t.Error("expected error adding a number to a matrix")
+	}
+
+	res, err := p.Run("det(m * m)")
+	if err != nil || res.Cmp(big.NewRat(25, 1)) != 0 {
+		t.Errorf("wrong result for det(m * m): %v, %v", res, err)
+	}
+
+	// Assigning a number to the name turns it back into a number variable
+	if _, err := p.Run("m = 5"); err != nil {
+		t.Fatalf("unexpected error reassigning variable: %s", err)
+	}
+	if _, err := p.GetMatrix("m"); err == nil {
+		t.Error("expected error getting reassigned matrix")
+	}
+	if v, err := p.GetVar("m"); err != nil || v.Cmp(big.NewRat(5, 1)) != 0 {
+		t.Errorf("wrong value for reassigned variable: %v, %v", v, err)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// NumberMode is the kind of numbers a parser calculates with
+type NumberMode int
+
+const (
+	// ExactMode calculates with rational numbers. Results are exact, except
+	// for functions like sqrt that can't be calculated exactly.
+	ExactMode NumberMode = iota
+	// BigFloatMode rounds numbers to Precision bits with RoundingMode after
+	// every operation, so they don't grow when they're calculated with over
+	// and over again, like in x = x*1.1 + 0.3
+	BigFloatMode
+	// Float64Mode rounds numbers to float64 after every operation. Results
+	// that don't fit in a float64 give an error.
+	Float64Mode
+)
+
+var numberModeNames = map[NumberMode]string{
+	ExactMode:    "exact",
+	BigFloatMode: "bigfloat",
+	Float64Mode:  "float64",
+}
+
+func (m NumberMode) String() string {
+	return numberModeNames[m]
+}
+
+// ParseNumberMode converts a mode name (exact, bigfloat or float64) to a
+// NumberMode
+func ParseNumberMode(name string) (NumberMode, error) {
+	for mode, modeName := range numberModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+
+	return ExactMode, fmt.Errorf("Invalid number mode ‘%s’", name)
+}
+
+// round rounds a result to the numbers of the parser's mode
+func (p *Parser) round(val Value) (Value, error) {
+	if p.Mode == ExactMode {
+		return val, nil
+	}
+
+	switch val := val.(type) {
+	case *big.Rat:
+		return p.roundRat(new(big.Rat), val, new(big.Float))
+	case *Matrix:
+		res := val.Copy()
+		f := new(big.Float)
+		for _, x := range res.Data {
+			if _, err := p.roundRat(x, x, f); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	return val, nil
+}
+
+// roundRat sets z to x rounded to the numbers of the parser's mode, using f
+// for the rounding
+func (p *Parser) roundRat(z, x *big.Rat, f *big.Float) (*big.Rat, error) {
+	switch p.Mode {
+	case BigFloatMode:
+		prec := p.Precision
+		if prec == 0 {
+			prec = DefaultPrecision
+		}
+		f.SetPrec(prec).SetMode(p.RoundingMode).SetRat(x)
+		res, _ := f.Rat(z)
+		return res, nil
+	case Float64Mode:
+		res, _ := x.Float64()
+		if math.IsInf(res, 0) {
+			return nil, ErrNotFinite
+		}
+		return z.SetFloat64(res), nil
+	}
+
+	return z.Set(x), nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+// significantBits returns the number of bits of x without trailing zeros, for
+// numbers with a power of two as denominator
+func significantBits(x *big.Rat) int {
+	return x.Num().BitLen() - int(x.Num().TrailingZeroBits())
+}
+
+func TestBigFloatMode(t *testing.T) {
+	p := New()
+	p.Mode = BigFloatMode
+	p.Run("x = 1")
+	for i := 0; i < 1000; i++ {
+		if _, err := p.Run("x = x*1.1 + 0.3"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	x, _ := p.GetVar("x")
+	if bits := significantBits(x); bits > 64 || x.Denom().BitLen() > 1 {
+		t.Errorf("numbers grew in bigfloat mode (got %d bits, denominator %s)", bits, x.Denom())
+	}
+
+	// 1000 roundings lose less than 1000 ulps
+	exact := big.NewRat(1, 1)
+	for i := 0; i < 1000; i++ {
+		exact.Add(exact.Mul(exact, big.NewRat(11, 10)), big.NewRat(3, 10))
+	}
+	diff := new(big.Rat).Quo(new(big.Rat).Sub(x, exact), exact)
+	if diff.Abs(diff).Cmp(big.NewRat(1000, 1<<62)) > 0 {
+		t.Errorf("wrong result in bigfloat mode (expected %s, got %s)", exact.FloatString(5), x.FloatString(5))
+	}
+}
+
+func TestRoundingMode(t *testing.T) {
+	results := map[big.RoundingMode][3]string{
+		big.ToNearestEven: {"171/512", "-171/512", "181/128"},
+		big.ToZero:        {"85/256", "-85/256", "181/128"},
+		big.AwayFromZero:  {"171/512", "-171/512", "91/64"},
+		big.ToNegativeInf: {"85/256", "-171/512", "181/128"},
+		big.ToPositiveInf: {"171/512", "-85/256", "91/64"},
+	}
+
+	p := New()
+	p.Mode = BigFloatMode
+	p.Precision = 8
+	for mode, expected := range results {
+		p.RoundingMode = mode
+		for i, expr := range []string{"1/3", "-1/3", "sqrt(2)"} {
+			if res, err := p.Run(expr); err != nil || res.String() != expected[i] {
+				t.Errorf("wrong result of '%s' rounding %s (expected %s, got %s, %v)", expr, mode, expected[i], res, err)
+			}
+		}
+	}
+}
+
+func TestFloat64Mode(t *testing.T) {
+	p := New()
+	p.Mode = Float64Mode
+
+	// Constants would be added exactly
+	a, b := 0.1, 0.2
+	res, err := p.Run("0.1 + 0.2")
+	if err != nil || res.Cmp(new(big.Rat).SetFloat64(a+b)) != 0 {
+		t.Errorf("wrong result in float64 mode (expected %v, got %s, %v)", a+b, res, err)
+	}
+	if res, _ := p.Run("0.1 + 0.2 == 0.3"); res.Sign() != 0 {
+		t.Errorf("expected 0.1 + 0.2 to differ from 0.3 in float64 mode")
+	}
+	if res, err := p.Run("2**1024"); err == nil {
+		t.Errorf("expected error for overflow in float64 mode (got %s)", res)
+	}
+
+	m, err := p.RunValue("[1, 2] / 3")
+	if err != nil || m.(*Matrix).Data[1].Cmp(new(big.Rat).SetFloat64(2.0/3)) != 0 {
+		t.Errorf("matrix not rounded in float64 mode (got %s, %v)", m, err)
+	}
+}
+
+func TestModePrograms(t *testing.T) {
+	for _, mode := range []NumberMode{ExactMode, BigFloatMode, Float64Mode} {
+		p := New()
+		p.Mode = mode
+		p.Precision = 24
+		p.Variables["x"] = big.NewRat(2, 3)
+
+		for _, expr := range []string{"x/7 + 0.1", "sqrt(x) * 3", "(x + 1/3)**3 % 0.3"} {
+			expected, err := p.Run(expr)
+			if err != nil {
+				t.Errorf("unexpected error running '%s' in %s mode: %s", expr, mode, err)
+				continue
+			}
+
+			prog, _ := p.Compile(expr)
+			if res, err := prog.Run(); err != nil || res.Cmp(expected) != 0 {
+				t.Errorf("wrong result of program '%s' in %s mode (expected %s, got %s, %v)", expr, mode, expected, res, err)
+			}
+		}
+	}
+}
+
+func TestParseNumberMode(t *testing.T) {
+	for _, mode := range []NumberMode{ExactMode, BigFloatMode, Float64Mode} {
+		if res, err := ParseNumberMode(mode.String()); err != nil || res != mode {
+			t.Errorf("wrong mode parsing '%s' (got %s, %v)", mode, res, err)
+		}
+	}
+
+	if _, err := ParseNumberMode("decimal"); err == nil {
+		t.Errorf("expected error parsing invalid mode")
+	}
+}
@@ -0,0 +1,367 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// primeRounds is the number of Miller-Rabin rounds used for primality tests
+const primeRounds = 20
+
+// maxRhoIterations bounds the work done by Pollard's rho so factoring a huge
+// semiprime gives an error instead of hanging
+const maxRhoIterations = 1 << 22
+
+var (
+	ErrNoPrime         = errors.New("No prime below 2")
+	ErrFactorLimit     = errors.New("Number too hard to factor")
+	ErrNegativeRoot    = errors.New("Even root of a negative number")
+	ErrInvalidBase     = errors.New("Base must be at least 2")
+	ErrInvalidModulus  = errors.New("Modulus must be positive")
+	ErrInvalidRootDeg  = errors.New("Root degree must be positive")
+	ErrRootDegTooLarge = errors.New("Root degree too large")
+	ErrNoPrimeFactors  = errors.New("0 and ±1 have no prime factors")
+	ErrJacobiEvenDenom = errors.New("Jacobi symbol needs an odd positive denominator")
+
+	bigOne = big.NewInt(1)
+	bigTwo = big.NewInt(2)
+)
+
+// Lcm calculates the least common multiple of a and b. The result is always
+// non-negative.
+func Lcm(a, b *big.Int) *big.Int {
+	if a.Sign() == 0 || b.Sign() == 0 {
+		return new(big.Int)
+	}
+
+	gcd := new(big.Int).GCD(nil, nil, new(big.Int).Abs(a), new(big.Int).Abs(b))
+	res := new(big.Int).Quo(a, gcd)
+	res.Mul(res, b)
+
+	return res.Abs(res)
+}
+
+// IsPrime reports whether n is prime using the Miller-Rabin test. The test is
+// exact for n < 2**64 and has a negligible error rate above that.
+func IsPrime(n *big.Int) bool {
+	return n.Sign() > 0 && n.ProbablyPrime(primeRounds)
+}
+
+// NextPrime returns the smallest prime larger than n
+func NextPrime(n *big.Int) *big.Int {
+	if n.Cmp(bigTwo) < 0 {
+		return big.NewInt(2)
+	}
+
+	// Start at the next odd number
+	p := new(big.Int).Add(n, bigOne)
+	if p.Bit(0) == 0 {
+		p.Add(p, bigOne)
+	}
+
+	for !IsPrime(p) {
+		p.Add(p, bigTwo)
+	}
+
+	return p
+}
+
+// PrevPrime returns the largest prime smaller than n
+func PrevPrime(n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) <= 0 {
+		return nil, ErrNoPrime
+	}
+	if n.Cmp(big.NewInt(3)) == 0 {
+		return big.NewInt(2), nil
+	}
+
+	p := new(big.Int).Sub(n, bigOne)
+	if p.Bit(0) == 0 {
+		p.Sub(p, bigOne)
+	}
+
+	for !IsPrime(p) {
+		p.Sub(p, bigTwo)
+	}
+
+	return p, nil
+}
+
+// Factor returns the prime factors of n in ascending order, with multiplicity.
+// The sign of n is ignored, and 0 and 1 have no prime factors.
+func Factor(n *big.Int) ([]*big.Int, error) {
+	var factors []*big.Int
+
+	n = new(big.Int).Abs(n)
+	if n.Cmp(bigTwo) < 0 {
+		return factors, nil
+	}
+
+	// Take out small factors first, Pollard's rho is slow for those
+	rem := new(big.Int)
+	for d := int64(2); d < 1000; d++ {
+		div := big.NewInt(d)
+		for {
+			q, r := new(big.Int).QuoRem(n, div, rem)
+			if r.Sign() != 0 {
+				break
+			}
+			factors = append(factors, div)
+			n = q
+		}
+	}
+
+	if err := factorRho(n, &factors); err != nil {
+		return nil, err
+	}
+
+	sort.Sort(bigInts(factors))
+
+	return factors, nil
+}
+
+func factorRho(n *big.Int, factors *[]*big.Int) error {
+	if n.Cmp(bigOne) == 0 {
+		return nil
+	}
+
+	if IsPrime(n) {
+		*factors = append(*factors, n)
+		return nil
+	}
+
+	d, err := pollardRho(n)
+	if err != nil {
+		return err
+	}
+
+	if err := factorRho(d, factors); err != nil {
+		return err
+	}
+
+	return factorRho(new(big.Int).Quo(n, d), factors)
+}
+
+// pollardRho finds a non-trivial divisor of the composite number n using
+// Brent's variant of Pollard's rho
+func pollardRho(n *big.Int) (*big.Int, error) {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+
+	// Perfect powers are never found by rho, check for squares at least
+	if root := Isqrt(n); new(big.Int).Mul(root, root).Cmp(n) == 0 {
+		return root, nil
+	}
+
+	var (
+		d   = new(big.Int)
+		tmp = new(big.Int)
+	)
+
+	iterations := 0
+	for c := int64(1); ; c++ {
+		x := big.NewInt(2)
+		y := big.NewInt(2)
+		d.SetInt64(1)
+
+		step := func(v *big.Int) {
+			v.Mul(v, v)
+			v.Add(v, big.NewInt(c))
+			v.Mod(v, n)
+		}
+
+		for d.Cmp(bigOne) == 0 {
+			if iterations++; iterations > maxRhoIterations {
+				return nil, ErrFactorLimit
+			}
+
+			step(x)
+			step(y)
+			step(y)
+			d.GCD(nil, nil, tmp.Abs(tmp.Sub(x, y)), n)
+		}
+
+		if d.Cmp(n) != 0 {
+			return d, nil
+		}
+	}
+}
+
+// Totient calculates Euler's totient function of n, the number of integers up
+// to n that are coprime to n
+func Totient(n *big.Int) (*big.Int, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf("Totient is only defined for positive integers")
+	}
+
+	factors, err := Factor(n)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(big.Int).Set(n)
+	for i, p := range factors {
+		if i > 0 && factors[i-1].Cmp(p) == 0 {
+			continue
+		}
+		res.Quo(res, p)
+		res.Mul(res, new(big.Int).Sub(p, bigOne))
+	}
+
+	return res, nil
+}
+
+// ModPow calculates b**e mod m. Negative exponents use the modular inverse of
+// b.
+func ModPow(b, e, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		return nil, ErrInvalidModulus
+	}
+
+	if e.Sign() < 0 {
+		inv, err := ModInverse(b, m)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).Exp(inv, new(big.Int).Neg(e), m), nil
+	}
+
+	return new(big.Int).Exp(new(big.Int).Mod(b, m), e, m), nil
+}
+
+// ModInverse calculates the inverse of a modulo m
+func ModInverse(a, m *big.Int) (*big.Int, error) {
+	if m.Sign() <= 0 {
+		return nil, ErrInvalidModulus
+	}
+
+	g, x, _ := ExtendedGcd(new(big.Int).Mod(a, m), m)
+	if g.Cmp(bigOne) != 0 {
+		return nil, fmt.Errorf("%s has no inverse modulo %s", a, m)
+	}
+
+	return x.Mod(x, m), nil
+}
+
+// ExtendedGcd calculates the greatest common divisor g of a and b, along with
+// x and y so that a*x + b*y = g
+func ExtendedGcd(a, b *big.Int) (g, x, y *big.Int) {
+	oldR, r := new(big.Int).Set(a), new(big.Int).Set(b)
+	oldS, s := big.NewInt(1), big.NewInt(0)
+	oldT, t := big.NewInt(0), big.NewInt(1)
+
+	for r.Sign() != 0 {
+		q := new(big.Int).Quo(oldR, r)
+		oldR, r = r, new(big.Int).Sub(oldR, new(big.Int).Mul(q, r))
+		oldS, s = s, new(big.Int).Sub(oldS, new(big.Int).Mul(q, s))
+		oldT, t = t, new(big.Int).Sub(oldT, new(big.Int).Mul(q, t))
+	}
+
+	if oldR.Sign() < 0 {
+		oldR.Neg(oldR)
+		oldS.Neg(oldS)
+		oldT.Neg(oldT)
+	}
+
+	return oldR, oldS, oldT
+}
+
+// Jacobi calculates the Jacobi symbol (a/n) for odd positive n
+func Jacobi(a, n *big.Int) (int, error) {
+	if n.Sign() <= 0 || n.Bit(0) == 0 {
+		return 0, ErrJacobiEvenDenom
+	}
+
+	return big.Jacobi(a, n), nil
+}
+
+// Isqrt calculates the integer square root of n, the largest integer whose
+// square doesn't exceed n. n must not be negative.
+func Isqrt(n *big.Int) *big.Int {
+	return new(big.Int).Sqrt(n)
+}
+
+// Iroot calculates the integer k-th root of n, rounded towards zero. Negative
+// n are allowed for odd k.
+func Iroot(n *big.Int, k int) (*big.Int, error) {
+	if k <= 0 {
+		return nil, ErrInvalidRootDeg
+	}
+
+	if n.Sign() < 0 {
+		if k%2 == 0 {
+			return nil, ErrNegativeRoot
+		}
+		root, err := Iroot(new(big.Int).Neg(n), k)
+		if err != nil {
+			return nil, err
+		}
+		return root.Neg(root), nil
+	}
+
+	if n.Sign() == 0 || k == 1 {
+		return new(big.Int).Set(n), nil
+	}
+
+	// Newton's method, starting from a power of two above the root
+	var (
+		bigK   = big.NewInt(int64(k))
+		bigKm1 = big.NewInt(int64(k - 1))
+		x      = new(big.Int).Lsh(bigOne, uint(n.BitLen()/k+1))
+		tmp    = new(big.Int)
+	)
+
+	for {
+		// y = ((k-1)*x + n/x**(k-1)) / k
+		tmp.Exp(x, bigKm1, nil)
+		tmp.Quo(n, tmp)
+		y := new(big.Int).Mul(x, bigKm1)
+		y.Add(y, tmp)
+		y.Quo(y, bigK)
+
+		if y.Cmp(x) >= 0 {
+			return x, nil
+		}
+		x = y
+	}
+}
+
+// Digits returns the digits of n in the given base, most significant digit
+// first. The sign of n is ignored.
+func Digits(n *big.Int, base *big.Int) ([]*big.Int, error) {
+	if base.Cmp(bigTwo) < 0 {
+		return nil, ErrInvalidBase
+	}
+
+	n = new(big.Int).Abs(n)
+	if n.Sign() == 0 {
+		return []*big.Int{new(big.Int)}, nil
+	}
+
+	var digits []*big.Int
+	for n.Sign() > 0 {
+		digit := new(big.Int)
+		n.QuoRem(n, base, digit)
+		digits = append(digits, digit)
+	}
+
+	// Reverse to most significant digit first
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+
+	return digits, nil
+}
+
+type bigInts []*big.Int
+
+func (b bigInts) Len() int           { return len(b) }
+func (b bigInts) Less(i, j int) bool { return b[i].Cmp(b[j]) < 0 }
+func (b bigInts) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,118 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import "math/big"
+
+// DefaultMaxIterations is the maximum number of iterations of numeric methods
+// like solve
+const DefaultMaxIterations = 1000
+
+// numeric holds the settings of numeric methods like solve and integrate.
+// They calculate with guard bits, and stop when the result is known to the
+// parser's precision or tolerance.
+type numeric struct {
+	prec     uint
+	eps, tol *big.Float
+	maxIter  int
+}
+
+func newNumeric(p *Parser) *numeric {
+	prec := floatPrec(p)
+	n := &numeric{prec: prec + guardBits, maxIter: p.MaxIterations}
+	if n.maxIter <= 0 {
+		n.maxIter = DefaultMaxIterations
+	}
+
+	n.eps = n.float().SetMantExp(newFloat(n.prec, 1), -int(prec))
+	n.tol = n.float().Set(n.eps)
+	if p.Tolerance > 0 {
+		n.tol.SetFloat64(p.Tolerance)
+	}
+
+	return n
+}
+
+func (n *numeric) float() *big.Float {
+	return new(big.Float).SetPrec(n.prec)
+}
+
+func (n *numeric) abs(x *big.Float) *big.Float {
+	return n.float().Abs(x)
+}
+
+// realFunc is an expression evaluated as a function of a single variable
+type realFunc struct {
+	expr *Expr
+	name string
+	prec uint
+	// diff evaluates equations like lhs == rhs as lhs - rhs
+	diff bool
+}
+
+// eval evaluates the function at x
+func (f *realFunc) eval(x *big.Float) (*big.Float, error) {
+	xRat, _ := x.Rat(nil)
+	vars := map[string]*big.Rat{f.name: xRat}
+
+	var (
+		res *big.Rat
+		err error
+	)
+	if eq, ok := f.expr.node.(*BinaryNode); ok && f.diff && eq.Op.Is(EqEq) {
+		res, err = f.evalDiff(eq, vars)
+	} else {
+		res, err = f.expr.EvalRat(vars)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Float).SetPrec(f.prec).SetRat(res), nil
+}
+
+func (f *realFunc) evalDiff(eq *BinaryNode, vars map[string]*big.Rat) (*big.Rat, error) {
+	scope := f.expr.bind(vars)
+
+	lhs, err := f.expr.p.evalNode(eq.Lhs, scope)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := f.expr.p.evalNode(eq.Rhs, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	lhsRat, err := scalar(lhs)
+	if err != nil {
+		return nil, err
+	}
+	rhsRat, err := scalar(rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Rat).Sub(lhsRat, rhsRat), nil
+}
+
+// funcArgs gets the expression and variable of a numeric method like
+// integrate(f, x, a, b), and evaluates the remaining arguments
+func funcArgs(n *numeric, args []*Expr) (*realFunc, []*big.Float, error) {
+	name, err := args[1].Ident()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]*big.Float, len(args)-2)
+	for i, arg := range args[2:] {
+		x, err := arg.EvalRat(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = n.float().SetRat(x)
+	}
+
+	return &realFunc{expr: args[0], name: name, prec: n.prec}, values, nil
+}
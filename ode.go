@@ -0,0 +1,379 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+// DefaultODETolerance is the tolerance of ode and odetable if the parser has
+// no Tolerance. Their method is accurate to a few more digits than float64 at
+// best.
+const DefaultODETolerance = 1e-10
+
+// ErrStepTooSmall is returned when an ODE solver can't reach the tolerance,
+// like for singular or very stiff equations
+var ErrStepTooSmall = errors.New("Step size too small")
+
+// dormandPrince is the Butcher tableau of the Dormand-Prince 5(4) method. The
+// last row of a is the fifth order solution, which is evaluated again as the
+// first stage of the next step. e holds the difference with the embedded
+// fourth order solution.
+var dormandPrince = struct {
+	c []string
+	a [][]string
+	e []string
+}{
+	c: []string{"0", "1/5", "3/10", "4/5", "8/9", "1", "1"},
+	a: [][]string{
+		{},
+		{"1/5"},
+		{"3/40", "9/40"},
+		{"44/45", "-56/15", "32/9"},
+		{"19372/6561", "-25360/2187", "64448/6561", "-212/729"},
+		{"9017/3168", "-355/33", "46732/5247", "49/176", "-5103/18656"},
+		{"35/384", "0", "500/1113", "125/192", "-2187/6784", "11/84"},
+	},
+	e: []string{"71/57600", "0", "-71/16695", "71/1920", "-17253/339200", "22/525", "-1/40"},
+}
+
+// odeSystem is a system of first order differential equations y' = f(t, y),
+// with f an expression in t and the variables of y
+type odeSystem struct {
+	expr  *Expr
+	t     string
+	names []string
+	prec  uint
+}
+
+// eval evaluates the derivatives of y at t
+func (s *odeSystem) eval(t *big.Float, y []*big.Float) ([]*big.Float, error) {
+	vars := make(map[string]*big.Rat, len(y)+1)
+	vars[s.t], _ = t.Rat(nil)
+	for i, yi := range y {
+		vars[s.names[i]], _ = yi.Rat(nil)
+	}
+
+	val, err := s.expr.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var derivs []*big.Rat
+	switch val := val.(type) {
+	case *big.Rat:
+		derivs = []*big.Rat{val}
+	case *Matrix:
+		derivs = val.Data
+	}
+	if len(derivs) != len(y) {
+		return nil, ErrDimMismatch
+	}
+
+	res := make([]*big.Float, len(derivs))
+	for i, d := range derivs {
+		res[i] = new(big.Float).SetPrec(s.prec).SetRat(d)
+	}
+
+	return res, nil
+}
+
+// rk45 solves an ODE system with the Dormand-Prince method, adapting the step
+// size to keep the estimated error of every step below the tolerance
+type rk45 struct {
+	*numeric
+	sys   *odeSystem
+	tol   float64
+	t     *big.Float
+	y     []*big.Float
+	deriv []*big.Float
+	h     *big.Float
+	steps int
+
+	c []*big.Float
+	a [][]*big.Float
+	e []*big.Float
+}
+
+func newRK45(p *Parser, sys *odeSystem, t0, t1 *big.Float, y0 []*big.Float) (*rk45, error) {
+	r := &rk45{numeric: newNumeric(p), sys: sys, tol: p.Tolerance, t: t0, y: y0}
+	if r.tol <= 0 {
+		r.tol = DefaultODETolerance
+	}
+
+	parse := func(fracs []string) []*big.Float {
+		res := make([]*big.Float, len(fracs))
+		for i, frac := range fracs {
+			x, _ := new(big.Rat).SetString(frac)
+			res[i] = r.float().SetRat(x)
+		}
+		return res
+	}
+	r.c = parse(dormandPrince.c)
+	r.e = parse(dormandPrince.e)
+	for _, row := range dormandPrince.a {
+		r.a = append(r.a, parse(row))
+	}
+
+	var err error
+	if r.deriv, err = sys.eval(t0, y0); err != nil {
+		return nil, err
+	}
+
+	// Start with a hundredth of the interval, the step size adapts quickly
+	r.h = r.float().Sub(t1, t0)
+	r.h.Quo(r.h, newFloat(r.prec, 100))
+
+	return r, nil
+}
+
+// advance steps until tEnd, calling step after every step
+func (r *rk45) advance(tEnd *big.Float, step func()) error {
+	dir := r.h.Sign()
+	for r.t.Cmp(tEnd) != 0 {
+		if r.steps++; r.steps > r.maxIter {
+			return ErrNoConvergence
+		}
+
+		// Don't step past the end
+		h := r.float().Set(r.h)
+		if left := r.float().Sub(tEnd, r.t); h.Cmp(left)*dir > 0 {
+			h = left
+		}
+
+		if err := r.step(h); err != nil {
+			return err
+		}
+		if step != nil {
+			step()
+		}
+	}
+
+	return nil
+}
+
+// step does a single step of at most h, retrying with smaller steps until the
+// error is small enough. The next step size is set from the error.
+func (r *rk45) step(h *big.Float) error {
+	for {
+		next := r.float().Add(r.t, h)
+		if next.Cmp(r.t) == 0 {
+			return ErrStepTooSmall
+		}
+
+		// Evaluate the stages, the last one being the new solution
+		k := [][]*big.Float{r.deriv}
+		var y []*big.Float
+		for i := 1; i < len(r.a); i++ {
+			y = make([]*big.Float, len(r.y))
+			for j := range r.y {
+				sum := r.float()
+				for s, a := range r.a[i] {
+					sum.Add(sum, r.float().Mul(a, k[s][j]))
+				}
+				y[j] = sum.Mul(sum, h)
+				y[j].Add(y[j], r.y[j])
+			}
+
+			ts := r.float().Mul(r.c[i], h)
+			deriv, err := r.sys.eval(ts.Add(ts, r.t), y)
+			if err != nil {
+				return err
+			}
+			k = append(k, deriv)
+		}
+
+		// Error relative to the tolerance, scaled by the size of the solution
+		errNorm := 0.0
+		for j := range r.y {
+			est := r.float()
+			for s, e := range r.e {
+				est.Add(est, r.float().Mul(e, k[s][j]))
+			}
+			est.Mul(est, h)
+
+			scale := r.abs(r.y[j])
+			if yj := r.abs(y[j]); yj.Cmp(scale) > 0 {
+				scale = yj
+			}
+			scale.Mul(scale, r.float().SetFloat64(r.tol))
+			scale.Add(scale, r.float().SetFloat64(r.tol))
+
+			ratio, _ := est.Quo(r.abs(est), scale).Float64()
+			errNorm = math.Max(errNorm, ratio)
+		}
+
+		// Grow or shrink the step by at most a factor 5, with a safety factor
+		factor := 5.0
+		if errNorm > 0 {
+			factor = math.Min(5, math.Max(0.2, 0.9*math.Pow(errNorm, -0.2)))
+		}
+		if errNorm <= 1 {
+			// Don't let the step that was shortened to hit the end shrink
+			// the next one
+			if r.abs(h).Cmp(r.abs(r.h)) < 0 && factor < 1 {
+				factor = 1
+			}
+			r.h.Mul(r.h, r.float().SetFloat64(factor))
+			r.t, r.y, r.deriv = next, y, k[len(k)-1]
+			return nil
+		}
+
+		r.h.Mul(h, r.float().SetFloat64(factor))
+		h = r.float().Set(r.h)
+	}
+}
+
+// odeArgs gets the system, initial values and interval of a call like
+// ode(-k*y, y, 10, t, 0, 5). y is a variable or a vector of variables, with y0
+// a number or vector of the same length.
+func odeArgs(p *Parser, args []*Expr) (*rk45, *big.Float, bool, error) {
+	sys := &odeSystem{expr: args[0], prec: floatPrec(p) + guardBits}
+
+	vector := false
+	if m, ok := args[1].Node().(*MatrixNode); ok {
+		vector = true
+		for _, elem := range m.Elems {
+			ident, ok := elem.(*IdentNode)
+			if !ok {
+				return nil, nil, false, ErrExpectingIdent
+			}
+			sys.names = append(sys.names, ident.Name())
+		}
+	} else {
+		name, err := args[1].Ident()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		sys.names = []string{name}
+	}
+
+	var err error
+	if sys.t, err = args[3].Ident(); err != nil {
+		return nil, nil, false, err
+	}
+
+	val, err := args[2].Eval(nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	var y0 []*big.Rat
+	if m, ok := val.(*Matrix); ok && vector {
+		y0 = m.Data
+	} else if x, ok := val.(*big.Rat); ok && !vector {
+		y0 = []*big.Rat{x}
+	}
+	if len(y0) != len(sys.names) {
+		return nil, nil, false, ErrDimMismatch
+	}
+
+	bounds := make([]*big.Float, 2)
+	for i, arg := range args[4:6] {
+		x, err := arg.EvalRat(nil)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		bounds[i] = new(big.Float).SetPrec(sys.prec).SetRat(x)
+	}
+
+	y := make([]*big.Float, len(y0))
+	for i, x := range y0 {
+		y[i] = new(big.Float).SetPrec(sys.prec).SetRat(x)
+	}
+
+	r, err := newRK45(p, sys, bounds[0], bounds[1], y)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	return r, bounds[1], vector, nil
+}
+
+// round rounds a solution to the parser's precision
+func (r *rk45) round(x *big.Float) (*big.Rat, error) {
+	return floatToRat(r.float().Set(x).SetPrec(r.prec - guardBits))
+}
+
+// ode solves a differential equation y' = f(t, y) with an initial value, like
+// ode(-k*y, y, 10, t, 0, 5), returning y at the end of the interval
+func ode(p *Parser, args []*Expr) (Value, error) {
+	r, end, vector, err := odeArgs(p, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.advance(end, nil); err != nil {
+		return nil, err
+	}
+
+	res := NewMatrix(1, len(r.y))
+	for i, y := range r.y {
+		if res.Data[i], err = r.round(y); err != nil {
+			return nil, err
+		}
+	}
+
+	if !vector {
+		return res.Data[0], nil
+	}
+	return res, nil
+}
+
+// odetable solves a differential equation like ode, returning a matrix with
+// rows of t followed by y. The rows are the steps taken, or n + 1 equally
+// spaced points if n is given.
+func odetable(p *Parser, args []*Expr) (Value, error) {
+	r, end, _, err := odeArgs(p, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]*big.Float
+	record := func() {
+		row := append([]*big.Float{r.t}, r.y...)
+		rows = append(rows, row)
+	}
+	record()
+
+	if len(args) == 6 {
+		if err := r.advance(end, record); err != nil {
+			return nil, err
+		}
+	} else {
+		n, err := args[6].EvalRat(nil)
+		if err != nil {
+			return nil, err
+		}
+		if !n.IsInt() || n.Sign() <= 0 || !n.Num().IsInt64() {
+			return nil, errors.New("Expecting a positive integer number of points")
+		}
+
+		// Step to every point in turn, keeping the step size in between
+		start := r.float().Set(r.t)
+		length := r.float().Sub(end, start)
+		count := n.Num().Int64()
+		for i := int64(1); i <= count; i++ {
+			t := r.float().Mul(length, newFloat(r.prec, i))
+			t.Quo(t, newFloat(r.prec, count))
+			if err := r.advance(t.Add(t, start), nil); err != nil {
+				return nil, err
+			}
+			record()
+		}
+	}
+
+	m := NewMatrix(len(rows), len(rows[0]))
+	for i, row := range rows {
+		for j, x := range row {
+			if m.Data[i*m.Cols+j], err = r.round(x); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestODE(t *testing.T) {
+	calls := map[string]string{
+		"ode(-y/2, y, 10, t, 0, 5)":        "0.82084998623898795169",
+		"ode(y, y, 1, t, 0, 1)":            "2.71828182845904523536",
+		"ode(-y, y, 1, t, 1, 0)":           "2.71828182845904523536",
+		"ode(t, y, 0, t, 0, 2)":            "2",
+		"ode(cos(t), y, 0, t, 0, pi/2)":    "1",
+		"ode(t*y, y, 1, t, 0, 2)":          "7.38905609893065022723",
+		"ode(1 - y**2, y, 0, t, 0, 1)":     "0.76159415595576488812",
+		"ode(1, y, 3, t, 5, 5)":            "3",
+		"ode(-y + sin(t), y, 1, t, 0, 10)": "0.14759330898818507",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error solving '%s': %s", expr, err)
+			continue
+		}
+
+		if !closeTo(res, expected, 1e-8) {
+			t.Errorf("wrong solution '%s' (expected %s, got %s)", expr, expected, res.FloatString(20))
+		}
+	}
+}
+
+func TestODESystem(t *testing.T) {
+	// x'' = -x with x(0) = 1 is cos(t)
+	p := New()
+	res, err := p.RunValue("ode([v, -x], [x, v], [1, 0], t, 0, pi)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := res.(*Matrix)
+	if !ok || m.Rows != 1 || m.Cols != 2 || !closeTo(m.At(0, 0), "-1", 1e-8) || !closeTo(m.At(0, 1), "0", 1e-8) {
+		t.Errorf("wrong solution of system: %s", res)
+	}
+}
+
+func TestODETable(t *testing.T) {
+	p := New()
+	res, err := p.RunValue("odetable(-y, y, 1, t, 0, 1, 4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, ok := res.(*Matrix)
+	if !ok || m.Rows != 5 || m.Cols != 2 {
+		t.Fatalf("wrong table shape: %s", res)
+	}
+	expected := []string{"1", "0.77880078307140486825", "0.60653065971263342360",
+		"0.47236655274101470714", "0.36787944117144232160"}
+	for i, y := range expected {
+		if m.At(i, 0).Cmp(big.NewRat(int64(i), 4)) != 0 || !closeTo(m.At(i, 1), y, 1e-8) {
+			t.Errorf("wrong row %d of table: [%s, %s]", i, m.At(i, 0), m.At(i, 1).FloatString(20))
+		}
+	}
+
+	// Without a number of points every step is a row
+	res, err = p.RunValue("odetable([v, -x], [x, v], [0, 1], t, 0, 2)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := res.(*Matrix); !ok || m.Rows < 3 || m.Cols != 3 || m.At(m.Rows-1, 0).Cmp(big.NewRat(2, 1)) != 0 {
+		t.Errorf("wrong table of steps: %s", res)
+	}
+
+	// The variables aren't changed
+	if _, err := p.GetVar("t"); err == nil {
+		t.Error("expected t to be undefined after solving")
+	}
+}
+
+func TestBadODE(t *testing.T) {
+	badCalls := []string{
+		"ode(-y, y, 1, t, 0)", "ode(-y, 1, 1, t, 0, 1)", "ode(-y, y, 1, 2, 0, 1)",
+		"ode(-y, y, [1, 2], t, 0, 1)", "ode([v, -x], [x, v], 1, t, 0, 1)",
+		"ode([1, 2], y, 1, t, 0, 1)", "ode([v, -x], [x, 2], [1, 0], t, 0, 1)",
+		"ode(y**2, y, 1, t, 0, 2)", "ode(sqrt(-y), y, 1, t, 0, 1)",
+		"odetable(-y, y, 1, t, 0, 1, 0)", "odetable(-y, y, 1, t, 0, 1, 1.5)",
+	}
+
+	for _, expr := range badCalls {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("expected error on bad differential equation '%s'", expr)
+		}
+	}
+}
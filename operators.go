@@ -99,15 +99,24 @@ func executeExpression(operator *Token, lhs, rhs *big.Rat) (*big.Rat, error) {
 	case Mul, MulEq:
 		result.Mul(lhs, rhs)
 	case Pow, PowEq:
-		if lhs.IsInt() && rhs.IsInt() {
-			intResult := new(big.Int)
-			intResult.Set(lhs.Num())
-			intResult.Exp(intResult, rhs.Num(), nil)
-			result.SetInt(intResult)
+		if rhs.IsInt() {
+			// Integer exponents are calculated exactly, also for fractions
+			exp := new(big.Int).Abs(rhs.Num())
+			num := new(big.Int).Exp(lhs.Num(), exp, nil)
+			den := new(big.Int).Exp(lhs.Denom(), exp, nil)
+			result.SetFrac(num, den)
+
+			// Negative exponents give the reciprocal
+			if rhs.Sign() < 0 {
+				if result.Sign() == 0 {
+					return nil, ErrDivisionByZero
+				}
+				result.Inv(result)
+			}
 		} else {
 			lhsFloat, _ := lhs.Float64()
 			rhsFloat, _ := rhs.Float64()
-			result.SetFloat64(math.Pow(lhsFloat, rhsFloat))
+			return floatResult(math.Pow(lhsFloat, rhsFloat))
 		}
 	case Rem, RemEq:
 		if rhs.Sign() == 0 {
@@ -155,3 +164,92 @@ func boolToRat(b bool) *big.Rat {
 	}
 	return RatFalse
 }
+
+// Apply executes an operator on numbers, like in an expression. lhs is nil
+// for unary operators.
+//
+// Example:
+//     res, err := mathcat.Apply(mathcat.Pow, big.NewRat(2, 3), big.NewRat(2, 1)) // 4/9
+func Apply(op TokenType, lhs, rhs *big.Rat) (*big.Rat, error) {
+	tok := &Token{Type: op, Value: op.String()}
+	if o, ok := operators[op]; !ok || o.unary != (lhs == nil) && op != Eq {
+		return nil, fmt.Errorf("Invalid operator ‘%s’", tok)
+	}
+
+	return executeExpression(tok, lhs, rhs)
+}
+
+// applyOperator executes an expression on numbers or matrices. lhs is nil for
+// unary operators.
+func applyOperator(operator *Token, lhs, rhs Value) (Value, error) {
+	lhsRat, lhsIsRat := lhs.(*big.Rat)
+	rhsRat, rhsIsRat := rhs.(*big.Rat)
+	if rhsIsRat && (lhs == nil || lhsIsRat) {
+		return executeExpression(operator, lhsRat, rhsRat)
+	}
+
+	return executeMatrixExpression(operator, lhs, rhs)
+}
+
+// applyOperator executes an expression like applyOperator, rounding the result
+// to the numbers of the parser's mode
+func (p *Parser) applyOperator(operator *Token, lhs, rhs Value) (Value, error) {
+	res, err := applyOperator(operator, lhs, rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.round(res)
+}
+
+// Execute a binary or unary expression where at least one side is a matrix
+func executeMatrixExpression(operator *Token, lhs, rhs Value) (Value, error) {
+	lhsMat, lhsIsMat := lhs.(*Matrix)
+	rhsMat, rhsIsMat := rhs.(*Matrix)
+	lhsRat, _ := lhs.(*big.Rat)
+	rhsRat, _ := rhs.(*big.Rat)
+
+	switch operator.Type {
+	case Eq:
+		return rhs, nil
+	case UnaryMin:
+		return rhsMat.Scale(big.NewRat(-1, 1)), nil
+	case Add, AddEq:
+		if lhsIsMat && rhsIsMat {
+			return lhsMat.Add(rhsMat)
+		}
+	case Sub, SubEq:
+		if lhsIsMat && rhsIsMat {
+			return lhsMat.Sub(rhsMat)
+		}
+	case Mul, MulEq:
+		switch {
+		case lhsIsMat && rhsIsMat:
+			return lhsMat.Mul(rhsMat)
+		case lhsIsMat:
+			return lhsMat.Scale(rhsRat), nil
+		default:
+			return rhsMat.Scale(lhsRat), nil
+		}
+	case Div, DivEq:
+		if lhsIsMat && !rhsIsMat {
+			if rhsRat.Sign() == 0 {
+				return nil, ErrDivisionByZero
+			}
+			return lhsMat.Scale(new(big.Rat).Inv(rhsRat)), nil
+		}
+	case Pow, PowEq:
+		if lhsIsMat && !rhsIsMat {
+			if !rhsRat.IsInt() {
+				return nil, fmt.Errorf("Expecting an integer for ‘%s’ on a matrix", operator)
+			}
+			return lhsMat.Pow(rhsRat.Num())
+		}
+	case EqEq:
+		return boolToRat(lhsIsMat && rhsIsMat && lhsMat.Equal(rhsMat)), nil
+	case NotEq:
+		return boolToRat(!(lhsIsMat && rhsIsMat && lhsMat.Equal(rhsMat))), nil
+	}
+
+	return nil, fmt.Errorf("Invalid operation ‘%s’ on a matrix", operator)
+}
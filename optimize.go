@@ -0,0 +1,447 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// ErrOptimizeArgs is returned when the arguments of minimize and friends
+// aren't variables of the expression, or a variable with two bounds
+var ErrOptimizeArgs = errors.New("Expecting variables of the expression, or one variable with bounds")
+
+// multiFunc is an expression evaluated as a function of several variables
+type multiFunc struct {
+	expr  *Expr
+	names []string
+	prec  uint
+	// neg negates the function, so maximizing is minimizing
+	neg bool
+}
+
+// eval evaluates the function at the point xs
+func (f *multiFunc) eval(xs []*big.Float) (*big.Float, error) {
+	vars := make(map[string]*big.Rat, len(xs))
+	for i, x := range xs {
+		vars[f.names[i]], _ = x.Rat(nil)
+	}
+
+	res, err := f.expr.EvalRat(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	y := new(big.Float).SetPrec(f.prec).SetRat(res)
+	if f.neg {
+		y.Neg(y)
+	}
+
+	return y, nil
+}
+
+// optimum is the location and value of a minimum or maximum
+type optimum struct {
+	f     *multiFunc
+	at    []*big.Rat
+	value *big.Rat
+}
+
+// optimize finds a minimum of f, or a maximum if neg is set. The arguments are
+// the expression followed by its variables, or by a single variable and the
+// bounds between which to search.
+func optimize(p *Parser, args []*Expr, neg bool) (*optimum, error) {
+	names := make(map[string]bool)
+	Inspect(args[0].Node(), func(node Node) bool {
+		if ident, ok := node.(*IdentNode); ok {
+			names[ident.Name()] = true
+		}
+		return true
+	})
+
+	n := newNumeric(p)
+	f := &multiFunc{expr: args[0], prec: n.prec, neg: neg}
+	if _, err := args[1].Ident(); err != nil {
+		return nil, err
+	}
+
+	rest := args[1:]
+	for ; len(rest) > 0; rest = rest[1:] {
+		name, err := rest[0].Ident()
+		if err != nil || !names[name] {
+			break
+		}
+		f.names = append(f.names, name)
+	}
+
+	var (
+		at  []*big.Float
+		err error
+	)
+	switch {
+	case len(f.names) == 1 && len(rest) == 2:
+		bounds := make([]*big.Float, 2)
+		for i, arg := range rest {
+			x, err := arg.EvalRat(nil)
+			if err != nil {
+				return nil, err
+			}
+			bounds[i] = n.float().SetRat(x)
+		}
+
+		var x *big.Float
+		if x, err = n.golden(f, bounds[0], bounds[1]); err == nil {
+			at = []*big.Float{x}
+		}
+	case len(f.names) > 0 && len(rest) == 0:
+		start := make([]*big.Float, len(f.names))
+		for i, name := range f.names {
+			start[i] = n.float()
+			if x, ok := p.Variables[name]; ok {
+				start[i].SetRat(x)
+			}
+		}
+
+		at, err = n.nelderMead(f, start)
+	default:
+		return nil, ErrOptimizeArgs
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The location is only known to about half the precision, as functions
+	// are flat near an optimum. Take the simplest number within its tolerance
+	// and evaluate there, so optima at numbers like 1/2 are found exactly.
+	o := &optimum{f: f, at: make([]*big.Rat, len(at))}
+	for i, x := range at {
+		tol := n.xtol(x)
+		lo, _ := n.float().Sub(x, tol).Rat(nil)
+		hi, _ := n.float().Add(x, tol).Rat(nil)
+		o.at[i] = simplestBetween(lo, hi)
+		at[i].SetRat(o.at[i])
+	}
+
+	value, err := f.eval(at)
+	if err != nil {
+		return nil, err
+	}
+	if neg {
+		value.Neg(value)
+	}
+	if o.value, err = floatToRat(value.SetPrec(floatPrec(p))); err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// simplestBetween returns the number with the smallest denominator and
+// numerator between lo and hi, using their continued fractions
+func simplestBetween(lo, hi *big.Rat) *big.Rat {
+	switch {
+	case hi.Sign() < 0:
+		res := simplestBetween(new(big.Rat).Neg(hi), new(big.Rat).Neg(lo))
+		return res.Neg(res)
+	case lo.Sign() <= 0:
+		return new(big.Rat)
+	}
+
+	// The integer part, or the first integer in the interval if there is one
+	floor := new(big.Int).Quo(lo.Num(), lo.Denom())
+	res := new(big.Rat).SetInt(floor)
+	if res.Cmp(lo) == 0 {
+		return res
+	}
+	if next := new(big.Rat).SetInt(floor.Add(floor, big.NewInt(1))); next.Cmp(hi) <= 0 {
+		return next
+	}
+
+	// Continue with the reciprocals of the fractional parts
+	frac := simplestBetween(
+		new(big.Rat).Inv(new(big.Rat).Sub(hi, res)),
+		new(big.Rat).Inv(new(big.Rat).Sub(lo, res)),
+	)
+	return res.Add(res, frac.Inv(frac))
+}
+
+// location returns the location of the optimum as a number for a single
+// variable, or as a row vector
+func (o *optimum) location() Value {
+	if len(o.at) == 1 {
+		return o.at[0]
+	}
+
+	m := NewMatrix(1, len(o.at))
+	copy(m.Data, o.at)
+	return m
+}
+
+// sqrtEps returns the square root of the machine epsilon, the relative
+// accuracy with which the location of an optimum can be found
+func (n *numeric) sqrtEps() *big.Float {
+	return n.float().SetMantExp(newFloat(n.prec, 1), n.eps.MantExp(nil)/2)
+}
+
+// xtol returns the tolerance of the location x of an optimum
+func (n *numeric) xtol(x *big.Float) *big.Float {
+	tol := n.abs(x)
+	tol.Add(tol, newFloat(n.prec, 1))
+	tol.Mul(tol, n.sqrtEps())
+	if tol.Cmp(n.tol) < 0 {
+		tol.Set(n.tol)
+	}
+
+	return tol
+}
+
+// golden finds a minimum of f between a and b with golden-section search,
+// which shrinks the interval by the golden ratio every step
+func (n *numeric) golden(f *multiFunc, a, b *big.Float) (*big.Float, error) {
+	if a.Cmp(b) > 0 {
+		a, b = b, a
+	}
+
+	// 1/phi = (sqrt(5) - 1) / 2
+	invPhi := n.float().Sqrt(newFloat(n.prec, 5))
+	invPhi.Sub(invPhi, newFloat(n.prec, 1))
+	invPhi = half(invPhi)
+
+	inner := func(from, to *big.Float) (*big.Float, *big.Float, error) {
+		x := n.float().Sub(to, from)
+		x.Mul(x, invPhi)
+		x.Add(x, from)
+
+		fx, err := f.eval([]*big.Float{x})
+		return x, fx, err
+	}
+
+	c, fc, err := inner(b, a)
+	if err != nil {
+		return nil, err
+	}
+	d, fd, err := inner(a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n.maxIter; i++ {
+		best := d
+		if fc.Cmp(fd) < 0 {
+			best = c
+		}
+		if n.float().Sub(b, a).Cmp(n.xtol(best)) <= 0 {
+			return best, nil
+		}
+
+		// Keep the part of the interval around the lowest point
+		if fc.Cmp(fd) < 0 {
+			b, d, fd = d, c, fc
+			if c, fc, err = inner(b, a); err != nil {
+				return nil, err
+			}
+		} else {
+			a, c, fc = c, d, fd
+			if d, fd, err = inner(a, b); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// simplex holds the points of a simplex with their function values, sortable
+// by function value
+type simplex struct {
+	points [][]*big.Float
+	values []*big.Float
+}
+
+func (s *simplex) Len() int           { return len(s.points) }
+func (s *simplex) Less(i, j int) bool { return s.values[i].Cmp(s.values[j]) < 0 }
+func (s *simplex) Swap(i, j int) {
+	s.points[i], s.points[j] = s.points[j], s.points[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+// nelderMead finds a minimum of f near start with the Nelder-Mead method,
+// which moves a simplex downhill by reflecting, expanding and contracting it.
+// Points where f can't be evaluated, like outside of its domain, are treated
+// as infinitely high.
+func (n *numeric) nelderMead(f *multiFunc, start []*big.Float) ([]*big.Float, error) {
+	eval := func(x []*big.Float) *big.Float {
+		y, err := f.eval(x)
+		if err != nil {
+			return n.float().SetInf(false)
+		}
+		return y
+	}
+
+	// along returns from + t*(to - from)
+	along := func(from, to []*big.Float, t float64) []*big.Float {
+		res := make([]*big.Float, len(from))
+		for i := range from {
+			res[i] = n.float().Sub(to[i], from[i])
+			res[i].Mul(res[i], n.float().SetFloat64(t))
+			res[i].Add(res[i], from[i])
+		}
+		return res
+	}
+
+	first, err := f.eval(start)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start with steps of 5% along every axis, or 1/4 for zero coordinates
+	s := &simplex{points: [][]*big.Float{start}, values: []*big.Float{first}}
+	for i := range start {
+		x := make([]*big.Float, len(start))
+		for j := range start {
+			x[j] = n.float().Set(start[j])
+		}
+		if x[i].Sign() == 0 {
+			x[i].SetFloat64(0.25)
+		} else {
+			x[i].Mul(x[i], n.float().SetFloat64(1.05))
+		}
+
+		s.points = append(s.points, x)
+		s.values = append(s.values, eval(x))
+	}
+
+	worst := len(s.points) - 1
+	for iter := 0; iter < n.maxIter; iter++ {
+		sort.Sort(s)
+		best := s.points[0]
+
+		if s.values[worst].IsInf() {
+			// The simplex hasn't left the area where f is undefined yet
+		} else if s.converged(n) {
+			return best, nil
+		}
+
+		// Centroid of all points except the worst
+		centroid := make([]*big.Float, len(best))
+		for i := range centroid {
+			centroid[i] = n.float()
+			for _, x := range s.points[:worst] {
+				centroid[i].Add(centroid[i], x[i])
+			}
+			centroid[i].Quo(centroid[i], newFloat(n.prec, int64(worst)))
+		}
+
+		reflected := along(centroid, s.points[worst], -1)
+		fr := eval(reflected)
+
+		switch {
+		case fr.Cmp(s.values[0]) < 0:
+			expanded := along(centroid, s.points[worst], -2)
+			if fe := eval(expanded); fe.Cmp(fr) < 0 {
+				s.points[worst], s.values[worst] = expanded, fe
+			} else {
+				s.points[worst], s.values[worst] = reflected, fr
+			}
+			continue
+		case fr.Cmp(s.values[worst-1]) < 0:
+			s.points[worst], s.values[worst] = reflected, fr
+			continue
+		}
+
+		// Contract towards the reflected point if it's better than the
+		// worst, otherwise towards the worst
+		if fr.Cmp(s.values[worst]) < 0 {
+			contracted := along(centroid, reflected, 0.5)
+			if fc := eval(contracted); fc.Cmp(fr) <= 0 {
+				s.points[worst], s.values[worst] = contracted, fc
+				continue
+			}
+		} else {
+			contracted := along(centroid, s.points[worst], 0.5)
+			if fc := eval(contracted); fc.Cmp(s.values[worst]) < 0 {
+				s.points[worst], s.values[worst] = contracted, fc
+				continue
+			}
+		}
+
+		// Shrink everything towards the best point
+		for i := 1; i < len(s.points); i++ {
+			s.points[i] = along(best, s.points[i], 0.5)
+			s.values[i] = eval(s.points[i])
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// converged checks if the values and points of a sorted simplex are close
+// enough to the best point
+func (s *simplex) converged(n *numeric) bool {
+	best, value := s.points[0], s.values[0]
+
+	ftol := n.float().Mul(n.eps, n.abs(value))
+	if ftol.Cmp(n.tol) < 0 {
+		ftol.Set(n.tol)
+	}
+	if n.float().Sub(s.values[len(s.values)-1], value).Cmp(ftol) > 0 {
+		return false
+	}
+
+	for _, x := range s.points[1:] {
+		for i := range x {
+			if n.abs(n.float().Sub(x[i], best[i])).Cmp(n.xtol(best[i])) > 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// minimize finds a minimum of an expression, like minimize(x**2 - x, x, 0, 1).
+// The variables are set to the location of the minimum.
+func minimize(p *Parser, args []*Expr) (Value, error) {
+	return optimumValue(p, args, false)
+}
+
+// maximize finds a maximum like minimize finds a minimum
+func maximize(p *Parser, args []*Expr) (Value, error) {
+	return optimumValue(p, args, true)
+}
+
+// argmin returns the location of a minimum of an expression, like
+// argmin(x**2 - x, x, 0, 1)
+func argmin(p *Parser, args []*Expr) (Value, error) {
+	return optimumLocation(p, args, false)
+}
+
+// argmax returns the location of a maximum like argmin
+func argmax(p *Parser, args []*Expr) (Value, error) {
+	return optimumLocation(p, args, true)
+}
+
+func optimumValue(p *Parser, args []*Expr, neg bool) (Value, error) {
+	o, err := optimize(p, args, neg)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range o.f.names {
+		p.setVar(name, o.at[i])
+	}
+
+	return o.value, nil
+}
+
+func optimumLocation(p *Parser, args []*Expr, neg bool) (Value, error) {
+	o, err := optimize(p, args, neg)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.location(), nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	calls := map[string]string{
+		"minimize(x**2 - 4*x, x, 0, 100)":                     "-4/1",
+		"maximize(x*(10 - x), x, 0, 10)":                      "25/1",
+		"argmin(x**2 - 4*x, x, 0, 100)":                       "2/1",
+		"argmin((x + 2.5)**2, x, 0, -10)":                     "-5/2",
+		"argmin(x, x, 2, 5)":                                  "2/1",
+		"argmax(x, x, 2, 5)":                                  "5/1",
+		"argmin(abs(x - 1/3), x)":                             "1/3",
+		"argmin((x - 10**6)**2, x)":                           "1000000/1",
+		"maximize(5 - (p - 3)**2 - (q + 1)**2, p, q)":         "5/1",
+		"argmax(5 - (p - 3)**2 - (q + 1)**2, p, q)":           "[3, -1]",
+		"argmin((1 - x)**2 + 100*(y - x**2)**2, x, y)":        "[1, 1]",
+		"argmin(x**2 + y**2 + z**2 - x - 2*y - 3*z, x, y, z)": "[1/2, 1, 3/2]",
+	}
+
+	for expr, expected := range calls {
+		p := New()
+		res, err := p.RunValue(expr)
+		if err != nil {
+			t.Errorf("unexpected error optimizing '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong optimum '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	numeric := map[string]string{
+		"argmax(sin(x), x, 0, 3)":            "1.5707963",
+		"maximize(sin(x), x, 0, 3)":          "1.0000000",
+		"argmin(x*ln(x), x, 0.1, 1)":         "0.3678794",
+		"minimize(x*ln(x), x, 0.1, 1)":       "-0.3678794",
+		"argmin(e**x - 2*x, x)":              "0.6931472",
+		"minimize(cosh(x - 1) + y**2, x, y)": "1.0000000",
+	}
+
+	for expr, expected := range numeric {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error optimizing '%s': %s", expr, err)
+			continue
+		}
+
+		if res.FloatString(7) != expected {
+			t.Errorf("wrong optimum '%s' (expected %s, got %s)", expr, expected, res.FloatString(7))
+		}
+	}
+}
+
+func TestOptimizeSetsVariables(t *testing.T) {
+	p := New()
+	if _, err := p.Run("minimize((a - 2)**2 + (b + 1)**2, a, b)"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]*big.Rat{"a": big.NewRat(2, 1), "b": big.NewRat(-1, 1)} {
+		if val, err := p.GetVar(name); err != nil || val.Cmp(expected) != 0 {
+			t.Errorf("expected %s to be set to %s, got %v", name, expected, val)
+		}
+	}
+
+	// argmin doesn't set variables, but starts searching at their values
+	p.Run("x = 4")
+	if res, err := p.Run("argmin(x*ln(x), x)"); err != nil || res.FloatString(7) != "0.3678794" {
+		t.Errorf("wrong minimum starting from variable: %v, %v", res, err)
+	}
+	if x, _ := p.GetVar("x"); x.Cmp(big.NewRat(4, 1)) != 0 {
+		t.Errorf("argmin changed variable x to %s", x)
+	}
+}
+
+func TestBadOptimize(t *testing.T) {
+	badCalls := []string{
+		"minimize(x**2)", "minimize(x**2, 1)", "minimize(5, x)", "minimize(x**2, x, 1)",
+		"minimize(x**2 + y**2, x, y, 0, 1)", "argmin(x**2, x, y)", "argmin(x*ln(x), x)",
+		"argmin(x, x)", "argmax(x**2, x)", "minimize([x, 1], x)",
+	}
+
+	for _, expr := range badCalls {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("expected error on bad optimization '%s'", expr)
+		}
+	}
+}
+
+func TestSimplestBetween(t *testing.T) {
+	tests := []struct {
+		lo, hi, expected *big.Rat
+	}{
+		{big.NewRat(3, 10), big.NewRat(4, 10), big.NewRat(1, 3)},
+		{big.NewRat(-4, 10), big.NewRat(-3, 10), big.NewRat(-1, 3)},
+		{big.NewRat(-1, 10), big.NewRat(1, 10), big.NewRat(0, 1)},
+		{big.NewRat(5, 2), big.NewRat(5, 2), big.NewRat(5, 2)},
+		{big.NewRat(314, 100), big.NewRat(315, 100), big.NewRat(22, 7)},
+		{big.NewRat(19, 10), big.NewRat(31, 10), big.NewRat(2, 1)},
+	}
+
+	for _, test := range tests {
+		if res := simplestBetween(test.lo, test.hi); res.Cmp(test.expected) != 0 {
+			t.Errorf("wrong simplest number between %s and %s (expected %s, got %s)",
+				test.lo, test.hi, test.expected, res)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"math/rand"
 )
 
 // Parser holds the lexed tokens, token position, declared variables and stacks
@@ -19,11 +20,49 @@ import (
 type Parser struct {
 	Tokens    Tokens
 	Variables map[string]*big.Rat
+	// Matrices holds the variables that have a matrix value
+	Matrices map[string]*Matrix
+
+	// BitWidth is the word size used by bit manipulation functions like rotl
+	// and clz when no width is passed explicitly
+	BitWidth uint
+	// Precision is the number of mantissa bits used by functions that can't
+	// be calculated exactly, like gamma and erf, and of numbers in
+	// BigFloatMode
+	Precision uint
+	// AngleUnit is the unit trigonometric functions take and return angles in.
+	// The zero value is radians.
+	AngleUnit AngleUnit
+	// Deterministic disallows functions with random results like rand, so the
+	// result of an expression only depends on its input
+	Deterministic bool
+	// Tolerance is the absolute tolerance of numeric methods like solve. The
+	// zero value means 2**-Precision.
+	Tolerance float64
+	// MaxIterations limits the number of iterations of numeric methods. The
+	// zero value means DefaultMaxIterations.
+	MaxIterations int
+	// Mode is the kind of numbers expressions are calculated with. The zero
+	// value is ExactMode.
+	Mode NumberMode
+	// RoundingMode is used to round numbers in BigFloatMode. The zero value
+	// rounds to nearest even.
+	RoundingMode big.RoundingMode
+
+	rng *rand.Rand
 
 	pos int
 	tok *Token
 
 	operands, operators, arity stack
+	// rows holds the positions of row breaks (;) for each matrix literal being
+	// parsed
+	rows stack
+}
+
+// Value is the result of an expression, either a *big.Rat or a *Matrix
+type Value interface {
+	String() string
 }
 
 var (
@@ -35,6 +74,9 @@ var (
 	ErrUnmatchedParentheses = errors.New("Unmatched parentheses")
 	ErrMisplacedComma       = errors.New("Misplaced ‘,’")
 	ErrAssignToLiteral      = errors.New("Can't assign to literal")
+	ErrUnmatchedBrackets    = errors.New("Unmatched brackets")
+	ErrMisplacedSemicolon   = errors.New("Misplaced ‘;’")
+	ErrMatrixResult         = errors.New("Result is a matrix")
 
 	defaultVariables = map[string]*big.Rat{
 		"pi":    new(big.Rat).SetFloat64(math.Pi),
@@ -52,6 +94,9 @@ func New() *Parser {
 	parser := &Parser{}
 
 	parser.Variables = make(map[string]*big.Rat)
+	parser.Matrices = make(map[string]*Matrix)
+	parser.BitWidth = DefaultBitWidth
+	parser.Precision = DefaultPrecision
 
 	for k, v := range defaultVariables {
 		parser.Variables[k] = v
@@ -65,6 +110,19 @@ func New() *Parser {
 // Example:
 //     res, err := mathcat.Eval("2 * 2 * 2") // 8
 func Eval(expr string) (*big.Rat, error) {
+	res, err := EvalValue(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return scalar(res)
+}
+
+// EvalValue evaluates an expression that may have a matrix as result.
+//
+// Example:
+//     res, err := mathcat.EvalValue("[1, 2; 3, 4] * [5; 6]") // [17; 39]
+func EvalValue(expr string) (Value, error) {
 	tokens, err := Lex(expr)
 
 	// If a lexer error occurred don't parse
@@ -86,6 +144,21 @@ func Eval(expr string) (*big.Rat, error) {
 //     p.Run("a += 45")
 //     res, err := p.Run("a + a") // 1200
 func (p *Parser) Run(expr string) (*big.Rat, error) {
+	res, err := p.RunValue(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return scalar(res)
+}
+
+// RunValue executes an expression that may have a matrix as result on an
+// existing parser instance.
+//
+// Example:
+//     p.Run("m = [1, 2; 3, 4]")
+//     res, err := p.RunValue("inv(m)") // [-2, 1; 3/2, -1/2]
+func (p *Parser) RunValue(expr string) (Value, error) {
 	tokens, err := Lex(expr)
 
 	if err != nil {
@@ -122,7 +195,22 @@ func Exec(expr string, vars map[string]*big.Rat) (*big.Rat, error) {
 		p.Variables[name] = val
 	}
 
-	return p.parse()
+	res, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return scalar(res)
+}
+
+// scalar converts the result of an expression to a rational number, erroring
+// if it's a matrix
+func scalar(val Value) (*big.Rat, error) {
+	if res, ok := val.(*big.Rat); ok {
+		return res, nil
+	}
+
+	return nil, ErrMatrixResult
 }
 
 // GetVar gets an existing variable.
@@ -140,7 +228,7 @@ func (p Parser) GetVar(index string) (*big.Rat, error) {
 	return nil, fmt.Errorf("Undefined variable ‘%s’", index)
 }
 
-func (p *Parser) parse() (*big.Rat, error) {
+func (p *Parser) parse() (Value, error) {
 	// Initializing current token value
 	p.tok = p.Tokens[0]
 
@@ -148,6 +236,18 @@ func (p *Parser) parse() (*big.Rat, error) {
 		switch {
 		case p.tok.IsLiteral():
 			if p.peek().Is(Lparen) {
+				// Functions taking expressions are evaluated right away, so
+				// their arguments never end up on the stacks
+				if function, ok := funcs[p.tok.Value]; ok && function.exprFn != nil {
+					val, err := p.lazyCall()
+					if err != nil {
+						return nil, err
+					}
+
+					p.operands.Push(val)
+					break
+				}
+
 				// It's a function call, push to operators stack instead
 				p.operators.Push(p.tok)
 
@@ -163,13 +263,22 @@ func (p *Parser) parse() (*big.Rat, error) {
 			p.operands.Push(p.tok)
 		case p.tok.Is(Lparen):
 			p.operators.Push(p.tok)
+		case p.tok.Is(Lbracket):
+			// Matrix literals track their element count like function calls
+			p.operators.Push(p.tok)
+			p.rows.Push([]int(nil))
+			if p.peek().Is(Rbracket) {
+				p.arity.Push(0)
+			} else {
+				p.arity.Push(1)
+			}
 		case p.tok.Is(Comma):
 			for {
 				if p.operators.Empty() {
 					return nil, ErrMisplacedComma
 				}
 
-				if p.operators.Top().(*Token).Is(Lparen) {
+				if top := p.operators.Top().(*Token); top.Is(Lparen) || top.Is(Lbracket) {
 					break
 				}
 
@@ -181,6 +290,27 @@ func (p *Parser) parse() (*big.Rat, error) {
 				p.operands.Push(val)
 			}
 			p.arity.Push(p.arity.Pop().(int) + 1)
+		case p.tok.Is(Semicolon):
+			if err := p.popUntil(Lbracket, ErrMisplacedSemicolon); err != nil {
+				return nil, err
+			}
+
+			// Record the row break at the current element count
+			count := p.arity.Pop().(int)
+			p.rows.Push(append(p.rows.Pop().([]int), count))
+			p.arity.Push(count + 1)
+		case p.tok.Is(Rbracket):
+			if err := p.popUntil(Lbracket, ErrUnmatchedBrackets); err != nil {
+				return nil, err
+			}
+			p.operators.Pop()
+
+			val, err := p.matrixLiteral()
+			if err != nil {
+				return nil, err
+			}
+
+			p.operands.Push(val)
 		case p.tok.IsOperator():
 			if err := p.handleOperator(); err != nil {
 				return nil, err
@@ -195,6 +325,9 @@ func (p *Parser) parse() (*big.Rat, error) {
 				if top.Is(Lparen) {
 					break
 				}
+				if top.Is(Lbracket) {
+					return nil, ErrUnmatchedParentheses
+				}
 
 				val, err := p.evaluate(top)
 				if err != nil {
@@ -213,6 +346,9 @@ func (p *Parser) parse() (*big.Rat, error) {
 		if top.Is(Lparen) {
 			return nil, ErrUnmatchedParentheses
 		}
+		if top.Is(Lbracket) {
+			return nil, ErrUnmatchedBrackets
+		}
 
 		val, err := p.evaluate(top)
 		if err != nil {
@@ -230,7 +366,7 @@ func (p *Parser) parse() (*big.Rat, error) {
 
 	// Single operand left means the expression was evaluated successful
 	if len(p.operands) == 1 {
-		return p.lookup(p.operands[0])
+		return p.lookupValue(p.operands[0])
 	}
 
 	// Leftover token on operand stack indicates invalid syntax
@@ -289,7 +425,7 @@ func (p *Parser) handleOperator() error {
 // evaluate gets called when an operator or function call has to be evaluated
 // for a result. In case of a function, evaluateFunc is called and in case of
 // an operator evaluateOp is called.
-func (p *Parser) evaluate(tok *Token) (*big.Rat, error) {
+func (p *Parser) evaluate(tok *Token) (Value, error) {
 	if tok.IsOperator() {
 		return p.evaluateOp(tok)
 	}
@@ -297,43 +433,200 @@ func (p *Parser) evaluate(tok *Token) (*big.Rat, error) {
 	return p.evaluateFunc(tok)
 }
 
-func (p *Parser) evaluateFunc(tok *Token) (*big.Rat, error) {
+func (p *Parser) evaluateFunc(tok *Token) (Value, error) {
 	var (
 		function function
 		ok       bool
 		i        int
 	)
 
+	arity := p.arity.Pop().(int)
 	if function, ok = funcs[tok.Value]; !ok {
 		return nil, fmt.Errorf("Undefined function ‘%s’", tok)
 	}
 
-	if arity := p.arity.Pop().(int); arity != function.arity {
-		return nil, fmt.Errorf("Invalid argument count for ‘%s’ (expected %d, got %d)", tok, function.arity, arity)
+	if err := p.checkCall(tok, function, arity); err != nil {
+		return nil, err
 	}
 
 	// Start popping off arguments for the function call
-	args := make([]*big.Rat, function.arity)
-	for i = function.arity - 1; i >= 0; i-- {
+	values := make([]Value, arity)
+	for i = arity - 1; i >= 0; i-- {
 		if p.operands.Empty() {
 			return nil, ErrMisplacedComma
 		}
 
-		arg, err := p.lookup(p.operands.Pop())
+		val, err := p.lookupValue(p.operands.Pop())
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = val
+	}
+
+	return p.call(tok, function, values)
+}
+
+// checkCall checks if a function can be called with the given number of
+// arguments
+func (p *Parser) checkCall(tok *Token, function function, arity int) error {
+	if arity < function.arity || arity > function.maxArity {
+		if function.arity == function.maxArity {
+			return fmt.Errorf("Invalid argument count for ‘%s’ (expected %d, got %d)", tok, function.arity, arity)
+		}
+		if function.maxArity == variadic {
+			return fmt.Errorf("Invalid argument count for ‘%s’ (expected at least %d, got %d)", tok, function.arity, arity)
+		}
+		return fmt.Errorf("Invalid argument count for ‘%s’ (expected %d to %d, got %d)", tok, function.arity, function.maxArity, arity)
+	}
+
+	if function.random && p.Deterministic {
+		return fmt.Errorf("‘%s’ is not allowed in deterministic mode", tok)
+	}
+
+	return nil
+}
+
+// call calls a function with evaluated arguments
+func (p *Parser) call(tok *Token, function function, values []Value) (Value, error) {
+	// Functions taking matrices get the values as they are
+	if function.valueFn != nil {
+		res, err := function.valueFn(p, values)
 		if err != nil {
 			return nil, err
 		}
+		return p.round(res)
+	}
+
+	args := make([]*big.Rat, len(values))
+	for i, val := range values {
+		arg, ok := val.(*big.Rat)
+		if !ok {
+			return nil, fmt.Errorf("Expecting numbers for ‘%s’", tok)
+		}
+
+		// Same as with bitwise operators, integer functions only take integers
+		if function.integer && !arg.IsInt() {
+			return nil, fmt.Errorf("Expecting integers for ‘%s’", tok)
+		}
 
 		args[i] = arg
 	}
 
-	return function.fn(args), nil
+	res, err := function.fn(p, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.round(res)
+}
+
+// popUntil evaluates operators until an opening token of type open is at the
+// top of the operator stack. If it isn't found, err is returned.
+func (p *Parser) popUntil(open TokenType, err error) error {
+	for {
+		if p.operators.Empty() {
+			return err
+		}
+
+		top := p.operators.Top().(*Token)
+		if top.Is(open) {
+			return nil
+		}
+		if top.Is(Lparen) || top.Is(Lbracket) {
+			return err
+		}
+
+		val, evalErr := p.evaluate(p.operators.Pop().(*Token))
+		if evalErr != nil {
+			return evalErr
+		}
+
+		p.operands.Push(val)
+	}
+}
+
+// matrixLiteral builds a matrix from the elements of a matrix literal on the
+// operand stack. Elements can be numbers, with rows separated by ;, or row
+// vectors that are stacked as rows, like [[1, 2], [3, 4]].
+func (p *Parser) matrixLiteral() (*Matrix, error) {
+	count := p.arity.Pop().(int)
+	breaks := p.rows.Pop().([]int)
+	if count == 0 {
+		return nil, ErrEmptyMatrix
+	}
+
+	elems := make([]Value, count)
+	for i := count - 1; i >= 0; i-- {
+		if p.operands.Empty() {
+			return nil, ErrMisplacedComma
+		}
+
+		val, err := p.lookupValue(p.operands.Pop())
+		if err != nil {
+			return nil, err
+		}
+		elems[i] = val
+	}
+
+	return buildMatrix(elems, breaks)
+}
+
+// buildMatrix builds a matrix from the elements of a matrix literal, with
+// breaks holding the element counts at which rows end
+func buildMatrix(elems []Value, breaks []int) (*Matrix, error) {
+	count := len(elems)
+	if count == 0 {
+		return nil, ErrEmptyMatrix
+	}
+
+	// Stack row vectors
+	if _, ok := elems[0].(*Matrix); ok && len(breaks) == 0 {
+		var res *Matrix
+		for _, elem := range elems {
+			row, ok := elem.(*Matrix)
+			if !ok || row.Rows != 1 || (res != nil && row.Cols != res.Cols) {
+				return nil, ErrDimMismatch
+			}
+
+			if res == nil {
+				res = &Matrix{Cols: row.Cols}
+			}
+			res.Rows++
+			res.Data = append(res.Data, row.Copy().Data...)
+		}
+
+		return res, nil
+	}
+
+	// Split the numbers into rows
+	breaks = append(breaks, count)
+	cols := breaks[0]
+	res := &Matrix{Rows: len(breaks), Cols: cols, Data: make([]*big.Rat, count)}
+	for i, brk := range breaks {
+		start := 0
+		if i > 0 {
+			start = breaks[i-1]
+		}
+		if brk-start != cols {
+			return nil, ErrDimMismatch
+		}
+	}
+	for i, elem := range elems {
+		x, ok := elem.(*big.Rat)
+		if !ok {
+			return nil, ErrDimMismatch
+		}
+		res.Data[i] = new(big.Rat).Set(x)
+	}
+
+	return res, nil
 }
 
-func (p *Parser) evaluateOp(operator *Token) (*big.Rat, error) {
+func (p *Parser) evaluateOp(operator *Token) (Value, error) {
 	var (
-		result   *big.Rat
-		lhs, rhs *big.Rat
+		result   Value
+		lhs, rhs Value
 		err      error
 		lhsToken interface{}
 	)
@@ -342,7 +635,7 @@ func (p *Parser) evaluateOp(operator *Token) (*big.Rat, error) {
 		return nil, fmt.Errorf("Unexpected ‘%s’", operator)
 	}
 
-	if rhs, err = p.lookup(p.operands.Pop()); err != nil {
+	if rhs, err = p.lookupValue(p.operands.Pop()); err != nil {
 		return nil, err
 	}
 
@@ -358,15 +651,14 @@ func (p *Parser) evaluateOp(operator *Token) (*big.Rat, error) {
 		// Don't lookup the left hand side if = is used so we can do initial
 		// assignment
 		if !operator.Is(Eq) {
-			lhs, err = p.lookup(lhsToken)
+			lhs, err = p.lookupValue(lhsToken)
 			if err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	result, err = executeExpression(operator, lhs, rhs)
-	if err != nil {
+	if result, err = p.applyOperator(operator, lhs, rhs); err != nil {
 		return nil, err
 	}
 
@@ -375,21 +667,89 @@ func (p *Parser) evaluateOp(operator *Token) (*big.Rat, error) {
 		if val, ok := lhsToken.(*Token); !(ok && val.Is(Ident)) {
 			return nil, ErrAssignToLiteral
 		}
-		p.Variables[lhsToken.(*Token).Value] = result
+		p.setVar(lhsToken.(*Token).Value, result)
 	}
 
 	return result, nil
 }
 
+// setVar assigns a value to a variable, either a number or a matrix
+func (p *Parser) setVar(name string, val Value) {
+	if p.Matrices == nil {
+		p.Matrices = make(map[string]*Matrix)
+	}
+
+	switch val := val.(type) {
+	case *big.Rat:
+		delete(p.Matrices, name)
+		p.Variables[name] = val
+	case *Matrix:
+		delete(p.Variables, name)
+		p.Matrices[name] = val
+	}
+}
+
+// GetMatrix gets an existing matrix variable
+func (p Parser) GetMatrix(index string) (*Matrix, error) {
+	if val, ok := p.Matrices[index]; ok {
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("Undefined matrix ‘%s’", index)
+}
+
+// lookupValue looks up a literal like lookup, but also allows matrices
+func (p *Parser) lookupValue(val interface{}) (Value, error) {
+	switch v := val.(type) {
+	case *Matrix:
+		return v, nil
+	case *Token:
+		if m, ok := p.Matrices[v.Value]; ok && v.Is(Ident) {
+			return m, nil
+		}
+	}
+
+	return p.lookup(val)
+}
+
 // Look up a literal. If it's an identifier, check the parser's variables map,
 // otherwise convert the tokenized string to a rational number.
 func (p *Parser) lookup(val interface{}) (*big.Rat, error) {
 	// val can be a token or a rational, if it's a rational it has been already
 	// evaluated and we don't need to do anything
-	if v, ok := val.(*big.Rat); ok {
+	switch v := val.(type) {
+	case *big.Rat:
 		return v, nil
+	case *Matrix:
+		return nil, ErrUnexpectedMatrix
+	}
+
+	tok := val.(*Token)
+	switch tok.Type {
+	case Decimal, Hex, Binary, Octal:
+		res, err := parseNumber(tok)
+		if err != nil {
+			return nil, err
+		}
+		return p.roundRat(res, res, new(big.Float))
+	case Ident:
+		if _, ok := p.Matrices[tok.Value]; ok {
+			return nil, ErrUnexpectedMatrix
+		}
+
+		res, err := p.GetVar(tok.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	default:
+		return nil, fmt.Errorf("Invalid lookup type ‘%s’", tok)
 	}
+}
 
+// parseNumber converts a number literal to a rational number
+func parseNumber(tok *Token) (*big.Rat, error) {
 	var (
 		ok  bool
 		res = new(big.Rat)
@@ -401,7 +761,6 @@ func (p *Parser) lookup(val interface{}) (*big.Rat, error) {
 		Binary: 2,
 	}
 
-	tok := val.(*Token)
 	switch tok.Type {
 	case Decimal:
 		res, ok = res.SetString(tok.Value)
@@ -419,15 +778,6 @@ func (p *Parser) lookup(val interface{}) (*big.Rat, error) {
 		}
 
 		res.SetInt(tmpInt)
-	case Ident:
-		res, err := p.GetVar(tok.Value)
-		if err != nil {
-			return nil, err
-		}
-
-		return res, nil
-	default:
-		return nil, fmt.Errorf("Invalid lookup type ‘%s’", tok)
 	}
 
 	return res, nil
@@ -440,6 +790,7 @@ func (p *Parser) reset() {
 	p.operators = nil
 	p.operands = nil
 	p.arity = nil
+	p.rows = nil
 }
 
 func (p *Parser) peek() *Token {
@@ -112,6 +112,9 @@ func TestEval(t *testing.T) {
 		"true == 1 & false == 0":                        RatTrue,
 		"false":                                         RatFalse,
 		"33**11":                                        big.NewRat(50542106513726817, 1),
+		"2**-2":                                         big.NewRat(1, 4),
+		"(1/3)**3":                                      big.NewRat(1, 27),
+		"(2/3)**-2":                                     big.NewRat(9, 4),
 	}
 
 	for expr, expected := range okExpressions {
@@ -129,6 +132,7 @@ func TestEval(t *testing.T) {
 	badExpressions := []string{
 		"2 / 0", "2 % 0", "+", "2 + 2 +", ")", "(2 + 2 * 8", "@#%@#*%&@#",
 		"a + a", "~~2", "2 == ()", "5 < -", "2 * (9 ** 2))", "5 ~ 3",
+		"0 ** -1", "(-1) ** 0.5",
 	}
 
 	for _, expr := range badExpressions {
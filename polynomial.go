@@ -0,0 +1,845 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"sort"
+)
+
+// maxDegree is the largest degree of polynomials made from expressions
+const maxDegree = 10000
+
+// maxRootCandidates limits the number of candidates tried when searching for
+// rational roots
+const maxRootCandidates = 100000
+
+// ErrZeroPolynomial is returned when asking for the roots of 0
+var ErrZeroPolynomial = errors.New("Every number is a root of the zero polynomial")
+
+// Polynomial is a polynomial in one variable with rational coefficients.
+// Coeffs[i] is the coefficient of x**i, and the last coefficient isn't 0.
+type Polynomial struct {
+	Coeffs []*big.Rat
+}
+
+// NewPolynomial creates a polynomial from its coefficients, starting with the
+// constant term
+//
+// Example:
+//     p := mathcat.NewPolynomial(big.NewRat(-1, 1), big.NewRat(0, 1), big.NewRat(1, 1)) // x**2 - 1
+func NewPolynomial(coeffs ...*big.Rat) *Polynomial {
+	p := &Polynomial{Coeffs: make([]*big.Rat, len(coeffs))}
+	for i, c := range coeffs {
+		p.Coeffs[i] = new(big.Rat).Set(c)
+	}
+
+	return p.trim()
+}
+
+// PolynomialOf expands an expression into a polynomial in variable x. Other
+// variables aren't allowed.
+func PolynomialOf(n Node, x string) (*Polynomial, error) {
+	notPolynomial := fmt.Errorf("Expecting a polynomial in ‘%s’", x)
+
+	p := &Polynomial{}
+	for _, t := range simplifySum(n).expand() {
+		deg := 0
+		switch len(t.factors) {
+		case 0:
+		case 1:
+			f := t.factors[0]
+			ident, ok := f.base.(*IdentNode)
+			if !ok || ident.Name() != x || !f.exp.IsInt() || f.exp.Sign() < 0 || f.exp.Cmp(big.NewRat(maxDegree, 1)) > 0 {
+				return nil, notPolynomial
+			}
+			deg = int(f.exp.Num().Int64())
+		default:
+			return nil, notPolynomial
+		}
+
+		for len(p.Coeffs) <= deg {
+			p.Coeffs = append(p.Coeffs, new(big.Rat))
+		}
+		p.Coeffs[deg].Add(p.Coeffs[deg], t.coef)
+	}
+
+	return p.trim(), nil
+}
+
+// trim removes zero coefficients of the highest powers
+func (p *Polynomial) trim() *Polynomial {
+	n := len(p.Coeffs)
+	for n > 0 && p.Coeffs[n-1].Sign() == 0 {
+		n--
+	}
+	p.Coeffs = p.Coeffs[:n]
+
+	return p
+}
+
+// Degree returns the degree of the polynomial, which is -1 for 0
+func (p *Polynomial) Degree() int {
+	return len(p.Coeffs) - 1
+}
+
+func (p *Polynomial) lead() *big.Rat {
+	return p.Coeffs[len(p.Coeffs)-1]
+}
+
+// coeff returns the coefficient of x**i, also above the degree
+func (p *Polynomial) coeff(i int) *big.Rat {
+	if i < len(p.Coeffs) {
+		return p.Coeffs[i]
+	}
+
+	return new(big.Rat)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Add adds two polynomials
+func (p *Polynomial) Add(q *Polynomial) *Polynomial {
+	res := &Polynomial{Coeffs: make([]*big.Rat, maxInt(len(p.Coeffs), len(q.Coeffs)))}
+	for i := range res.Coeffs {
+		res.Coeffs[i] = new(big.Rat).Add(p.coeff(i), q.coeff(i))
+	}
+
+	return res.trim()
+}
+
+// Sub subtracts q from p
+func (p *Polynomial) Sub(q *Polynomial) *Polynomial {
+	return p.Add(q.Scale(big.NewRat(-1, 1)))
+}
+
+// Scale multiplies all coefficients by x
+func (p *Polynomial) Scale(x *big.Rat) *Polynomial {
+	res := &Polynomial{Coeffs: make([]*big.Rat, len(p.Coeffs))}
+	for i, c := range p.Coeffs {
+		res.Coeffs[i] = new(big.Rat).Mul(c, x)
+	}
+
+	return res.trim()
+}
+
+// Mul multiplies two polynomials
+func (p *Polynomial) Mul(q *Polynomial) *Polynomial {
+	if p.Degree() < 0 || q.Degree() < 0 {
+		return &Polynomial{}
+	}
+
+	res := &Polynomial{Coeffs: make([]*big.Rat, len(p.Coeffs)+len(q.Coeffs)-1)}
+	for i := range res.Coeffs {
+		res.Coeffs[i] = new(big.Rat)
+	}
+	for i, a := range p.Coeffs {
+		for j, b := range q.Coeffs {
+			res.Coeffs[i+j].Add(res.Coeffs[i+j], new(big.Rat).Mul(a, b))
+		}
+	}
+
+	return res.trim()
+}
+
+// DivMod divides p by q with long division, returning the quotient and the
+// remainder, which has a lower degree than q
+func (p *Polynomial) DivMod(q *Polynomial) (quo, rem *Polynomial, err error) {
+	if q.Degree() < 0 {
+		return nil, nil, ErrDivisionByZero
+	}
+
+	rem = NewPolynomial(p.Coeffs...)
+	quo = &Polynomial{Coeffs: make([]*big.Rat, maxInt(p.Degree()-q.Degree()+1, 0))}
+	for i := range quo.Coeffs {
+		quo.Coeffs[i] = new(big.Rat)
+	}
+
+	for rem.Degree() >= q.Degree() {
+		// Cancel the highest power of the remainder
+		shift := rem.Degree() - q.Degree()
+		c := new(big.Rat).Quo(rem.lead(), q.lead())
+		quo.Coeffs[shift] = c
+		for i, qc := range q.Coeffs {
+			rem.Coeffs[i+shift].Sub(rem.Coeffs[i+shift], new(big.Rat).Mul(c, qc))
+		}
+		rem.trim()
+	}
+
+	return quo.trim(), rem, nil
+}
+
+// quo divides polynomials that are known to divide
+func (p *Polynomial) quo(q *Polynomial) *Polynomial {
+	quo, _, _ := p.DivMod(q)
+	return quo
+}
+
+// Monic divides the polynomial by its leading coefficient
+func (p *Polynomial) Monic() *Polynomial {
+	if p.Degree() < 0 {
+		return p
+	}
+
+	return p.Scale(new(big.Rat).Inv(p.lead()))
+}
+
+// GCD returns the monic greatest common divisor of two polynomials
+func (p *Polynomial) GCD(q *Polynomial) *Polynomial {
+	a, b := p, q
+	for b.Degree() >= 0 {
+		_, rem, _ := a.DivMod(b)
+		a, b = b, rem
+	}
+
+	return a.Monic()
+}
+
+// Derivative returns the derivative of the polynomial
+func (p *Polynomial) Derivative() *Polynomial {
+	res := &Polynomial{}
+	for i := 1; i < len(p.Coeffs); i++ {
+		res.Coeffs = append(res.Coeffs, new(big.Rat).Mul(p.Coeffs[i], big.NewRat(int64(i), 1)))
+	}
+
+	return res
+}
+
+// Eval evaluates the polynomial at x
+func (p *Polynomial) Eval(x *big.Rat) *big.Rat {
+	res := new(big.Rat)
+	for i := len(p.Coeffs) - 1; i >= 0; i-- {
+		res.Mul(res, x)
+		res.Add(res, p.Coeffs[i])
+	}
+
+	return res
+}
+
+// Node returns the polynomial as expression in variable x
+func (p *Polynomial) Node(x string) Node {
+	var s termSum
+	for i, c := range p.Coeffs {
+		if c.Sign() == 0 {
+			continue
+		}
+
+		t := &term{coef: c}
+		if i > 0 {
+			t.factors = []*factor{{base: identNode(x, 0), key: x, exp: big.NewRat(int64(i), 1), rank: identRank}}
+		}
+		s = append(s, t)
+	}
+
+	return s.node(0)
+}
+
+// String returns the polynomial as expression in x
+func (p *Polynomial) String() string {
+	return p.Node("x").String()
+}
+
+// primitive returns the polynomial scaled to coprime integer coefficients with
+// a positive leading coefficient
+func (p *Polynomial) primitive() *Polynomial {
+	if p.Degree() < 0 {
+		return p
+	}
+
+	// Scale to integers, then divide by their greatest common divisor
+	den := big.NewInt(1)
+	for _, c := range p.Coeffs {
+		den = Lcm(den, c.Denom())
+	}
+	var num *big.Int
+	for _, c := range p.Coeffs {
+		if c.Sign() == 0 {
+			continue
+		}
+		n := new(big.Int).Mul(c.Num(), new(big.Int).Quo(den, c.Denom()))
+		if num == nil {
+			num = n.Abs(n)
+		} else {
+			num.GCD(nil, nil, num, n.Abs(n))
+		}
+	}
+
+	scale := new(big.Rat).SetFrac(den, num)
+	if p.lead().Sign() < 0 {
+		scale.Neg(scale)
+	}
+
+	return p.Scale(scale)
+}
+
+// squareFree splits the polynomial into square free parts with Yun's
+// algorithm. Part i is raised to the power i + 1 in p.
+func (p *Polynomial) squareFree() []*Polynomial {
+	var parts []*Polynomial
+
+	deriv := p.Derivative()
+	g := p.GCD(deriv)
+	c, d := p.quo(g), deriv.quo(g)
+	for c.Degree() > 0 {
+		d = d.Sub(c.Derivative())
+		a := c.GCD(d)
+		parts = append(parts, a)
+		c, d = c.quo(a), d.quo(a)
+	}
+
+	return parts
+}
+
+// divisors returns the positive divisors of n
+func divisors(n *big.Int) ([]*big.Int, error) {
+	primes, err := Factor(n)
+	if err != nil {
+		return nil, err
+	}
+
+	divs := []*big.Int{big.NewInt(1)}
+	for i := 0; i < len(primes); {
+		// Multiply the divisors so far by every power of the prime
+		j, count := i, len(divs)
+		for pow := big.NewInt(1); j < len(primes) && primes[j].Cmp(primes[i]) == 0; j++ {
+			pow = new(big.Int).Mul(pow, primes[i])
+			for _, d := range divs[:count] {
+				divs = append(divs, new(big.Int).Mul(d, pow))
+			}
+		}
+		i = j
+	}
+
+	return divs, nil
+}
+
+// linearFactors splits off the factors qx - p of rational roots p/q of a
+// primitive polynomial, returning them and the primitive polynomial that's
+// left
+func (p *Polynomial) linearFactors() ([]*Polynomial, *Polynomial, error) {
+	var factors []*Polynomial
+
+	if p.Degree() > 0 && p.Coeffs[0].Sign() == 0 {
+		x := NewPolynomial(new(big.Rat), big.NewRat(1, 1))
+		factors = append(factors, x)
+		p = p.quo(x)
+	}
+	if p.Degree() < 1 {
+		return factors, p, nil
+	}
+
+	nums, err := divisors(p.Coeffs[0].Num())
+	if err != nil {
+		return nil, nil, err
+	}
+	dens, err := divisors(p.lead().Num())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nums)*len(dens) > maxRootCandidates {
+		return nil, nil, errors.New("Too many candidates for rational roots")
+	}
+
+	for _, den := range dens {
+		for _, num := range nums {
+			if p.Degree() < 1 {
+				return factors, p, nil
+			}
+			if new(big.Int).GCD(nil, nil, num, den).Cmp(bigOne) != 0 {
+				continue
+			}
+
+			for _, sign := range []int64{1, -1} {
+				root := new(big.Rat).SetFrac(new(big.Int).Mul(num, big.NewInt(sign)), den)
+				if p.Eval(root).Sign() != 0 {
+					continue
+				}
+
+				f := NewPolynomial(new(big.Rat).SetInt(new(big.Int).Neg(root.Num())), new(big.Rat).SetInt(den))
+				factors = append(factors, f)
+				p = p.quo(f)
+			}
+		}
+	}
+
+	return factors, p, nil
+}
+
+// Factor factors the polynomial over the rationals, splitting off repeated
+// factors and the linear factors of rational roots. It returns a number and
+// primitive integer factors with positive leading coefficients, of which the
+// product is p. Repeated factors are returned once for every time they
+// occur.
+func (p *Polynomial) Factor() (*big.Rat, []*Polynomial, error) {
+	if p.Degree() < 1 {
+		return new(big.Rat).Set(p.coeff(0)), nil, nil
+	}
+
+	prim := p.primitive()
+	content := new(big.Rat).Quo(p.lead(), prim.lead())
+
+	var factors []*Polynomial
+	for i, part := range prim.squareFree() {
+		linear, rest, err := part.primitive().linearFactors()
+		if err != nil {
+			return nil, nil, err
+		}
+		if rest.Degree() > 0 {
+			linear = append(linear, rest.primitive())
+		}
+
+		for _, f := range linear {
+			for j := 0; j <= i; j++ {
+				factors = append(factors, f)
+			}
+		}
+	}
+	sort.Stable(byDegree(factors))
+
+	return content, factors, nil
+}
+
+type byDegree []*Polynomial
+
+func (p byDegree) Len() int      { return len(p) }
+func (p byDegree) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byDegree) Less(i, j int) bool {
+	if p[i].Degree() != p[j].Degree() {
+		return p[i].Degree() < p[j].Degree()
+	}
+
+	return p[i].String() < p[j].String()
+}
+
+// FactoredNode returns the factored polynomial as expression in variable x,
+// like 2*(x - 1)**2*(x**2 + 1)
+func (p *Polynomial) FactoredNode(x string) (Node, error) {
+	content, factors, err := p.Factor()
+	if err != nil {
+		return nil, err
+	}
+
+	// Equal factors are collected into powers
+	t := &term{coef: content}
+	for _, f := range factors {
+		t = t.mul(simplifySum(f.Node(x)).asFactor(ratOne, 0))
+	}
+
+	return t.node(0), nil
+}
+
+// FactorNode factors an expression that is a polynomial in one variable over
+// the rationals, like 2*x**3 - 2*x into 2*x*(x + 1)*(x - 1)
+func FactorNode(n Node) (Node, error) {
+	vars := map[string]bool{}
+	Inspect(n, func(n Node) bool {
+		if ident, ok := n.(*IdentNode); ok {
+			vars[ident.Name()] = true
+		}
+		return true
+	})
+	if len(vars) > 1 {
+		return nil, errors.New("Expecting a polynomial in one variable")
+	}
+
+	x := ""
+	for name := range vars {
+		x = name
+	}
+	p, err := PolynomialOf(n, x)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.FactoredNode(x)
+}
+
+// Roots returns the real roots of the polynomial in ascending order, with
+// multiplicity. Roots of factors up to degree 2 are calculated exactly,
+// irrational ones rounded to prec bits. Others are approximated numerically.
+func (p *Polynomial) Roots(prec uint) ([]*big.Rat, error) {
+	if p.Degree() < 0 {
+		return nil, ErrZeroPolynomial
+	}
+
+	_, factors, err := p.Factor()
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*big.Rat
+	for _, f := range factors {
+		var res []*big.Rat
+		switch f.Degree() {
+		case 1:
+			res = []*big.Rat{new(big.Rat).Quo(new(big.Rat).Neg(f.Coeffs[0]), f.Coeffs[1])}
+		case 2:
+			res, err = f.quadraticRoots(prec)
+		default:
+			res, err = f.realRoots(prec)
+		}
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, res...)
+	}
+	sort.Sort(rats(roots))
+
+	return roots, nil
+}
+
+type rats []*big.Rat
+
+func (r rats) Len() int           { return len(r) }
+func (r rats) Less(i, j int) bool { return r[i].Cmp(r[j]) < 0 }
+func (r rats) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// quadraticRoots returns the real roots of a quadratic polynomial with the
+// quadratic formula
+func (p *Polynomial) quadraticRoots(prec uint) ([]*big.Rat, error) {
+	c, b, a := p.Coeffs[0], p.Coeffs[1], p.Coeffs[2]
+
+	// b**2 - 4ac
+	disc := new(big.Rat).Mul(b, b)
+	disc.Sub(disc, new(big.Rat).Mul(big.NewRat(4, 1), new(big.Rat).Mul(a, c)))
+	if disc.Sign() < 0 {
+		return nil, nil
+	}
+
+	wp := prec + guardBits
+	sqrt := new(big.Float).SetPrec(wp).SetRat(disc)
+	sqrt.Sqrt(sqrt)
+	twoA := new(big.Float).SetPrec(wp).SetRat(new(big.Rat).Mul(a, big.NewRat(2, 1)))
+	negB := new(big.Float).SetPrec(wp).SetRat(new(big.Rat).Neg(b))
+
+	var roots []*big.Rat
+	for _, sign := range []int{-1, 1} {
+		root := new(big.Float).SetPrec(wp).Set(sqrt)
+		if sign < 0 {
+			root.Neg(root)
+		}
+		root.Add(root, negB)
+		root.Quo(root, twoA)
+
+		x, err := floatToRat(root.SetPrec(prec))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, x)
+	}
+
+	return roots, nil
+}
+
+// sturmCount returns the number of distinct real roots of a polynomial by
+// counting the sign changes of its Sturm sequence at -inf and +inf
+func (p *Polynomial) sturmCount() int {
+	seq := []*Polynomial{p, p.Derivative()}
+	for {
+		_, rem, _ := seq[len(seq)-2].DivMod(seq[len(seq)-1])
+		if rem.Degree() < 0 {
+			break
+		}
+		seq = append(seq, rem.Scale(big.NewRat(-1, 1)))
+	}
+
+	changes := func(negInf bool) int {
+		count, last := 0, 0
+		for _, q := range seq {
+			sign := q.lead().Sign()
+			if negInf && q.Degree()%2 == 1 {
+				sign = -sign
+			}
+			if last != 0 && sign != last {
+				count++
+			}
+			last = sign
+		}
+		return count
+	}
+
+	return changes(true) - changes(false)
+}
+
+// realRoots approximates the real roots of a square free polynomial. The
+// Durand-Kerner method finds all complex roots in float64, of which the ones
+// closest to the real axis are refined with Newton's method.
+func (p *Polynomial) realRoots(prec uint) ([]*big.Rat, error) {
+	count := p.sturmCount()
+	if count == 0 {
+		return nil, nil
+	}
+
+	approx, err := p.durandKerner()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byImag(approx))
+
+	var roots []*big.Rat
+	for _, z := range approx[:count] {
+		root, err := p.newton(real(z), prec)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range roots {
+			if r.Cmp(root) == 0 {
+				return nil, ErrNoConvergence
+			}
+		}
+		roots = append(roots, root)
+	}
+
+	return roots, nil
+}
+
+type byImag []complex128
+
+func (z byImag) Len() int           { return len(z) }
+func (z byImag) Less(i, j int) bool { return math.Abs(imag(z[i])) < math.Abs(imag(z[j])) }
+func (z byImag) Swap(i, j int)      { z[i], z[j] = z[j], z[i] }
+
+// durandKerner approximates all complex roots of the polynomial, improving
+// guesses for all of them at once
+func (p *Polynomial) durandKerner() ([]complex128, error) {
+	n := p.Degree()
+	monic := p.Monic()
+	coeffs := make([]complex128, n+1)
+	radius := 1.0
+	for i, c := range monic.Coeffs {
+		f, _ := c.Float64()
+		if math.IsInf(f, 0) {
+			return nil, ErrNoConvergence
+		}
+		coeffs[i] = complex(f, 0)
+		if i < n {
+			radius = math.Max(radius, 1+math.Abs(f))
+		}
+	}
+
+	eval := func(z complex128) complex128 {
+		res := complex(0, 0)
+		for i := n; i >= 0; i-- {
+			res = res*z + coeffs[i]
+		}
+		return res
+	}
+
+	// Start on a circle containing all roots, off the real axis
+	z := make([]complex128, n)
+	for i := range z {
+		z[i] = cmplx.Rect(radius, 2*math.Pi*float64(i)/float64(n)+0.4)
+	}
+
+	for iter := 0; iter < 1000; iter++ {
+		change := 0.0
+		for i := range z {
+			den := complex(1, 0)
+			for j := range z {
+				if i != j {
+					den *= z[i] - z[j]
+				}
+			}
+			step := eval(z[i]) / den
+			z[i] -= step
+			change = math.Max(change, cmplx.Abs(step)/math.Max(1, cmplx.Abs(z[i])))
+		}
+
+		if cmplx.IsNaN(z[0]) {
+			return nil, ErrNoConvergence
+		}
+		if change < 1e-14 {
+			break
+		}
+	}
+
+	return z, nil
+}
+
+// newton refines a simple real root from a float64 approximation to prec bits
+func (p *Polynomial) newton(guess float64, prec uint) (*big.Rat, error) {
+	wp := prec + guardBits
+	coeffs := make([]*big.Float, len(p.Coeffs))
+	for i, c := range p.Coeffs {
+		coeffs[i] = new(big.Float).SetPrec(wp).SetRat(c)
+	}
+	deriv := p.Derivative()
+	dcoeffs := make([]*big.Float, len(deriv.Coeffs))
+	for i, c := range deriv.Coeffs {
+		dcoeffs[i] = new(big.Float).SetPrec(wp).SetRat(c)
+	}
+
+	eval := func(coeffs []*big.Float, x *big.Float) *big.Float {
+		res := new(big.Float).SetPrec(wp)
+		for i := len(coeffs) - 1; i >= 0; i-- {
+			res.Mul(res, x)
+			res.Add(res, coeffs[i])
+		}
+		return res
+	}
+
+	x := new(big.Float).SetPrec(wp).SetFloat64(guess)
+	for iter := 0; iter < 100; iter++ {
+		d := eval(dcoeffs, x)
+		if d.Sign() == 0 {
+			return nil, ErrNoConvergence
+		}
+		step := eval(coeffs, x)
+		step.Quo(step, d)
+		x.Sub(x, step)
+
+		// Stop when the step is below the precision
+		if step.Sign() == 0 || step.MantExp(nil)-x.MantExp(nil) < -int(prec)-8 {
+			return floatToRat(x.SetPrec(prec))
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// polynomialArg gets the polynomial of a call like roots(x**2 - 2, x). Other
+// variables are replaced by their values.
+func polynomialArg(p *Parser, args []*Expr) (*Polynomial, error) {
+	x, err := args[1].Ident()
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := args[0].bindValues(x)
+	if err != nil {
+		return nil, err
+	}
+
+	return PolynomialOf(n, x)
+}
+
+// bindValues replaces the variables in the expression other than x by their
+// values
+func (e *Expr) bindValues(x string) (Node, error) {
+	return replaceIdents(e.node, func(ident *IdentNode) (Node, error) {
+		if ident.Name() == x {
+			return ident, nil
+		}
+
+		val, ok := e.scope[ident.Name()]
+		if !ok {
+			res, err := e.p.lookupValue(ident.Tok)
+			if err != nil {
+				return nil, err
+			}
+			if val, ok = res.(*big.Rat); !ok {
+				return nil, fmt.Errorf("Expecting a number for ‘%s’", ident.Name())
+			}
+		}
+
+		return numNode(val, ident.Pos()), nil
+	})
+}
+
+// replaceIdents returns a copy of a tree with the variables replaced
+func replaceIdents(n Node, fn func(*IdentNode) (Node, error)) (Node, error) {
+	var err error
+	switch n := n.(type) {
+	case *IdentNode:
+		return fn(n)
+	case *UnaryNode:
+		res := &UnaryNode{Op: n.Op}
+		res.X, err = replaceIdents(n.X, fn)
+		return res, err
+	case *BinaryNode:
+		res := &BinaryNode{Op: n.Op}
+		if res.Lhs, err = replaceIdents(n.Lhs, fn); err != nil {
+			return nil, err
+		}
+		res.Rhs, err = replaceIdents(n.Rhs, fn)
+		return res, err
+	case *CallNode:
+		res := &CallNode{Func: n.Func, Args: make([]Node, len(n.Args))}
+		for i, arg := range n.Args {
+			if res.Args[i], err = replaceIdents(arg, fn); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case *MatrixNode:
+		res := &MatrixNode{Lbracket: n.Lbracket, Elems: make([]Node, len(n.Elems)), Breaks: n.Breaks}
+		for i, elem := range n.Elems {
+			if res.Elems[i], err = replaceIdents(elem, fn); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	return n, nil
+}
+
+// expand evaluates the expanded form of an expression, like
+// expand((x + 1)**2), which mc prints instead if x is undefined
+func expand(p *Parser, args []*Expr) (Value, error) {
+	return p.evalNode(ExpandNode(args[0].Node()), args[0].scope)
+}
+
+// pfactor evaluates the factored form of a polynomial in one variable, like
+// pfactor(x**2 - 1), which mc prints instead if x is undefined
+func pfactor(p *Parser, args []*Expr) (Value, error) {
+	n, err := FactorNode(args[0].Node())
+	if err != nil {
+		return nil, err
+	}
+
+	return p.evalNode(n, args[0].scope)
+}
+
+// coeffs returns the coefficients of a polynomial from the highest power
+// down, like coeffs(2*x**2 - 1, x) gives [2, 0, -1]
+func coeffs(p *Parser, args []*Expr) (Value, error) {
+	poly, err := polynomialArg(p, args)
+	if err != nil {
+		return nil, err
+	}
+	if poly.Degree() < 0 {
+		return new(big.Rat), nil
+	}
+
+	res := NewMatrix(1, len(poly.Coeffs))
+	for i, c := range poly.Coeffs {
+		res.Data[len(poly.Coeffs)-1-i] = new(big.Rat).Set(c)
+	}
+
+	return res, nil
+}
+
+// roots returns the real roots of a polynomial as row vector, like
+// roots(x**2 - 2, x)
+func roots(p *Parser, args []*Expr) (Value, error) {
+	poly, err := polynomialArg(p, args)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := poly.Roots(floatPrec(p))
+	if err != nil {
+		return nil, err
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("No real roots")
+	}
+
+	res := NewMatrix(1, len(rs))
+	copy(res.Data, rs)
+
+	return res, nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func mustPolynomial(t *testing.T, expr string) *Polynomial {
+	tree, err := Parse(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := PolynomialOf(tree, "x")
+	if err != nil {
+		t.Fatalf("unexpected error making polynomial of '%s': %s", expr, err)
+	}
+
+	return p
+}
+
+func TestPolynomialOf(t *testing.T) {
+	polys := map[string]string{
+		"(x + 1)**5":     "x**5 + 5*x**4 + 10*x**3 + 10*x**2 + 5*x + 1",
+		"x**2/2 - 0.125": "x**2/2 - 1/8",
+		"(x - 1)*x - x":  "x**2 - 2*x",
+		"3 - 3":          "0",
+	}
+
+	for expr, expected := range polys {
+		if p := mustPolynomial(t, expr); p.String() != expected {
+			t.Errorf("wrong polynomial of '%s' (expected %s, got %s)", expr, expected, p)
+		}
+	}
+
+	for _, expr := range []string{"x*y", "1/x", "sin(x)", "x**0.5", "2**x"} {
+		tree, _ := Parse(expr)
+		if _, err := PolynomialOf(tree, "x"); err == nil {
+			t.Errorf("expected error making polynomial of '%s'", expr)
+		}
+	}
+}
+
+func TestPolynomialDivMod(t *testing.T) {
+	p, q := mustPolynomial(t, "x**3 - 2*x**2 - 4"), mustPolynomial(t, "x - 3")
+	quo, rem, err := p.DivMod(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quo.String() != "x**2 + x + 3" || rem.String() != "5" {
+		t.Errorf("wrong division (got %s and %s)", quo, rem)
+	}
+
+	if _, _, err := p.DivMod(NewPolynomial()); err != ErrDivisionByZero {
+		t.Errorf("expected division by zero error, got %v", err)
+	}
+
+	gcd := mustPolynomial(t, "2*x**3 - 2*x").GCD(mustPolynomial(t, "x**2 + 2*x + 1"))
+	if gcd.String() != "x + 1" {
+		t.Errorf("wrong gcd (expected x + 1, got %s)", gcd)
+	}
+
+	if d := mustPolynomial(t, "x**3 + 2*x").Derivative(); d.String() != "3*x**2 + 2" {
+		t.Errorf("wrong derivative (expected 3*x**2 + 2, got %s)", d)
+	}
+}
+
+func TestPolynomialFactor(t *testing.T) {
+	factored := map[string]string{
+		"2*x**3 - 2*x":               "2*x*(x + 1)*(x - 1)",
+		"x**4 - 2*x**2 + 1":          "(x + 1)**2*(x - 1)**2",
+		"6*x**3 - 11*x**2 + 6*x - 1": "(2*x - 1)*(3*x - 1)*(x - 1)",
+		"x**2/2 - 1/8":               "(2*x + 1)*(2*x - 1)/8",
+		"x**5 + 1":                   "(x + 1)*(x**4 - x**3 + x**2 - x + 1)",
+		"x**2 - 2":                   "x**2 - 2",
+		"(x + 1)**5":                 "(x + 1)**5",
+		"-3":                         "-3",
+	}
+
+	for expr, expected := range factored {
+		res, err := mustPolynomial(t, expr).FactoredNode("x")
+		if err != nil {
+			t.Errorf("unexpected error factoring '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong factorization of '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	tree, err := Parse("y**2 - 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := FactorNode(tree); err != nil || res.String() != "(y + 2)*(y - 2)" {
+		t.Errorf("wrong factorization in y: %v, %v", res, err)
+	}
+	tree, err = Parse("x*y + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FactorNode(tree); err == nil {
+		t.Error("expected error factoring polynomial in two variables")
+	}
+}
+
+func TestPolynomialRoots(t *testing.T) {
+	roots := map[string][]string{
+		"x**3 - x":           {"-1", "0", "1"},
+		"x**2 - 2":           {"-1.4142135624", "1.4142135624"},
+		"(x - 1)**2":         {"1", "1"},
+		"x**3 - 2":           {"1.2599210499"},
+		"x**4 - 10*x**2 + 1": {"-3.1462643699", "-0.3178372452", "0.3178372452", "3.1462643699"},
+		"x**2 + 1":           {},
+	}
+
+	for expr, expected := range roots {
+		res, err := mustPolynomial(t, expr).Roots(64)
+		if err != nil {
+			t.Errorf("unexpected error finding roots of '%s': %s", expr, err)
+			continue
+		}
+
+		if len(res) != len(expected) {
+			t.Errorf("wrong roots of '%s' (expected %v, got %v)", expr, expected, res)
+			continue
+		}
+		for i, r := range res {
+			if r.IsInt() && r.Num().String() != expected[i] || !r.IsInt() && r.FloatString(10) != expected[i] {
+				t.Errorf("wrong root of '%s' (expected %s, got %s)", expr, expected[i], r.FloatString(10))
+			}
+		}
+	}
+
+	if _, err := NewPolynomial(new(big.Rat)).Roots(64); err != ErrZeroPolynomial {
+		t.Errorf("expected zero polynomial error, got %v", err)
+	}
+}
+
+func TestPolynomialFunctions(t *testing.T) {
+	exprs := map[string]string{
+		"coeffs(2*x**2 - 1, x)":       "[2, 0, -1]",
+		"coeffs(5, x)":                "[5]",
+		"roots(x**2 - 3*x + 2, x)":    "[1, 2]",
+		"roots(x**2 - a, x)":          "[-2, 2]",
+		"roots(x**4 - 5*x**2 + 4, x)": "[-2, -1, 1, 2]",
+		"expand((a + 1)**2)":          "25/1",
+		"pfactor(a**2 - 1)":           "15/1",
+		"factor(a)":                   "[2, 2]",
+	}
+
+	for expr, expected := range exprs {
+		p := New()
+		p.Run("a = 4")
+		res, err := p.RunValue(expr)
+		if err != nil {
+			t.Errorf("unexpected error evaluating '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong result of '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	badExprs := []string{
+		"roots(x**2 + 1, x)", "coeffs(x*y, x)", "roots(0*x, x)", "coeffs(x, 2)",
+		"expand((x + 1)**2)", "pfactor(a*x)",
+	}
+
+	for _, expr := range badExprs {
+		if _, err := New().RunValue(expr); err == nil {
+			t.Errorf("expected error evaluating '%s'", expr)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+// atomPrec is the precedence of nodes that never need parentheses
+const atomPrec = 100
+
+// prec returns the precedence of a node when printed
+func prec(n Node) int {
+	switch n := n.(type) {
+	case *NumberNode:
+		switch {
+		case strings.HasPrefix(n.Tok.Value, "-"):
+			return operators[UnaryMin].prec
+		case strings.Contains(n.Tok.Value, "/"):
+			return operators[Div].prec
+		}
+	case *UnaryNode:
+		return operators[n.Op.Type].prec
+	case *BinaryNode:
+		return operators[n.Op.Type].prec
+	}
+
+	return atomPrec
+}
+
+// spaced reports if an operator is printed with spaces around it. Operators
+// binding tighter than addition aren't, like in 2*x**2.
+func spaced(op *Token) bool {
+	return operators[op.Type].prec <= operators[Add].prec || op.Is(Rem)
+}
+
+// writeNode prints a node, with parentheses if it binds looser than needed
+func writeNode(buf *bytes.Buffer, n Node, minPrec int) {
+	parens := prec(n) < minPrec
+	if parens {
+		buf.WriteByte('(')
+	}
+
+	switch n := n.(type) {
+	case *NumberNode:
+		buf.WriteString(n.Tok.Value)
+	case *IdentNode:
+		buf.WriteString(n.Name())
+	case *UnaryNode:
+		// Nested negations are put between parentheses, as -(-x)
+		buf.WriteString(n.Op.Value)
+		writeNode(buf, n.X, prec(n)+1)
+	case *BinaryNode:
+		op := operators[n.Op.Type]
+
+		// Equal precedence needs parentheses on the side the operator doesn't
+		// associate to. Negative bases are always put between parentheses to
+		// make clear they're raised to a power.
+		lhsPrec, rhsPrec := op.prec, op.prec+1
+		if op.assoc == AssocRight {
+			lhsPrec, rhsPrec = op.prec+1, op.prec
+		}
+		if n.Op.Is(Pow) {
+			lhsPrec = operators[UnaryMin].prec + 1
+		}
+
+		writeNode(buf, n.Lhs, lhsPrec)
+		if spaced(n.Op) {
+			buf.WriteString(" " + n.Op.Value + " ")
+		} else {
+			buf.WriteString(n.Op.Value)
+		}
+		writeNode(buf, n.Rhs, rhsPrec)
+	case *CallNode:
+		buf.WriteString(n.Func.Value + "(")
+		for i, arg := range n.Args {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeNode(buf, arg, 0)
+		}
+		buf.WriteByte(')')
+	case *MatrixNode:
+		buf.WriteByte('[')
+		row := 0
+		for i, elem := range n.Elems {
+			switch {
+			case row < len(n.Breaks) && n.Breaks[row] == i:
+				buf.WriteString("; ")
+				row++
+			case i > 0:
+				buf.WriteString(", ")
+			}
+			writeNode(buf, elem, 0)
+		}
+		buf.WriteByte(']')
+	}
+
+	if parens {
+		buf.WriteByte(')')
+	}
+}
+
+func nodeString(n Node) string {
+	var buf bytes.Buffer
+	writeNode(&buf, n, 0)
+	return buf.String()
+}
+
+// String prints the expression with as few parentheses as needed to parse it
+// into the same tree
+func (n *NumberNode) String() string { return nodeString(n) }
+func (n *IdentNode) String() string  { return nodeString(n) }
+func (n *UnaryNode) String() string  { return nodeString(n) }
+func (n *BinaryNode) String() string { return nodeString(n) }
+func (n *CallNode) String() string   { return nodeString(n) }
+func (n *MatrixNode) String() string { return nodeString(n) }
+
+// Format formats an expression in its canonical form, with single spaces
+// around operators binding as loose as addition and no redundant parentheses.
+// A comment is kept, separated by a single space. The formatted expression
+// evaluates to the same value.
+//
+// Example:
+//     res, err := mathcat.Format("((2*x))+ 3 #  area")
+//     fmt.Println(res) // 2*x + 3 #  area
+func Format(expr string) (string, error) {
+	tokens, err := Lex(expr)
+	if err != nil {
+		return "", err
+	}
+
+	// The comment starts where the expression ends
+	var comment string
+	end := tokens[len(tokens)-1].Pos
+	if runes := []rune(expr); end < len(runes) && runes[end] == '#' {
+		comment = strings.TrimRightFunc(string(runes[end:]), unicode.IsSpace)
+	}
+
+	if len(tokens) == 1 {
+		return comment, nil
+	}
+
+	tree, err := Parse(expr)
+	if err != nil {
+		return "", err
+	}
+
+	if comment == "" {
+		return tree.String(), nil
+	}
+	return tree.String() + " " + comment, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+var ErrEmptyRange = errors.New("Empty range")
+
+// Seed seeds the random number generator used by functions like rand, so their
+// results can be reproduced
+func (p *Parser) Seed(seed int64) {
+	p.rng = rand.New(rand.NewSource(seed))
+}
+
+// random returns the parser's random number generator, seeding it with the
+// current time if it hasn't been seeded yet
+func (p *Parser) random() *rand.Rand {
+	if p.rng == nil {
+		p.Seed(time.Now().UnixNano())
+	}
+
+	return p.rng
+}
+
+// seedValue reduces an integer seed to its lower 64 bits
+func seedValue(n *big.Int) int64 {
+	mask := new(big.Int).Lsh(bigOne, 64)
+	seed := new(big.Int).Mod(n, mask)
+	return int64(seed.Uint64())
+}
+
+// randomRange returns a random integer in [start, stop) with the given step
+func randomRange(rng *rand.Rand, start, stop, step *big.Int) (*big.Int, error) {
+	if step.Sign() == 0 {
+		return nil, errors.New("Step must not be zero")
+	}
+
+	// Number of values in the range, rounded up
+	n := new(big.Int).Sub(stop, start)
+	n.Add(n, step)
+	if step.Sign() > 0 {
+		n.Sub(n, bigOne)
+	} else {
+		n.Add(n, bigOne)
+	}
+	n.Quo(n, step)
+	if n.Sign() <= 0 {
+		return nil, ErrEmptyRange
+	}
+
+	res := n.Rand(rng, n)
+	res.Mul(res, step)
+	return res.Add(res, start), nil
+}
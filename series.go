@@ -0,0 +1,243 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// inf is the upper bound of infinite sums and products
+const inf = "inf"
+
+// maxTerms limits the number of terms of finite sums and products
+const maxTerms = 1 << 20
+
+var (
+	addTok = &Token{Type: Add, Value: "+"}
+	mulTok = &Token{Type: Mul, Value: "*"}
+)
+
+// series is a sum or product of an expression over a range of an index
+type series struct {
+	name   string
+	op     *Token
+	term   *Expr
+	index  string
+	lo, hi *big.Int
+}
+
+// seriesArgs gets the index, bounds and term of a call like
+// sum(i, 1, 10, i**2). hi is nil if the upper bound is inf.
+func seriesArgs(name string, op *Token, args []*Expr) (*series, error) {
+	index, err := args[0].Ident()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &series{name: name, op: op, term: args[3], index: index}
+	if s.lo, err = s.bound(args[1]); err != nil {
+		return nil, err
+	}
+	if hi, err := args[2].Ident(); err != nil || hi != inf {
+		if s.hi, err = s.bound(args[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *series) bound(arg *Expr) (*big.Int, error) {
+	val, err := arg.EvalRat(nil)
+	if err != nil {
+		return nil, err
+	}
+	if !val.IsInt() {
+		return nil, fmt.Errorf("Expecting integer bounds for ‘%s’", s.name)
+	}
+
+	return new(big.Int).Set(val.Num()), nil
+}
+
+// eval evaluates the term with the index bound to i
+func (s *series) eval(i *big.Int) (Value, error) {
+	return s.term.Eval(map[string]*big.Rat{s.index: new(big.Rat).SetInt(i)})
+}
+
+// exact calculates a finite sum or product exactly. Terms can be matrices.
+// It fails with more than maxTerms terms.
+func (s *series) exact(empty *big.Rat) (Value, error) {
+	if s.lo.Cmp(s.hi) > 0 {
+		return empty, nil
+	}
+
+	terms := new(big.Int).Sub(s.hi, s.lo)
+	if terms.Cmp(big.NewInt(maxTerms)) >= 0 {
+		return nil, fmt.Errorf("Too many terms in ‘%s’", s.name)
+	}
+
+	res, err := s.eval(s.lo)
+	if err != nil {
+		return nil, err
+	}
+
+	one := big.NewInt(1)
+	for i := new(big.Int).Add(s.lo, one); i.Cmp(s.hi) <= 0; i.Add(i, one) {
+		term, err := s.eval(i)
+		if err != nil {
+			return nil, err
+		}
+		if res, err = applyOperator(s.op, res, term); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// infinite calculates an infinite sum or product numerically. Partial results
+// are taken over a growing even number of terms, and extrapolated to an
+// infinite number of terms by fitting a polynomial in 1/terms through the last
+// few of them. Most convergent series have such an error, even alternating
+// ones as long as the number of terms is even. Others fail after n.maxIter
+// terms.
+func (s *series) infinite(n *numeric, empty *big.Rat) (*big.Rat, error) {
+	// Fit through up to window partial results, and need at least minPoints
+	// of them so that terms that start out as zero aren't mistaken for
+	// convergence
+	const (
+		window    = 12
+		minPoints = 6
+	)
+
+	var (
+		partial  = n.float().SetRat(empty)
+		i        = new(big.Int).Set(s.lo)
+		one      = big.NewInt(1)
+		count    = 0
+		largest  = n.float()
+		last     *big.Float
+		xs, ys   []*big.Float
+		prevEsts []*big.Float
+	)
+	for terms := 4; terms <= n.maxIter; terms += 2 * (terms / 4) {
+		for ; count < terms; count++ {
+			val, err := s.eval(i)
+			if err != nil {
+				return nil, err
+			}
+			term, err := scalar(val)
+			if err != nil {
+				return nil, err
+			}
+
+			t := n.float().SetRat(term)
+			if s.op.Is(Add) {
+				partial.Add(partial, t)
+			} else {
+				partial.Mul(partial, t)
+				t.Sub(t, newFloat(n.prec, 1))
+			}
+			i.Add(i, one)
+
+			// Keep track of how far the terms are from not changing the
+			// result
+			if last = t.Abs(t); last.Cmp(largest) > 0 {
+				largest = last
+			}
+		}
+
+		xs = append(xs, n.float().Quo(newFloat(n.prec, 1), newFloat(n.prec, int64(terms))))
+		ys = append(ys, n.float().Set(partial))
+		if len(xs) > window {
+			xs, ys = xs[1:], ys[1:]
+		}
+
+		// Take the order of extrapolation that changed the least as result.
+		// Quickly converging series like geometric ones are best left alone.
+		ests := n.extrapolate(xs, ys)
+		var res, resErr *big.Float
+		for j := 0; j < len(ests) && j < len(prevEsts); j++ {
+			errEst := n.abs(n.float().Sub(ests[j], prevEsts[j]))
+			if resErr == nil || errEst.Cmp(resErr) < 0 {
+				res, resErr = ests[j], errEst
+			}
+		}
+		prevEsts = ests
+		if len(xs) < minPoints {
+			continue
+		}
+
+		tol := n.float().Mul(n.eps, n.abs(res))
+		if tol.Cmp(n.tol) < 0 {
+			tol.Set(n.tol)
+		}
+
+		if resErr.Cmp(tol) <= 0 {
+			// The terms of a convergent series go to zero, or one for
+			// products, so they can't stay as big as the largest
+			if last.Sign() != 0 && last.Cmp(n.float().Quo(largest, newFloat(n.prec, 2))) >= 0 {
+				return nil, ErrNoConvergence
+			}
+			return floatToRat(res.SetPrec(n.prec - guardBits))
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// extrapolate evaluates the polynomials through the last 1, 2, ... points of
+// (xs, ys) at 0 with Neville's algorithm
+func (n *numeric) extrapolate(xs, ys []*big.Float) []*big.Float {
+	p := make([]*big.Float, len(ys))
+	for i, y := range ys {
+		p[i] = n.float().Set(y)
+	}
+
+	last := len(xs) - 1
+	ests := []*big.Float{p[last]}
+	for j := 1; j < len(xs); j++ {
+		for k := last; k >= j; k-- {
+			// p[k] = (xs[k]*p[k-1] - xs[k-j]*p[k]) / (xs[k] - xs[k-j])
+			a := n.float().Mul(xs[k], p[k-1])
+			a.Sub(a, n.float().Mul(xs[k-j], p[k]))
+			p[k] = a.Quo(a, n.float().Sub(xs[k], xs[k-j]))
+		}
+		ests = append(ests, p[last])
+	}
+
+	return ests
+}
+
+func (s *series) calculate(p *Parser, empty *big.Rat) (Value, error) {
+	if s.hi == nil {
+		return s.infinite(newNumeric(p), empty)
+	}
+
+	return s.exact(empty)
+}
+
+// sum calculates the sum of an expression over a range of an index, like
+// sum(i, 1, 10, i**2)
+func sum(p *Parser, args []*Expr) (Value, error) {
+	s, err := seriesArgs("sum", addTok, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.calculate(p, new(big.Rat))
+}
+
+// prod calculates the product of an expression over a range of an index,
+// like prod(k, 1, 5, k)
+func prod(p *Parser, args []*Expr) (Value, error) {
+	s, err := seriesArgs("prod", mulTok, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.calculate(p, big.NewRat(1, 1))
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSeries(t *testing.T) {
+	calls := map[string]*big.Rat{
+		"sum(i, 1, 100, i**2)":             big.NewRat(338350, 1),
+		"prod(k, 1, 3, (2*k)/(2*k-1))":     big.NewRat(16, 5),
+		"prod(k, 1, 20, k) == fact(20)":    RatTrue,
+		"sum(i, 1, 3, sum(j, 1, i, j))":    big.NewRat(10, 1),
+		"sum(i, 1, 10, 1/(i*(i+1)))":       big.NewRat(10, 11),
+		"sum(i, 5, 1, i)":                  big.NewRat(0, 1),
+		"prod(i, 5, 1, i)":                 big.NewRat(1, 1),
+		"sum(i, -2, 2, i**3)":              big.NewRat(0, 1),
+		"sum(n, 0, inf, 1/2**n)":           big.NewRat(2, 1),
+		"prod(n, 2, inf, 1 - 1/n**2)":      big.NewRat(1, 2),
+		"sum(n, 1, inf, 0)":                big.NewRat(0, 1),
+		"sum(n, 1, inf, (n-1)*(n-2)/2**n)": big.NewRat(2, 1),
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on series '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong series '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	p := New()
+	p.Run("i = 7")
+	if res, err := p.Run("sum(i, 1, i, i)"); err != nil || res.Cmp(big.NewRat(28, 1)) != 0 {
+		t.Errorf("wrong sum with bound equal to index variable: %v, %v", res, err)
+	}
+	if i, _ := p.GetVar("i"); i.Cmp(big.NewRat(7, 1)) != 0 {
+		t.Errorf("index changed variable i to %s", i)
+	}
+
+	res, err := p.RunValue("sum(i, 1, 3, [i, 1])")
+	if m, ok := res.(*Matrix); err != nil || !ok || m.String() != "[6, 3]" {
+		t.Errorf("wrong sum of matrices: %v, %v", res, err)
+	}
+}
+
+func TestInfiniteSeries(t *testing.T) {
+	calls := map[string]string{
+		"sum(n, 1, inf, 1/n**2)":             "1.644934066848226436",
+		"sum(n, 1, inf, 1/n**3)":             "1.202056903159594285",
+		"sum(n, 1, inf, (-1)**(n+1)/n)":      "0.693147180559945309",
+		"sum(n, 0, inf, (-1)**n/(2*n+1))":    "0.785398163397448310",
+		"sum(n, 0, inf, 1/fact(n))":          "2.718281828459045235",
+		"prod(k, 1, inf, 4*k**2/(4*k**2-1))": "1.570796326794896619",
+		"sum(n, 0, inf, 0.9**n)":             "10.000000000000000000",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error on infinite series '%s': %s", expr, err)
+			continue
+		}
+
+		if res.FloatString(18) != expected {
+			t.Errorf("wrong infinite series '%s' (expected %s, got %s)", expr, expected, res.FloatString(18))
+		}
+	}
+
+	// Diverging series, and series converging too slowly
+	badCalls := []string{
+		"sum(n, 1, inf, 1/n)", "sum(n, 1, inf, n)", "sum(n, 1, inf, (-1)**n)",
+		"prod(n, 1, inf, 2)", "prod(n, 1, inf, 1/2)", "sum(n, 1, inf, 1/n**1.5)",
+	}
+
+	for _, expr := range badCalls {
+		if _, err := Eval(expr); err != ErrNoConvergence {
+			t.Errorf("expected ErrNoConvergence on series '%s', got %v", expr, err)
+		}
+	}
+}
+
+func TestBadSeries(t *testing.T) {
+	badCalls := []string{
+		"sum(1, 1, 10, i)", "sum(i, 1.5, 10, i)", "sum(i, 1, 10)", "prod(i, inf, 10, i)",
+		"sum(i, 1, inf, [i, 1])", "sum(i, 1, 10, j)", "sum(i, 1, 2, 1/(i-2))",
+		"sum(i, 1, 10**9, i)", "prod(k, -(10**20), 10**20, k)",
+	}
+
+	for _, expr := range badCalls {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("expected error on bad series '%s'", expr)
+		}
+	}
+}
+
+func TestSeriesTermLimit(t *testing.T) {
+	p := New()
+	p.MaxIterations = 5
+	if res, err := p.Run("sum(i, 1, 2000, 1)"); err != nil || res.Cmp(big.NewRat(2000, 1)) != 0 {
+		t.Errorf("wrong sum with more terms than MaxIterations: %v, %v", res, err)
+	}
+	if _, err := p.Run("sum(i, 1, 2**20 + 1, 1)"); err == nil {
+		t.Error("expected error on sum over term limit")
+	}
+}
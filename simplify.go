@@ -0,0 +1,997 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// The functions below build nodes for new expressions like derivatives. They
+// simplify as they go, by folding numbers and leaving out terms that don't
+// change the result, like 0 + x and 1*x. New nodes get the position of the
+// node they're derived from.
+
+// maxFoldBits limits the size of powers of numbers that are folded, as the
+// bits of the number times the exponent
+const maxFoldBits = 8192
+
+var ratOne = big.NewRat(1, 1)
+
+func newOp(typ TokenType, pos int) *Token {
+	return &Token{Type: typ, Value: typ.String(), Pos: pos}
+}
+
+// numNode creates a number. Numbers with a finite decimal expansion are
+// written as decimal, others as fraction.
+func numNode(x *big.Rat, pos int) *NumberNode {
+	text := x.RatString()
+	if !x.IsInt() {
+		// The number of decimals is the larger power of 2 or 5 in the
+		// denominator, if those are the only factors
+		denom := new(big.Int).Set(x.Denom())
+		decimals := 0
+		for _, f := range []int64{2, 5} {
+			factor, count := big.NewInt(f), 0
+			for new(big.Int).Rem(denom, factor).Sign() == 0 {
+				denom.Quo(denom, factor)
+				count++
+			}
+			if count > decimals {
+				decimals = count
+			}
+		}
+		if denom.Cmp(bigOne) == 0 {
+			text = x.FloatString(decimals)
+		}
+	}
+
+	return &NumberNode{Tok: &Token{Type: Decimal, Value: text, Pos: pos}, Value: new(big.Rat).Set(x)}
+}
+
+func intNode(x int64, pos int) *NumberNode {
+	return numNode(big.NewRat(x, 1), pos)
+}
+
+func identNode(name string, pos int) *IdentNode {
+	return &IdentNode{Tok: &Token{Type: Ident, Value: name, Pos: pos}}
+}
+
+func callNode(name string, pos int, args ...Node) *CallNode {
+	return &CallNode{Func: &Token{Type: Ident, Value: name, Pos: pos}, Args: args}
+}
+
+// numValue returns the value of n if it's a number
+func numValue(n Node) (*big.Rat, bool) {
+	if num, ok := n.(*NumberNode); ok {
+		return num.Value, true
+	}
+
+	return nil, false
+}
+
+// isNum checks if n is the number x
+func isNum(n Node, x int64) bool {
+	val, ok := numValue(n)
+	return ok && val.Cmp(big.NewRat(x, 1)) == 0
+}
+
+// isNeg checks if n is a negated expression or a negative number
+func isNeg(n Node) bool {
+	if val, ok := numValue(n); ok {
+		return val.Sign() < 0
+	}
+	unary, ok := n.(*UnaryNode)
+	return ok && unary.Op.Is(UnaryMin)
+}
+
+// isOp checks if n is a binary operation of the given type
+func isOp(n Node, typ TokenType) (*BinaryNode, bool) {
+	bin, ok := n.(*BinaryNode)
+	if !ok || !bin.Op.Is(typ) {
+		return nil, false
+	}
+
+	return bin, true
+}
+
+// equalNodes checks if two trees are the same expression
+func equalNodes(a, b Node) bool {
+	return a.String() == b.String()
+}
+
+func binary(typ TokenType, lhs, rhs Node) *BinaryNode {
+	return &BinaryNode{Op: newOp(typ, lhs.Pos()), Lhs: lhs, Rhs: rhs}
+}
+
+// neg builds -x
+func neg(x Node) Node {
+	if val, ok := numValue(x); ok {
+		return numNode(new(big.Rat).Neg(val), x.Pos())
+	}
+
+	switch x := x.(type) {
+	case *UnaryNode:
+		if x.Op.Is(UnaryMin) {
+			return x.X
+		}
+	case *BinaryNode:
+		switch x.Op.Type {
+		case Sub:
+			return sub(x.Rhs, x.Lhs)
+		case Mul, Div:
+			// Negate the left hand side, as in -2*x and -sin(x)*y
+			return binary(x.Op.Type, neg(x.Lhs), x.Rhs)
+		}
+	}
+
+	return &UnaryNode{Op: newOp(UnaryMin, x.Pos()), X: x}
+}
+
+// add builds lhs + rhs
+func add(lhs, rhs Node) Node {
+	a, aNum := numValue(lhs)
+	b, bNum := numValue(rhs)
+	switch {
+	case aNum && bNum:
+		return numNode(new(big.Rat).Add(a, b), lhs.Pos())
+	case isNum(lhs, 0):
+		return rhs
+	case isNum(rhs, 0):
+		return lhs
+	case isNeg(rhs) || isNegProduct(rhs):
+		return sub(lhs, neg(rhs))
+	case isNeg(lhs):
+		return sub(rhs, neg(lhs))
+	}
+
+	return binary(Add, lhs, rhs)
+}
+
+// isNegProduct checks if n is a product or quotient with a negative left hand
+// side, like -2*x
+func isNegProduct(n Node) bool {
+	bin, ok := n.(*BinaryNode)
+	return ok && (bin.Op.Is(Mul) || bin.Op.Is(Div)) && isNeg(bin.Lhs)
+}
+
+// sub builds lhs - rhs
+func sub(lhs, rhs Node) Node {
+	a, aNum := numValue(lhs)
+	b, bNum := numValue(rhs)
+	switch {
+	case aNum && bNum:
+		return numNode(new(big.Rat).Sub(a, b), lhs.Pos())
+	case isNum(rhs, 0):
+		return lhs
+	case isNum(lhs, 0):
+		return neg(rhs)
+	case isNeg(rhs) || isNegProduct(rhs):
+		return add(lhs, neg(rhs))
+	case equalNodes(lhs, rhs):
+		return intNode(0, lhs.Pos())
+	}
+
+	return binary(Sub, lhs, rhs)
+}
+
+// mul builds lhs*rhs, with numbers moved to the left
+func mul(lhs, rhs Node) Node {
+	a, aNum := numValue(lhs)
+	b, bNum := numValue(rhs)
+	switch {
+	case aNum && bNum:
+		return numNode(new(big.Rat).Mul(a, b), lhs.Pos())
+	case isNum(lhs, 0) || isNum(rhs, 0):
+		return intNode(0, lhs.Pos())
+	case isNum(lhs, 1):
+		return rhs
+	case isNum(rhs, 1):
+		return lhs
+	case isNum(lhs, -1):
+		return neg(rhs)
+	case isNum(rhs, -1):
+		return neg(lhs)
+	case bNum:
+		return mul(rhs, lhs)
+	case isNeg(lhs) && !aNum:
+		return neg(mul(neg(lhs), rhs))
+	case isNeg(rhs):
+		return neg(mul(lhs, neg(rhs)))
+	}
+
+	// Keep products left associative, so numbers end up in front as in 2*x*y
+	if bin, ok := isOp(rhs, Mul); ok {
+		return mul(mul(lhs, bin.Lhs), bin.Rhs)
+	}
+
+	return binary(Mul, lhs, rhs)
+}
+
+// div builds lhs/rhs
+func div(lhs, rhs Node) Node {
+	a, aNum := numValue(lhs)
+	b, bNum := numValue(rhs)
+	switch {
+	case bNum && b.Sign() == 0:
+		// Leave division by zero for evaluation to report
+	case aNum && bNum:
+		return numNode(new(big.Rat).Quo(a, b), lhs.Pos())
+	case isNum(lhs, 0):
+		return lhs
+	case isNum(rhs, 1):
+		return lhs
+	case bNum:
+		return mul(numNode(new(big.Rat).Inv(b), rhs.Pos()), lhs)
+	case isNeg(lhs) && !aNum:
+		return neg(div(neg(lhs), rhs))
+	case isNeg(rhs):
+		return neg(div(lhs, neg(rhs)))
+	case equalNodes(lhs, rhs):
+		return intNode(1, lhs.Pos())
+	}
+
+	return binary(Div, lhs, rhs)
+}
+
+// pow builds lhs**rhs
+func pow(lhs, rhs Node) Node {
+	a, aNum := numValue(lhs)
+	b, bNum := numValue(rhs)
+	switch {
+	case isNum(rhs, 0):
+		return intNode(1, lhs.Pos())
+	case isNum(rhs, 1), isNum(lhs, 1):
+		return lhs
+	case aNum && bNum:
+		if res, ok := foldPow(a, b); ok {
+			return numNode(res, lhs.Pos())
+		}
+	}
+
+	// (x**a)**b = x**(a*b) for integers
+	if bin, ok := isOp(lhs, Pow); ok && bNum && b.IsInt() {
+		if c, ok := numValue(bin.Rhs); ok && c.IsInt() {
+			return pow(bin.Lhs, numNode(new(big.Rat).Mul(b, c), rhs.Pos()))
+		}
+	}
+
+	return binary(Pow, lhs, rhs)
+}
+
+// Simplify simplifies an expression into a canonical form. Numbers are folded
+// exactly, like terms and factors are collected and the identities of
+// powers applied. Calls to functions with side effects like rand are left
+// as they are. Variables are assumed to be numbers, so products of them can
+// be reordered.
+//
+// Example:
+//     tree, err := mathcat.Simplify("x*1 + 0 + 2*3*y")
+//     fmt.Println(tree) // x + 6*y
+func Simplify(expr string) (Node, error) {
+	tree, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return SimplifyNode(tree), nil
+}
+
+// SimplifyNode simplifies a parsed expression like Simplify
+func SimplifyNode(n Node) Node {
+	return simplifySum(n).node(n.Pos())
+}
+
+// exactFuncs are the functions of which calls with numbers are folded, as
+// their results are exact
+var exactFuncs = map[string]bool{
+	"abs": true, "ceil": true, "floor": true, "max": true, "min": true,
+	"gcd": true, "lcm": true,
+}
+
+// totalFuncs are the functions that can't fail on numbers
+var totalFuncs = map[string]bool{
+	"abs": true, "ceil": true, "floor": true, "max": true, "min": true,
+	"sin": true, "cos": true, "atan": true, "tanh": true, "erf": true,
+	"erfc": true,
+}
+
+// cantFail checks if evaluating n can't raise an error or have side effects,
+// assuming variables are numbers
+func cantFail(n Node) bool {
+	switch n := n.(type) {
+	case *NumberNode, *IdentNode:
+		return true
+	case *UnaryNode:
+		return n.Op.Is(UnaryMin) && cantFail(n.X)
+	case *BinaryNode:
+		switch n.Op.Type {
+		case Add, Sub, Mul, Lt, LtEq, Gt, GtEq, EqEq, NotEq:
+			return cantFail(n.Lhs) && cantFail(n.Rhs)
+		case Div:
+			b, ok := numValue(n.Rhs)
+			return ok && b.Sign() != 0 && cantFail(n.Lhs)
+		case Pow:
+			b, ok := numValue(n.Rhs)
+			return ok && b.IsInt() && b.Sign() > 0 && cantFail(n.Lhs)
+		}
+	case *CallNode:
+		function, ok := funcs[n.Func.Value]
+		if !ok || !totalFuncs[n.Func.Value] || len(n.Args) < function.arity || len(n.Args) > function.maxArity {
+			return false
+		}
+		for _, arg := range n.Args {
+			if !cantFail(arg) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Ranks of factors, which are ordered by rank and then by text. Unique
+// factors keep their order.
+const (
+	identRank = iota
+	callRank
+	otherRank
+	uniqueRank
+)
+
+// factor is a base raised to a rational power. Bases with the same key are the
+// same, except for unique ones like calls to rand.
+type factor struct {
+	base Node
+	key  string
+	exp  *big.Rat
+	rank int
+	// sum is set if the base is a sum of terms
+	sum termSum
+}
+
+func (f *factor) withExp(exp *big.Rat) *factor {
+	res := *f
+	res.exp = exp
+	return &res
+}
+
+// node returns the factor as power
+func (f *factor) node(pos int) Node {
+	if f.exp.Cmp(ratOne) == 0 {
+		return f.base
+	}
+
+	return binary(Pow, f.base, numNode(f.exp, pos))
+}
+
+// term is a coefficient times a product of factors, ordered by rank and key
+type term struct {
+	coef    *big.Rat
+	factors []*factor
+}
+
+func (t *term) unique() bool {
+	for _, f := range t.factors {
+		if f.rank == uniqueRank {
+			return true
+		}
+	}
+
+	return false
+}
+
+// key identifies terms with the same factors
+func (t *term) key() string {
+	keys := make([]string, len(t.factors))
+	for i, f := range t.factors {
+		keys[i] = f.key + "**" + f.exp.RatString()
+	}
+
+	return strings.Join(keys, "*")
+}
+
+// degree is the sum of the exponents of the factors
+func (t *term) degree() *big.Rat {
+	deg := new(big.Rat)
+	for _, f := range t.factors {
+		deg.Add(deg, f.exp)
+	}
+
+	return deg
+}
+
+// mul multiplies two terms, adding the exponents of the same factors
+func (t *term) mul(u *term) *term {
+	res := &term{coef: new(big.Rat).Mul(t.coef, u.coef)}
+	res.factors = append(res.factors, t.factors...)
+
+	for _, g := range u.factors {
+		merged := false
+		for i, f := range res.factors {
+			if f.rank != uniqueRank && f.key == g.key {
+				res.factors[i] = f.withExp(new(big.Rat).Add(f.exp, g.exp))
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			res.factors = append(res.factors, g)
+		}
+	}
+
+	// Drop factors to the power 0
+	factors := res.factors[:0]
+	for _, f := range res.factors {
+		if f.exp.Sign() != 0 {
+			factors = append(factors, f)
+		}
+	}
+	res.factors = factors
+	sort.Stable(byRank(res.factors))
+
+	return res
+}
+
+// node returns the term as expression, with negative powers as divisor
+func (t *term) node(pos int) Node {
+	coef := new(big.Rat).Abs(t.coef)
+
+	var num, den []Node
+	if !coef.Num().IsInt64() || coef.Num().Int64() != 1 {
+		num = append(num, numNode(new(big.Rat).SetInt(coef.Num()), pos))
+	}
+	if !coef.IsInt() {
+		den = append(den, numNode(new(big.Rat).SetInt(coef.Denom()), pos))
+	}
+	for _, f := range t.factors {
+		if f.exp.Sign() > 0 {
+			num = append(num, f.node(pos))
+		} else {
+			den = append(den, f.withExp(new(big.Rat).Neg(f.exp)).node(pos))
+		}
+	}
+	if len(num) == 0 {
+		num = append(num, intNode(1, pos))
+	}
+
+	// Negate the first factor of the numerator, unless it's a power, which
+	// would be raised to the power negated
+	negTerm := false
+	if t.coef.Sign() < 0 {
+		if _, ok := isOp(num[0], Pow); ok {
+			negTerm = true
+		} else {
+			num[0] = neg(num[0])
+		}
+	}
+
+	res := product(num)
+	if len(den) > 0 {
+		res = binary(Div, res, product(den))
+	}
+	if negTerm {
+		res = &UnaryNode{Op: newOp(UnaryMin, pos), X: res}
+	}
+
+	return res
+}
+
+// product multiplies nodes from left to right
+func product(nodes []Node) Node {
+	res := nodes[0]
+	for _, n := range nodes[1:] {
+		res = binary(Mul, res, n)
+	}
+
+	return res
+}
+
+type byRank []*factor
+
+func (f byRank) Len() int      { return len(f) }
+func (f byRank) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+func (f byRank) Less(i, j int) bool {
+	if f[i].rank != f[j].rank || f[i].rank == uniqueRank {
+		return f[i].rank < f[j].rank
+	}
+	if f[i].key != f[j].key {
+		return f[i].key < f[j].key
+	}
+
+	return f[i].exp.Cmp(f[j].exp) < 0
+}
+
+// termSum is a sum of terms, without like terms and terms with coefficient 0.
+// The number term has no factors.
+type termSum []*term
+
+func constSum(x *big.Rat) termSum {
+	if x.Sign() == 0 {
+		return nil
+	}
+
+	return termSum{{coef: new(big.Rat).Set(x)}}
+}
+
+// atom returns n as a sum of a single factor
+func atom(n Node, unique bool) termSum {
+	f := &factor{base: n, key: n.String(), exp: big.NewRat(1, 1), rank: otherRank}
+	switch n.(type) {
+	case *IdentNode:
+		f.rank = identRank
+	case *CallNode:
+		f.rank = callRank
+	}
+	if unique {
+		f.rank = uniqueRank
+	}
+
+	return termSum{{coef: big.NewRat(1, 1), factors: []*factor{f}}}
+}
+
+// constant returns the value of the sum if it has no factors
+func (s termSum) constant() (*big.Rat, bool) {
+	switch {
+	case len(s) == 0:
+		return new(big.Rat), true
+	case len(s) == 1 && len(s[0].factors) == 0:
+		return s[0].coef, true
+	}
+
+	return nil, false
+}
+
+// monomial returns the single term of the sum
+func (s termSum) monomial() (*term, bool) {
+	if len(s) != 1 {
+		return nil, false
+	}
+
+	return s[0], true
+}
+
+// asFactor returns the sum as a factor to the power exp
+func (s termSum) asFactor(exp *big.Rat, pos int) *term {
+	if t, ok := s.monomial(); ok && exp.Cmp(ratOne) == 0 {
+		return t
+	}
+
+	f := &factor{base: s.node(pos), exp: exp, rank: otherRank, sum: s}
+	f.key = f.base.String()
+	for _, t := range s {
+		if t.unique() {
+			f.rank = uniqueRank
+		}
+	}
+
+	return &term{coef: big.NewRat(1, 1), factors: []*factor{f}}
+}
+
+func (s termSum) add(u termSum) termSum {
+	res := append(termSum{}, s...)
+	for _, t := range u {
+		merged := false
+		if !t.unique() {
+			key := t.key()
+			for i, r := range res {
+				if !r.unique() && r.key() == key {
+					res[i] = &term{coef: new(big.Rat).Add(r.coef, t.coef), factors: r.factors}
+					merged = true
+					break
+				}
+			}
+		}
+		if !merged {
+			res = append(res, t)
+		}
+	}
+
+	// Drop cancelled terms
+	terms := res[:0]
+	for _, t := range res {
+		if t.coef.Sign() != 0 {
+			terms = append(terms, t)
+		}
+	}
+
+	return terms
+}
+
+func (s termSum) scale(x *big.Rat) termSum {
+	if x.Sign() == 0 {
+		return nil
+	}
+
+	res := make(termSum, len(s))
+	for i, t := range s {
+		res[i] = &term{coef: new(big.Rat).Mul(t.coef, x), factors: t.factors}
+	}
+
+	return res
+}
+
+// mul multiplies two sums. Sums of more than one term are kept as factors
+// instead of being expanded.
+func (s termSum) mul(u termSum, pos int) termSum {
+	if x, ok := s.constant(); ok {
+		if x.Sign() == 0 {
+			return s.mulZero(u, pos)
+		}
+		return u.scale(x)
+	}
+	if x, ok := u.constant(); ok {
+		if x.Sign() == 0 {
+			return s.mulZero(u, pos)
+		}
+		return s.scale(x)
+	}
+
+	t := s.asFactor(ratOne, pos).mul(u.asFactor(ratOne, pos))
+
+	// A number times a sum is distributed, as in 2*(x + 1) = 2*x + 2
+	if len(t.factors) == 1 && t.factors[0].sum != nil && t.factors[0].exp.Cmp(ratOne) == 0 {
+		return t.factors[0].sum.scale(t.coef)
+	}
+
+	return termSum{t}
+}
+
+// mulZero multiplies two sums of which one is 0. The product is only folded
+// to 0 if evaluating it can't fail, so 0*(1/0) still reports division by
+// zero and rand()*0 still calls rand.
+func (s termSum) mulZero(u termSum, pos int) termSum {
+	lhs, rhs := s.node(pos), u.node(pos)
+	if cantFail(lhs) && cantFail(rhs) {
+		return nil
+	}
+
+	return atom(binary(Mul, lhs, rhs), s.unique() || u.unique())
+}
+
+// unique checks if the sum has a unique term, like a call to rand
+func (s termSum) unique() bool {
+	for _, t := range s {
+		if t.unique() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pow raises a sum to a rational power
+func (s termSum) pow(exp *big.Rat, pos int) (termSum, bool) {
+	if exp.Sign() == 0 {
+		return constSum(ratOne), true
+	}
+	if exp.Cmp(ratOne) == 0 {
+		return s, true
+	}
+
+	t, mono := s.monomial()
+	if x, ok := s.constant(); ok {
+		switch {
+		case x.Cmp(ratOne) == 0:
+			return s, true
+		case x.Sign() == 0 && exp.Sign() > 0:
+			return nil, true
+		}
+		res, ok := foldPow(x, exp)
+		if !ok {
+			return nil, false
+		}
+		return constSum(res), true
+	}
+
+	if !exp.IsInt() {
+		// Only a single factor to the power 1 is raised, as (x**2)**(1/2)
+		// isn't x for negative x
+		if !mono || t.coef.Cmp(ratOne) != 0 || len(t.factors) != 1 || t.factors[0].exp.Cmp(ratOne) != 0 {
+			return nil, false
+		}
+		return termSum{{coef: t.coef, factors: []*factor{t.factors[0].withExp(exp)}}}, true
+	}
+
+	if !mono {
+		return termSum{s.asFactor(exp, pos)}, true
+	}
+
+	// The coefficient of a monomial is raised if the power is small enough
+	res := &term{coef: big.NewRat(1, 1)}
+	if t.coef.Cmp(ratOne) != 0 {
+		coef, ok := foldPow(t.coef, exp)
+		if !ok {
+			return nil, false
+		}
+		res.coef = coef
+	}
+	for _, f := range t.factors {
+		res.factors = append(res.factors, f.withExp(new(big.Rat).Mul(f.exp, exp)))
+	}
+
+	return termSum{res}, true
+}
+
+// foldPow raises x to an integer power, if the result isn't too large
+func foldPow(x, exp *big.Rat) (*big.Rat, bool) {
+	if !exp.IsInt() || (x.Sign() == 0 && exp.Sign() < 0) {
+		return nil, false
+	}
+
+	k := new(big.Int).Abs(exp.Num())
+	size := int64(x.Num().BitLen() + x.Denom().BitLen())
+	if !k.IsInt64() || k.Int64() > maxFoldBits || size*k.Int64() > maxFoldBits {
+		return nil, false
+	}
+
+	num := new(big.Int).Exp(x.Num(), k, nil)
+	den := new(big.Int).Exp(x.Denom(), k, nil)
+	if exp.Sign() < 0 {
+		num, den = den, num
+	}
+
+	return new(big.Rat).SetFrac(num, den), true
+}
+
+// byOrder orders the terms of a sum by descending degree and then by key,
+// with the number last
+type byOrder termSum
+
+func (s byOrder) Len() int      { return len(s) }
+func (s byOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byOrder) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	if (len(a.factors) == 0) != (len(b.factors) == 0) {
+		return len(b.factors) == 0
+	}
+	if a.unique() || b.unique() {
+		return false
+	}
+	if cmp := a.degree().Cmp(b.degree()); cmp != 0 {
+		return cmp > 0
+	}
+
+	return a.key() < b.key()
+}
+
+// node returns the sum as expression. It starts with the first term that
+// isn't negative, so it reads like x - 1 instead of -1 + x.
+func (s termSum) node(pos int) Node {
+	if len(s) == 0 {
+		return intNode(0, pos)
+	}
+
+	terms := append(termSum{}, s...)
+	sort.Stable(byOrder(terms))
+	for i, t := range terms {
+		if t.coef.Sign() > 0 {
+			terms = append(termSum{t}, append(terms[:i:i], terms[i+1:]...)...)
+			break
+		}
+	}
+
+	res := terms[0].node(pos)
+	for _, t := range terms[1:] {
+		if t.coef.Sign() < 0 {
+			abs := &term{coef: new(big.Rat).Neg(t.coef), factors: t.factors}
+			res = binary(Sub, res, abs.node(pos))
+		} else {
+			res = binary(Add, res, t.node(pos))
+		}
+	}
+
+	return res
+}
+
+// simplifySum brings an expression in the form of a sum of terms
+func simplifySum(n Node) termSum {
+	switch n := n.(type) {
+	case *NumberNode:
+		return constSum(n.Value)
+	case *IdentNode:
+		return atom(n, false)
+	case *UnaryNode:
+		if n.Op.Is(UnaryMin) {
+			return simplifySum(n.X).scale(big.NewRat(-1, 1))
+		}
+		x := SimplifyNode(n.X)
+		if val, ok := numValue(x); ok {
+			if res, err := executeExpression(n.Op, nil, val); err == nil {
+				return constSum(res)
+			}
+		}
+		return atom(&UnaryNode{Op: n.Op, X: x}, false)
+	case *BinaryNode:
+		return simplifyBinary(n)
+	case *CallNode:
+		return simplifyCall(n)
+	case *MatrixNode:
+		m := &MatrixNode{Lbracket: n.Lbracket, Elems: make([]Node, len(n.Elems)), Breaks: n.Breaks}
+		for i, elem := range n.Elems {
+			m.Elems[i] = SimplifyNode(elem)
+		}
+		// Matrix products don't commute
+		return atom(m, true)
+	}
+
+	return atom(n, true)
+}
+
+func simplifyBinary(n *BinaryNode) termSum {
+	pos := n.Pos()
+
+	switch n.Op.Type {
+	case Add:
+		return simplifySum(n.Lhs).add(simplifySum(n.Rhs))
+	case Sub:
+		return simplifySum(n.Lhs).add(simplifySum(n.Rhs).scale(big.NewRat(-1, 1)))
+	case Mul:
+		return simplifySum(n.Lhs).mul(simplifySum(n.Rhs), pos)
+	case Div:
+		lhs, rhs := simplifySum(n.Lhs), simplifySum(n.Rhs)
+		if x, ok := lhs.constant(); ok && x.Sign() == 0 {
+			// Like in mulZero, 0/x is only folded if it can't fail
+			if quo := binary(Div, lhs.node(pos), rhs.node(pos)); !cantFail(quo) {
+				return atom(quo, rhs.unique())
+			}
+		}
+		if x, ok := rhs.constant(); ok {
+			if x.Sign() == 0 {
+				// Leave division by zero for evaluation to report
+				return atom(binary(Div, lhs.node(pos), rhs.node(pos)), false)
+			}
+			return lhs.scale(new(big.Rat).Inv(x))
+		}
+		inv, _ := rhs.pow(big.NewRat(-1, 1), pos)
+		return lhs.mul(inv, pos)
+	case Pow:
+		lhs, rhs := simplifySum(n.Lhs), simplifySum(n.Rhs)
+		if x, ok := rhs.constant(); ok {
+			if res, ok := lhs.pow(x, pos); ok {
+				return res
+			}
+		}
+		if x, ok := lhs.constant(); ok && x.Cmp(ratOne) == 0 {
+			return lhs
+		}
+		return atom(binary(Pow, lhs.node(pos), rhs.node(pos)), false)
+	}
+
+	// Assignments only have their value simplified
+	if n.Op.IsAssignment() {
+		return atom(&BinaryNode{Op: n.Op, Lhs: n.Lhs, Rhs: SimplifyNode(n.Rhs)}, true)
+	}
+
+	lhs, rhs := SimplifyNode(n.Lhs), SimplifyNode(n.Rhs)
+	a, aNum := numValue(lhs)
+	b, bNum := numValue(rhs)
+	if aNum && bNum {
+		if res, err := executeExpression(n.Op, a, b); err == nil {
+			return constSum(res)
+		}
+	}
+
+	return atom(&BinaryNode{Op: n.Op, Lhs: lhs, Rhs: rhs}, false)
+}
+
+func simplifyCall(n *CallNode) termSum {
+	// Functions with side effects or expression arguments are left alone
+	function, ok := funcs[n.Func.Value]
+	if !ok || function.random || function.exprFn != nil || n.Func.Value == "seed" || n.Func.Value == "list" {
+		return atom(n, true)
+	}
+
+	call := &CallNode{Func: n.Func, Args: make([]Node, len(n.Args))}
+	folds := exactFuncs[n.Func.Value]
+	for i, arg := range n.Args {
+		call.Args[i] = SimplifyNode(arg)
+		if _, ok := numValue(call.Args[i]); !ok {
+			folds = false
+		}
+	}
+
+	if folds {
+		if res, err := New().EvalNode(call); err == nil {
+			if x, ok := res.(*big.Rat); ok {
+				return constSum(x)
+			}
+		}
+	}
+
+	return atom(call, false)
+}
+
+// maxExpandTerms limits the number of terms products of sums are expanded
+// into
+const maxExpandTerms = 10000
+
+// Expand simplifies an expression like Simplify, and multiplies out products
+// and positive integer powers of sums.
+//
+// Example:
+//     tree, err := mathcat.Expand("(x + 1)**3")
+//     fmt.Println(tree) // x**3 + 3*x**2 + 3*x + 1
+func Expand(expr string) (Node, error) {
+	tree, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExpandNode(tree), nil
+}
+
+// ExpandNode expands a parsed expression like Expand
+func ExpandNode(n Node) Node {
+	return simplifySum(n).expand().node(n.Pos())
+}
+
+// expand multiplies out the sums in the factors of the terms
+func (s termSum) expand() termSum {
+	var res termSum
+	for _, t := range s {
+		prod := constSum(t.coef)
+		for _, f := range t.factors {
+			prod = prod.mulTerms(f.expand())
+		}
+		res = res.add(prod)
+	}
+
+	return res
+}
+
+// expand returns the factor as sum, multiplied out if it's a positive integer
+// power of a sum
+func (f *factor) expand() termSum {
+	single := termSum{{coef: big.NewRat(1, 1), factors: []*factor{f}}}
+	if f.sum == nil || f.rank == uniqueRank || !f.exp.IsInt() || f.exp.Sign() < 0 || !f.exp.Num().IsInt64() {
+		return single
+	}
+
+	inner := f.sum.expand()
+	res := constSum(ratOne)
+	for i := int64(0); i < f.exp.Num().Int64(); i++ {
+		if len(res)*len(inner) > maxExpandTerms {
+			return single
+		}
+		res = res.mulTerms(inner)
+	}
+
+	return res
+}
+
+// mulTerms multiplies two sums term by term
+func (s termSum) mulTerms(u termSum) termSum {
+	var res termSum
+	index := make(map[string]int)
+	for _, a := range s {
+		for _, b := range u {
+			t := a.mul(b)
+			if t.unique() {
+				res = append(res, t)
+				continue
+			}
+
+			key := t.key()
+			if i, ok := index[key]; ok {
+				res[i] = &term{coef: new(big.Rat).Add(res[i].coef, t.coef), factors: res[i].factors}
+			} else {
+				index[key] = len(res)
+				res = append(res, t)
+			}
+		}
+	}
+
+	terms := res[:0]
+	for _, t := range res {
+		if t.coef.Sign() != 0 {
+			terms = append(terms, t)
+		}
+	}
+
+	return terms
+}
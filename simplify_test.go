@@ -0,0 +1,146 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	exprs := map[string]string{
+		"x*1 + 0 + 2*3*y":                   "x + 6*y",
+		"x - x":                             "0",
+		"a*b - b*a":                         "0",
+		"x**2 * x**3":                       "x**5",
+		"(x**2)**3":                         "x**6",
+		"x**0":                              "1",
+		"1**y":                              "1",
+		"2**10 - 1/4":                       "4095/4",
+		"x/x":                               "1",
+		"2*(x + 1) - 2*x":                   "2",
+		"(x + 1)*(x + 1)":                   "(x + 1)**2",
+		"(x + 1)**2/(x + 1)":                "x + 1",
+		"sin(x)**2 + cos(x)**2 + sin(x)**2": "cos(x)**2 + 2*sin(x)**2",
+		"1 - x**2*3 + x":                    "x - 3*x**2 + 1",
+		"-x - 1":                            "-x - 1",
+		"0.25*x":                            "x/4",
+		"2*x/3*y":                           "2*x*y/3",
+		"x**-1 * y":                         "y/x",
+		"x**0.5*x**0.5":                     "x",
+		"(x**2)**0.5":                       "(x**2)**(1/2)",
+		"(2*x)**3":                          "8*x**3",
+		"y*x*2*x":                           "2*x**2*y",
+		"abs(-3) + max(2, 5)":               "8",
+		"sqrt(2)*sqrt(2)":                   "sqrt(2)**2",
+		"sin(x*1) + ln(2 + 3)":              "ln(5) + sin(x)",
+		"(1 + 2 > 2) + y*0":                 "1",
+		"~5 + x":                            "x - 6",
+		"1/0 + x":                           "1/0 + x",
+		"a = x*1 + 0":                       "a = x",
+		"[1, 1 + 1]*[3; 4]*2":               "2*[1, 2]*[3; 4]",
+		"rand() - rand()":                   "rand() - rand()",
+		"rand()*2 + rand()*3":               "2*rand() + 3*rand()",
+		"solve(x*1 = 2)":                    "solve(x*1 = 2)",
+		"10**30":                            "1000000000000000000000000000000",
+		"0*(1/0)":                           "0*(1/0)",
+		"rand()*0":                          "rand()*0",
+		"0*sin(x) + x**2*0":                 "0",
+		"0*ln(x)":                           "0*ln(x)",
+		"0/y":                               "0/y",
+		"0*[1, 2]":                          "0*[1, 2]",
+		"1/x*x":                             "1",
+		"x**2/x":                            "x",
+		"(x**0.5)**2":                       "x",
+	}
+
+	for expr, expected := range exprs {
+		tree, err := Simplify(expr)
+		if err != nil {
+			t.Errorf("unexpected error simplifying '%s': %s", expr, err)
+			continue
+		}
+
+		if tree.String() != expected {
+			t.Errorf("wrong simplification of '%s' (expected %s, got %s)", expr, expected, tree)
+		}
+
+		// Simplifying again doesn't change anything
+		again, err := Simplify(tree.String())
+		if err != nil || again.String() != expected {
+			t.Errorf("simplification of '%s' isn't canonical: %v, %v", expr, again, err)
+		}
+	}
+
+	if _, err := Simplify("1 +"); err == nil {
+		t.Error("expected error simplifying bad expression")
+	}
+
+	// Errors aren't simplified away
+	tree, err := Simplify("0*(1/0)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New().EvalNode(tree); err != ErrDivisionByZero {
+		t.Errorf("expected division by zero evaluating '%s', got %v", tree, err)
+	}
+}
+
+func TestSimplifyValue(t *testing.T) {
+	// Simplified expressions have to evaluate to the same value
+	exprs := []string{
+		"x*1 + 0 + 2*3*y", "(x + y)**3/(x + y) - x", "x**2 * x**-3 + 1/x",
+		"3*(x - 2*y) - (y - x)*2", "x % 3 + x % 3", "(2*x/3)**-2",
+		"-x**2 + 1", "hypot(x, y)*2/hypot(x, y)**2", "[x, 2*x; 3, y*y]*2",
+		"(x > y) + (x > y)", "e**x * e**-x", "x**y / x",
+	}
+
+	vars := map[string]*big.Rat{"x": big.NewRat(7, 2), "y": big.NewRat(-5, 3)}
+	for _, expr := range exprs {
+		tree, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := New().evalNode(tree, vars)
+		if err != nil {
+			t.Errorf("unexpected error evaluating '%s': %s", expr, err)
+			continue
+		}
+
+		res, err := New().evalNode(SimplifyNode(tree), vars)
+		if err != nil {
+			t.Errorf("unexpected error evaluating simplified '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected.String() {
+			t.Errorf("simplification of '%s' changed its value (expected %s, got %s)", expr, expected, res)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	exprs := map[string]string{
+		"(x + 1)**3":          "x**3 + 3*x**2 + 3*x + 1",
+		"(x - y)*(x + y)":     "x**2 - y**2",
+		"(a + b)**2 - a*a":    "2*a*b + b**2",
+		"2*(x + 1)*(x - 1)/4": "x**2/2 - 1/2",
+		"(x + 1)**-2":         "1/(x + 1)**2",
+		"sin(x)*(x + 1)":      "x*sin(x) + sin(x)",
+	}
+
+	for expr, expected := range exprs {
+		tree, err := Expand(expr)
+		if err != nil {
+			t.Errorf("unexpected error expanding '%s': %s", expr, err)
+			continue
+		}
+
+		if tree.String() != expected {
+			t.Errorf("wrong expansion of '%s' (expected %s, got %s)", expr, expected, tree)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// maxExpansions limits how far solve searches around a guess for a sign change
+const maxExpansions = 64
+
+var (
+	ErrNotEquation  = errors.New("Expecting an equation like ‘x + y == 1’")
+	ErrNotLinear    = errors.New("Equations aren't linear in the unknowns")
+	ErrNoUnknowns   = errors.New("No unknowns to solve for")
+	ErrNoSignChange = errors.New("Function doesn't change sign between the bounds")
+)
+
+// solve solves linear equations, or numerically finds a root of an expression
+// if the second argument is a variable
+func solve(p *Parser, args []*Expr) (Value, error) {
+	if len(args) < 2 {
+		return solveLinear(p, args)
+	}
+	if _, err := args[1].Ident(); err != nil {
+		return solveLinear(p, args)
+	}
+
+	if len(args) > 4 {
+		return nil, fmt.Errorf("Invalid argument count for ‘solve’ (expected 3 to 4, got %d)", len(args))
+	}
+	if len(args) < 3 {
+		return nil, fmt.Errorf("Expecting a guess or bounds for ‘solve’")
+	}
+
+	return solveNumeric(p, args)
+}
+
+// linsolve solves m * x = b. The solution has the same shape as b.
+func linsolve(m, b *Matrix) (Value, error) {
+	sol, err := m.Solve(b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, m.Cols)
+	for i := range names {
+		names[i] = fmt.Sprintf("x%d", i+1)
+	}
+	if !sol.Unique() {
+		return nil, infiniteSolutions(sol, names)
+	}
+
+	if b.Rows == 1 {
+		return sol.Particular.Transpose(), nil
+	}
+	return sol.Particular, nil
+}
+
+func infiniteSolutions(sol *Solution, names []string) error {
+	return fmt.Errorf("Infinitely many solutions: %s", sol.Describe(names))
+}
+
+// solveLinear solves a system of linear equations like 2*x + y == 3. Unknowns
+// are the variables that aren't defined. The result is the value of the
+// unknown if there's one, otherwise a vector with the unknowns in order of
+// appearance.
+func solveLinear(p *Parser, args []*Expr) (Value, error) {
+	var (
+		unknowns []string
+		index    = make(map[string]int)
+	)
+
+	for _, arg := range args {
+		eq, ok := arg.node.(*BinaryNode)
+		if !ok || !eq.Op.Is(EqEq) {
+			return nil, ErrNotEquation
+		}
+
+		Inspect(eq, func(n Node) bool {
+			if ident, ok := n.(*IdentNode); ok && arg.isUnknown(ident.Name()) {
+				if _, seen := index[ident.Name()]; !seen {
+					index[ident.Name()] = len(unknowns)
+					unknowns = append(unknowns, ident.Name())
+				}
+			}
+			return true
+		})
+	}
+
+	if len(unknowns) == 0 {
+		return nil, ErrNoUnknowns
+	}
+
+	// Move everything to the left hand side, so each equation becomes a row
+	// of coefficients with the negated constant on the right
+	m := NewMatrix(len(args), len(unknowns))
+	b := NewMatrix(len(args), 1)
+	for i, arg := range args {
+		eq := arg.node.(*BinaryNode)
+
+		lhs, err := arg.linearize(eq.Lhs, index)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := arg.linearize(eq.Rhs, index)
+		if err != nil {
+			return nil, err
+		}
+
+		lhs.sub(rhs)
+		for j, coef := range lhs.coeffs {
+			m.At(i, j).Set(coef)
+		}
+		b.Data[i].Neg(lhs.c)
+	}
+
+	sol, err := m.Solve(b)
+	if err != nil {
+		return nil, err
+	}
+	if !sol.Unique() {
+		return nil, infiniteSolutions(sol, unknowns)
+	}
+
+	if len(unknowns) == 1 {
+		return sol.Particular.Data[0], nil
+	}
+	return sol.Particular.Transpose(), nil
+}
+
+// affine is a linear combination of unknowns plus a constant
+type affine struct {
+	coeffs []*big.Rat
+	c      *big.Rat
+}
+
+func newAffine(n int) *affine {
+	a := &affine{coeffs: make([]*big.Rat, n), c: new(big.Rat)}
+	for i := range a.coeffs {
+		a.coeffs[i] = new(big.Rat)
+	}
+
+	return a
+}
+
+func (a *affine) isConst() bool {
+	for _, coef := range a.coeffs {
+		if coef.Sign() != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *affine) add(b *affine) {
+	for i, coef := range a.coeffs {
+		coef.Add(coef, b.coeffs[i])
+	}
+	a.c.Add(a.c, b.c)
+}
+
+func (a *affine) sub(b *affine) {
+	for i, coef := range a.coeffs {
+		coef.Sub(coef, b.coeffs[i])
+	}
+	a.c.Sub(a.c, b.c)
+}
+
+func (a *affine) scale(x *big.Rat) {
+	for _, coef := range a.coeffs {
+		coef.Mul(coef, x)
+	}
+	a.c.Mul(a.c, x)
+}
+
+// isUnknown reports whether a variable isn't defined in the scope of e
+func (e *Expr) isUnknown(name string) bool {
+	if _, ok := e.scope[name]; ok {
+		return false
+	}
+	if _, ok := e.p.Variables[name]; ok {
+		return false
+	}
+	_, ok := e.p.Matrices[name]
+	return !ok
+}
+
+// linearize writes n as a linear combination of the unknowns in index.
+// Subexpressions without unknowns are evaluated.
+func (e *Expr) linearize(n Node, index map[string]int) (*affine, error) {
+	hasUnknowns := false
+	Inspect(n, func(n Node) bool {
+		if ident, ok := n.(*IdentNode); ok && e.isUnknown(ident.Name()) {
+			hasUnknowns = true
+		}
+		return !hasUnknowns
+	})
+
+	if !hasUnknowns {
+		val, err := e.p.evalNode(n, e.scope)
+		if err != nil {
+			return nil, err
+		}
+		x, err := scalar(val)
+		if err != nil {
+			return nil, err
+		}
+
+		res := newAffine(len(index))
+		res.c.Set(x)
+		return res, nil
+	}
+
+	switch n := n.(type) {
+	case *IdentNode:
+		res := newAffine(len(index))
+		res.coeffs[index[n.Name()]].SetInt64(1)
+		return res, nil
+	case *UnaryNode:
+		if !n.Op.Is(UnaryMin) {
+			break
+		}
+
+		res, err := e.linearize(n.X, index)
+		if err != nil {
+			return nil, err
+		}
+		res.scale(big.NewRat(-1, 1))
+		return res, nil
+	case *BinaryNode:
+		lhs, err := e.linearize(n.Lhs, index)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := e.linearize(n.Rhs, index)
+		if err != nil {
+			return nil, err
+		}
+
+		switch n.Op.Type {
+		case Add:
+			lhs.add(rhs)
+			return lhs, nil
+		case Sub:
+			lhs.sub(rhs)
+			return lhs, nil
+		case Mul:
+			// One of both sides has to be constant
+			if lhs.isConst() {
+				rhs.scale(lhs.c)
+				return rhs, nil
+			}
+			if rhs.isConst() {
+				lhs.scale(rhs.c)
+				return lhs, nil
+			}
+		case Div:
+			if rhs.isConst() {
+				if rhs.c.Sign() == 0 {
+					return nil, ErrDivisionByZero
+				}
+				lhs.scale(new(big.Rat).Inv(rhs.c))
+				return lhs, nil
+			}
+		}
+	}
+
+	return nil, ErrNotLinear
+}
+
+// root is a function of one variable that is searched for a root. Equations
+// like lhs == rhs are searched for a root of lhs - rhs.
+type root struct {
+	*numeric
+	f *realFunc
+}
+
+// brent finds a root between a and b, where f(a) and f(b) have different
+// signs, using Brent's method
+func (r *root) brent(a, b, fa, fb *big.Float) (*big.Float, error) {
+	var (
+		two   = newFloat(r.prec, 2)
+		three = newFloat(r.prec, 3)
+	)
+
+	if fa.Sign()*fb.Sign() > 0 {
+		return nil, ErrNoSignChange
+	}
+
+	c, fc := a, fa
+	d := r.float().Sub(b, a)
+	e := d
+
+	for i := 0; i < r.maxIter; i++ {
+		if fb.Sign() != 0 && fb.Sign() == fc.Sign() {
+			c, fc = a, fa
+			d = r.float().Sub(b, a)
+			e = d
+		}
+		if r.abs(fc).Cmp(r.abs(fb)) < 0 {
+			a, b, c = b, c, b
+			fa, fb, fc = fb, fc, fb
+		}
+
+		// tol1 = 2*eps*|b| + tol/2, m = (c - b)/2
+		tol1 := r.float().Mul(two, r.eps)
+		tol1.Mul(tol1, r.abs(b))
+		tol1.Add(tol1, r.float().Quo(r.tol, two))
+		m := r.float().Sub(c, b)
+		m.Quo(m, two)
+
+		if r.abs(m).Cmp(tol1) <= 0 || fb.Sign() == 0 {
+			return b, nil
+		}
+
+		if r.abs(e).Cmp(tol1) >= 0 && r.abs(fa).Cmp(r.abs(fb)) > 0 {
+			// Try interpolation, secant if there are only two points and
+			// inverse quadratic otherwise
+			var p, q *big.Float
+			s := r.float().Quo(fb, fa)
+			if a.Cmp(c) == 0 {
+				p = r.float().Mul(two, m)
+				p.Mul(p, s)
+				q = r.float().Sub(newFloat(r.prec, 1), s)
+			} else {
+				q = r.float().Quo(fa, fc)
+				t := r.float().Quo(fb, fc)
+
+				// p = s*(2*m*q*(q - t) - (b - a)*(t - 1))
+				p = r.float().Mul(two, m)
+				p.Mul(p, q)
+				p.Mul(p, r.float().Sub(q, t))
+				p.Sub(p, r.float().Mul(r.float().Sub(b, a), r.float().Sub(t, newFloat(r.prec, 1))))
+				p.Mul(p, s)
+
+				// q = (q - 1)*(t - 1)*(s - 1)
+				q.Sub(q, newFloat(r.prec, 1))
+				q.Mul(q, r.float().Sub(t, newFloat(r.prec, 1)))
+				q.Mul(q, r.float().Sub(s, newFloat(r.prec, 1)))
+			}
+			if p.Sign() > 0 {
+				q.Neg(q)
+			} else {
+				p.Neg(p)
+			}
+
+			// Accept the interpolation only if it falls well within the
+			// bracket, otherwise bisect
+			bound := r.float().Mul(three, m)
+			bound.Mul(bound, q)
+			bound.Sub(bound, r.abs(r.float().Mul(tol1, q)))
+			if eq := r.abs(r.float().Mul(e, q)); eq.Cmp(bound) < 0 {
+				bound = eq
+			}
+			if r.float().Mul(two, p).Cmp(bound) < 0 {
+				e = d
+				d = r.float().Quo(p, q)
+			} else {
+				d, e = m, m
+			}
+		} else {
+			d, e = m, m
+		}
+
+		a, fa = b, fb
+		if r.abs(d).Cmp(tol1) > 0 {
+			b = r.float().Add(b, d)
+		} else if m.Sign() > 0 {
+			b = r.float().Add(b, tol1)
+		} else {
+			b = r.float().Sub(b, tol1)
+		}
+
+		var err error
+		if fb, err = r.f.eval(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// near finds a root near x. It first searches for a sign change in growing
+// steps on both sides of x and uses Brent's method on it. If there is none,
+// like at a double root, it falls back to the secant method.
+func (r *root) near(x *big.Float) (*big.Float, error) {
+	fx, err := r.f.eval(x)
+	if err != nil {
+		return nil, err
+	}
+	if fx.Sign() == 0 {
+		return x, nil
+	}
+
+	// Start with a step relative to the size of x
+	step := r.abs(x)
+	step.Add(step, newFloat(r.prec, 1))
+	step.SetMantExp(step, -10)
+
+	sides := []*bracket{
+		{dir: 1, last: x, flast: fx},
+		{dir: -1, last: x, flast: fx},
+	}
+	for i := 0; i < maxExpansions; i++ {
+		searching := false
+		for _, side := range sides {
+			if side.blocked {
+				continue
+			}
+			searching = true
+
+			next := r.float().Mul(step, newFloat(r.prec, int64(side.dir)))
+			next.Add(x, next)
+			fnext, err := side.expand(r, next)
+			if err != nil {
+				return nil, err
+			}
+			if fnext != nil && fnext.Sign() != fx.Sign() {
+				return r.brent(side.last, next, side.flast, fnext)
+			}
+			if fnext != nil {
+				side.last, side.flast = next, fnext
+			}
+		}
+		if !searching {
+			break
+		}
+
+		step.Add(step, step)
+	}
+
+	return r.secant(x, fx)
+}
+
+// bracket is one side of the search for a sign change around a guess
+type bracket struct {
+	dir         int
+	last, flast *big.Float
+	blocked     bool
+}
+
+// expand evaluates the function at next. If it can't be evaluated there, like
+// sqrt(x) at x < 0, points halfway back to the last point are tried, and next
+// is moved to the first one that works. If none do, the side is blocked and a
+// nil value is returned.
+func (b *bracket) expand(r *root, next *big.Float) (*big.Float, error) {
+	for i := 0; i < maxExpansions; i++ {
+		fnext, err := r.f.eval(next)
+		if err == nil {
+			return fnext, nil
+		}
+
+		next.Add(next, b.last)
+		next.SetMantExp(next, -1)
+	}
+
+	b.blocked = true
+	return nil, nil
+}
+
+// secant finds a root with the secant method, starting at x
+func (r *root) secant(x0, f0 *big.Float) (*big.Float, error) {
+	two := newFloat(r.prec, 2)
+
+	x1 := r.abs(x0)
+	x1.Add(x1, newFloat(r.prec, 1))
+	x1.SetMantExp(x1, -10)
+	x1.Add(x0, x1)
+	f1, err := r.f.eval(x1)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < r.maxIter; i++ {
+		if f1.Sign() == 0 {
+			return x1, nil
+		}
+
+		// Flat secant, no root in sight
+		df := r.float().Sub(f1, f0)
+		if df.Sign() == 0 {
+			break
+		}
+
+		step := r.float().Sub(x1, x0)
+		step.Mul(step, f1)
+		step.Quo(step, df)
+
+		x0, f0 = x1, f1
+		x1 = r.float().Sub(x1, step)
+		if f1, err = r.f.eval(x1); err != nil {
+			return nil, err
+		}
+
+		tol := r.float().Mul(two, r.eps)
+		tol.Mul(tol, r.abs(x1))
+		tol.Add(tol, r.tol)
+		if r.abs(step).Cmp(tol) <= 0 {
+			return x1, nil
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// solveNumeric finds a root of an expression in a variable, either near a
+// guess or between two bounds. The variable is only bound while evaluating the
+// expression.
+func solveNumeric(p *Parser, args []*Expr) (Value, error) {
+	n := newNumeric(p)
+	f, bounds, err := funcArgs(n, args)
+	if err != nil {
+		return nil, err
+	}
+	f.diff = true
+	r := &root{n, f}
+
+	var res *big.Float
+	if len(bounds) == 1 {
+		res, err = r.near(bounds[0])
+	} else {
+		lo, hi := bounds[0], bounds[1]
+
+		var flo, fhi *big.Float
+		if flo, err = r.f.eval(lo); err != nil {
+			return nil, err
+		}
+		if fhi, err = r.f.eval(hi); err != nil {
+			return nil, err
+		}
+		res, err = r.brent(lo, hi, flo, fhi)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return floatToRat(res.SetPrec(floatPrec(p)))
+}
@@ -0,0 +1,155 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSolveLinear(t *testing.T) {
+	exprs := map[string]string{
+		"linsolve([[2, 1], [1, 3]], [3, 5])":              "[4/5, 7/5]",
+		"linsolve([2, 1; 1, 3], [3; 5])":                  "[4/5; 7/5]",
+		"linsolve([1, 1; 1, -1; 2, 0], [2, 0, 2])":        "[1, 1]",
+		"solve(2*x + y == 3, x + 3*y == 5)":               "[4/5, 7/5]",
+		"solve(2*x == 4)":                                 "2/1",
+		"solve(x / 3 - 1 == 2)":                           "9/1",
+		"solve(3*(x - 1) == x)":                           "3/2",
+		"solve(y == 2*x, x + y == 3)":                     "[2, 1]", // y comes first
+		"solve(-(a - b) == 1, a + b == 3)":                "[1, 2]",
+		"solve(x + y + z == 6, x - y == 0, 2*z == x + 4)": "[8/5, 8/5, 14/5]",
+	}
+
+	for expr, expected := range exprs {
+		res, err := EvalValue(expr)
+		if err != nil {
+			t.Errorf("unexpected error solving '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong solution for '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	errors := map[string]string{
+		"solve(x + y == 1, 2*x + 2*y == 2)":    "Infinitely many solutions: x = 1 - y, y free",
+		"linsolve([1, 2, 3; 2, 4, 6], [1, 2])": "Infinitely many solutions: x1 = 1 - 2*x2 - 3*x3, x2 free, x3 free",
+		"solve(x + y == 1, x + y == 2)":        ErrNoSolution.Error(),
+		"linsolve([1, 2; 2, 4], [1, 3])":       ErrNoSolution.Error(),
+		"solve(x * y == 1)":                    ErrNotLinear.Error(),
+		"solve(x ** 2 == 1)":                   ErrNotLinear.Error(),
+		"solve(1 / x == 1)":                    ErrNotLinear.Error(),
+		"solve(x + 1)":                         ErrNotEquation.Error(),
+		"solve(1 == 1)":                        ErrNoUnknowns.Error(),
+		"linsolve([1, 2; 3, 4], [1, 2, 3])":    ErrDimMismatch.Error(),
+		"linsolve([1, 2; 3, 4], [1, 2; 3, 4])": ErrNotVector.Error(),
+	}
+
+	for expr, expected := range errors {
+		_, err := EvalValue(expr)
+		if err == nil || err.Error() != expected {
+			t.Errorf("wrong error solving '%s' (expected %s, got %v)", expr, expected, err)
+		}
+	}
+}
+
+func TestSolveKnownVariables(t *testing.T) {
+	p := New()
+	p.Run("k = 2")
+
+	// Defined variables are constants, not unknowns
+	res, err := p.Run("solve(k * x == 1)")
+	if err != nil || res.RatString() != "1/2" {
+		t.Errorf("wrong solution with known variable: %v, %v", res, err)
+	}
+
+	if _, err := p.GetVar("x"); err == nil {
+		t.Error("solve shouldn't assign the unknowns")
+	}
+}
+
+func TestSolveNumeric(t *testing.T) {
+	calls := map[string]string{
+		"solve(x**3 - 2*x - 5, x, 2)":     "2.094551481542327",
+		"solve(x**3 - 2*x == 5, x, 0, 3)": "2.094551481542327",
+		"solve(x**2 - 2, x, 1)":           "1.414213562373095",
+		"solve(x**2 - 2, x, -1)":          "-1.414213562373095",
+		"solve(cos(x) == x, x, 0, 1)":     "0.739085133215161",
+		"solve(x**2 - 4, x, 0, 5)":        "2.000000000000000",
+		"solve(x**2, x, 1)":               "0.000000000000000",
+		"solve(sqrt(x) - 2, x, 1)":        "4.000000000000000",
+		"solve(ln(x), x, 5)":              "1.000000000000000",
+		"solve(x - 10**20, x, 0)":         "100000000000000000000.000000000000000",
+		"solve(y**2 - 9, y, 1) + 1":       "4.000000000000000",
+	}
+
+	for expr, expected := range calls {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error solving '%s': %s", expr, err)
+			continue
+		}
+
+		if res.FloatString(15) != expected {
+			t.Errorf("wrong solution for '%s' (expected %s, got %s)", expr, expected, res.FloatString(15))
+		}
+	}
+
+	errors := map[string]string{
+		"solve(x**2 + 1, x, 0)":       ErrNoConvergence.Error(),
+		"solve(x**2 - 4, x, 3, 5)":    ErrNoSignChange.Error(),
+		"solve(x**2 - 4, x)":          "Expecting a guess or bounds for ‘solve’",
+		"solve(x**2 - 4, x, 1, 2, 3)": "Invalid argument count for ‘solve’ (expected 3 to 4, got 5)",
+		"solve(x - y, x, 1)":          "Undefined variable ‘y’",
+	}
+
+	for expr, expected := range errors {
+		_, err := EvalValue(expr)
+		if err == nil || err.Error() != expected {
+			t.Errorf("wrong error solving '%s' (expected %s, got %v)", expr, expected, err)
+		}
+	}
+}
+
+func TestSolveNumericScope(t *testing.T) {
+	p := New()
+	p.Run("x = 100")
+	p.Run("a = 3")
+
+	res, err := p.Run("solve(x**2 - a, x, 1)")
+	if err != nil || res.FloatString(15) != "1.732050807568877" {
+		t.Errorf("wrong solution with known variable: %v, %v", res, err)
+	}
+
+	// The bound variable shadows x without changing it
+	if x, _ := p.GetVar("x"); x.Cmp(big.NewRat(100, 1)) != 0 {
+		t.Errorf("solve changed bound variable to %s", x)
+	}
+}
+
+func TestSolveNumericSettings(t *testing.T) {
+	p := New()
+
+	p.Precision = 200
+	res, err := p.Run("solve(x**2 - 2, x, 1)")
+	if err != nil || res.FloatString(50) != "1.41421356237309504880168872420969807856967187537695" {
+		t.Errorf("wrong solution at high precision: %v, %v", res.FloatString(50), err)
+	}
+
+	p.Precision = 0
+	p.Tolerance = 1e-3
+	res, err = p.Run("solve(x**2 - 2, x, 0, 2)")
+	if err != nil || res.FloatString(2) != "1.41" {
+		t.Errorf("wrong solution with tolerance: %v, %v", res.FloatString(10), err)
+	}
+
+	p.Tolerance = 0
+	p.MaxIterations = 3
+	if _, err := p.Run("solve(x**2 - 2, x, 0, 2)"); err != ErrNoConvergence {
+		t.Errorf("expected ErrNoConvergence with iteration limit, got %v", err)
+	}
+}
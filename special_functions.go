@@ -0,0 +1,771 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"sync"
+)
+
+// All special functions take and return big.Floats, calculated with prec bits
+// of mantissa. Integer arguments of gamma and fact are calculated exactly.
+
+// maxIterations bounds the series and continued fractions of special
+// functions, which converge long before it at any sensible precision
+const maxIterations = 100000
+
+var (
+	ErrPole           = errors.New("Function has a pole at given argument")
+	ErrNoConvergence  = errors.New("Calculation did not converge")
+	ErrArgTooLarge    = errors.New("Argument too large")
+	bernoulliCache    []*big.Rat
+	bernoulliCacheMtx sync.Mutex
+)
+
+// bernoulli returns the Bernoulli numbers B_0, B_2, B_4, ..., B_2n
+func bernoulli(n int) []*big.Rat {
+	bernoulliCacheMtx.Lock()
+	defer bernoulliCacheMtx.Unlock()
+
+	if len(bernoulliCache) > n {
+		return bernoulliCache
+	}
+
+	// Akiyama-Tanigawa algorithm, giving B_m for m up to 2n
+	m := 2 * n
+	a := make([]*big.Rat, m+1)
+	res := make([]*big.Rat, 0, n+1)
+	for i := 0; i <= m; i++ {
+		a[i] = big.NewRat(1, int64(i+1))
+		for j := i; j >= 1; j-- {
+			a[j-1].Sub(a[j-1], a[j])
+			a[j-1].Mul(a[j-1], big.NewRat(int64(j), 1))
+		}
+		if i%2 == 0 {
+			res = append(res, new(big.Rat).Set(a[0]))
+		}
+	}
+
+	bernoulliCache = res
+	return res
+}
+
+func ratFloat(x *big.Rat, prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec).SetRat(x)
+}
+
+// isNonPositiveInt reports whether x is 0, -1, -2, ...
+func isNonPositiveInt(x *big.Float) bool {
+	return x.IsInt() && x.Sign() <= 0
+}
+
+// stirlingShift returns the number x has to be shifted up by before the
+// asymptotic series for lgamma and digamma reach the working precision
+func stirlingShift(x *big.Float, prec uint) int64 {
+	min := int64(float64(prec)*0.12) + 2
+	xf, _ := x.Float64()
+	if xf >= float64(min) {
+		return 0
+	}
+
+	return min - int64(math.Floor(xf))
+}
+
+// lgammaPositive calculates ln(gamma(x)) for x >= 0.5 using Stirling's series
+func lgammaPositive(x *big.Float, prec uint) *big.Float {
+	wp := prec + guardBits
+
+	// Shift x up, lgamma(x) = lgamma(x + n) - ln(x * (x+1) * ... * (x+n-1))
+	shift := stirlingShift(x, wp)
+	y := new(big.Float).SetPrec(wp).Add(x, newFloat(wp, shift))
+	product := newFloat(wp, 1)
+	for i := int64(0); i < shift; i++ {
+		product.Mul(product, new(big.Float).SetPrec(wp).Add(x, newFloat(wp, i)))
+	}
+
+	// (y - 1/2) * ln(y) - y + ln(2 * pi) / 2
+	lnY, _ := bigLog(y, wp)
+	res := new(big.Float).SetPrec(wp).Sub(y, big.NewFloat(0.5))
+	res.Mul(res, lnY)
+	res.Sub(res, y)
+	twoPi := bigPi(wp)
+	lnTwoPi, _ := bigLog(twoPi.SetMantExp(twoPi, 1), wp)
+	res.Add(res, lnTwoPi.SetMantExp(lnTwoPi, -1))
+
+	// + sum B_2k / (2k * (2k - 1) * y**(2k-1))
+	ySq := new(big.Float).SetPrec(wp).Mul(y, y)
+	power := new(big.Float).SetPrec(wp).Set(y)
+	for k := 1; ; k++ {
+		b := bernoulli(k)[k]
+		term := ratFloat(b, wp)
+		term.Quo(term, newFloat(wp, int64(2*k*(2*k-1))))
+		term.Quo(term, power)
+		if negligible(term, res, wp) {
+			break
+		}
+		res.Add(res, term)
+		power.Mul(power, ySq)
+	}
+
+	if shift > 0 {
+		lnProduct, _ := bigLog(product, wp)
+		res.Sub(res, lnProduct)
+	}
+
+	return res.SetPrec(prec)
+}
+
+// Gamma calculates the gamma function of x
+func Gamma(x *big.Float, prec uint) (*big.Float, error) {
+	if isNonPositiveInt(x) {
+		return nil, ErrPole
+	}
+
+	// Exact for positive integers that aren't too large, gamma(n) = (n-1)!
+	if x.IsInt() {
+		n, _ := x.Int(nil)
+		if fact, err := factorialInt(n.Sub(n, bigOne)); err == nil {
+			return new(big.Float).SetPrec(prec).SetInt(fact), nil
+		}
+	}
+
+	wp := prec + guardBits
+	if x.Cmp(big.NewFloat(0.5)) < 0 {
+		// Reflection formula, gamma(x) = pi / (sin(pi * x) * gamma(1 - x))
+		pi := bigPi(wp)
+		sin, _ := bigSinCos(new(big.Float).SetPrec(wp).Mul(pi, x), wp)
+		g, err := Gamma(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), x), wp)
+		if err != nil {
+			return nil, err
+		}
+		return pi.Quo(pi, sin.Mul(sin, g)).SetPrec(prec), nil
+	}
+
+	return bigExp(lgammaPositive(x, wp), prec), nil
+}
+
+// Lgamma calculates the natural logarithm of the absolute value of gamma(x)
+func Lgamma(x *big.Float, prec uint) (*big.Float, error) {
+	if isNonPositiveInt(x) {
+		return nil, ErrPole
+	}
+
+	wp := prec + guardBits
+	if x.Cmp(big.NewFloat(0.5)) < 0 {
+		// ln|gamma(x)| = ln(pi) - ln|sin(pi * x)| - lgamma(1 - x)
+		pi := bigPi(wp)
+		sin, _ := bigSinCos(new(big.Float).SetPrec(wp).Mul(pi, x), wp)
+		lnPi, _ := bigLog(pi, wp)
+		lnSin, err := bigLog(sin.Abs(sin), wp)
+		if err != nil {
+			return nil, err
+		}
+		res := lnPi.Sub(lnPi, lnSin)
+		return res.Sub(res, lgammaPositive(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), x), wp)).SetPrec(prec), nil
+	}
+
+	return lgammaPositive(x, prec), nil
+}
+
+// Beta calculates the beta function gamma(a) * gamma(b) / gamma(a + b)
+func Beta(a, b *big.Float, prec uint) (*big.Float, error) {
+	wp := prec + guardBits
+	ga, err := Gamma(a, wp)
+	if err != nil {
+		return nil, err
+	}
+	gb, err := Gamma(b, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := new(big.Float).SetPrec(wp).Add(a, b)
+	if isNonPositiveInt(sum) {
+		return newFloat(prec, 0), nil
+	}
+
+	gab, err := Gamma(sum, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return ga.Mul(ga, gb).Quo(ga, gab).SetPrec(prec), nil
+}
+
+// gammaIncPrefix calculates x**a * e**-x / gamma(a)
+func gammaIncPrefix(a, x *big.Float, wp uint) (*big.Float, error) {
+	lnX, err := bigLog(x, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	lg, err := Lgamma(a, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	exp := lnX.Mul(lnX, a)
+	exp.Sub(exp, x)
+	exp.Sub(exp, lg)
+
+	return bigExp(exp, wp), nil
+}
+
+// gammaIncSeries calculates the regularized lower incomplete gamma function
+// P(a, x) with its power series, which converges quickly for x < a + 1
+func gammaIncSeries(a, x *big.Float, wp uint) (*big.Float, error) {
+	prefix, err := gammaIncPrefix(a, x, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	// P(a, x) = prefix * sum x**n / (a * (a+1) * ... * (a+n))
+	term := newFloat(wp, 1)
+	term.Quo(term, a)
+	sum := new(big.Float).SetPrec(wp).Set(term)
+	for n := int64(1); ; n++ {
+		if n > int64(maxIterations) {
+			return nil, ErrNoConvergence
+		}
+
+		term.Mul(term, x)
+		term.Quo(term, new(big.Float).SetPrec(wp).Add(a, newFloat(wp, n)))
+		if negligible(term, sum, wp) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+
+	return sum.Mul(sum, prefix), nil
+}
+
+// lentz evaluates the continued fraction b0 + a1/(b1 + a2/(b2 + ...)) using
+// the modified Lentz method. coef returns a_n and b_n.
+func lentz(b0 *big.Float, coef func(n int64) (a, b *big.Float), wp uint) (*big.Float, error) {
+	tiny := new(big.Float).SetPrec(wp).SetMantExp(newFloat(wp, 1), -int(wp)*2)
+	one := newFloat(wp, 1)
+
+	f := new(big.Float).SetPrec(wp).Set(b0)
+	if f.Sign() == 0 {
+		f.Set(tiny)
+	}
+	c := new(big.Float).SetPrec(wp).Set(f)
+	d := newFloat(wp, 0)
+	delta := new(big.Float).SetPrec(wp)
+
+	for n := int64(1); n <= int64(maxIterations); n++ {
+		a, b := coef(n)
+
+		// d = 1 / (b + a * d)
+		d.Mul(a, d)
+		d.Add(d, b)
+		if d.Sign() == 0 {
+			d.Set(tiny)
+		}
+		d.Quo(one, d)
+
+		// c = b + a / c
+		c.Quo(a, c)
+		c.Add(c, b)
+		if c.Sign() == 0 {
+			c.Set(tiny)
+		}
+
+		delta.Mul(c, d)
+		f.Mul(f, delta)
+
+		// Rounding errors keep delta from reaching exactly 1, so stop a bit
+		// before the working precision
+		if negligible(delta.Sub(delta, one), one, wp-guardBits/2) {
+			return f, nil
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// gammaIncFraction calculates the regularized upper incomplete gamma function
+// Q(a, x) with its continued fraction, which converges quickly for x >= a + 1
+func gammaIncFraction(a, x *big.Float, wp uint) (*big.Float, error) {
+	prefix, err := gammaIncPrefix(a, x, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Q(a, x) = prefix / (x + 1 - a - 1*(1-a) / (x + 3 - a - 2*(2-a) / ...))
+	b0 := new(big.Float).SetPrec(wp).Add(x, newFloat(wp, 1))
+	b0.Sub(b0, a)
+	frac, err := lentz(b0, func(n int64) (*big.Float, *big.Float) {
+		an := new(big.Float).SetPrec(wp).Sub(a, newFloat(wp, n))
+		an.Mul(an, newFloat(wp, n))
+		bn := new(big.Float).SetPrec(wp).Add(x, newFloat(wp, 2*n+1))
+		bn.Sub(bn, a)
+		return an, bn
+	}, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return prefix.Quo(prefix, frac), nil
+}
+
+// GammaInc calculates the regularized lower incomplete gamma function P(a, x)
+// for a > 0 and x >= 0
+func GammaInc(a, x *big.Float, prec uint) (*big.Float, error) {
+	p, q, err := gammaIncPQ(a, x, prec)
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		return p, nil
+	}
+
+	return q.Sub(newFloat(prec, 1), q), nil
+}
+
+// GammaIncComp calculates the regularized upper incomplete gamma function
+// Q(a, x) = 1 - P(a, x) for a > 0 and x >= 0
+func GammaIncComp(a, x *big.Float, prec uint) (*big.Float, error) {
+	p, q, err := gammaIncPQ(a, x, prec)
+	if err != nil {
+		return nil, err
+	}
+	if q != nil {
+		return q, nil
+	}
+
+	return p.Sub(newFloat(prec, 1), p), nil
+}
+
+// gammaIncPQ calculates either P(a, x) or Q(a, x), whichever is more accurate
+func gammaIncPQ(a, x *big.Float, prec uint) (p, q *big.Float, err error) {
+	if a.Sign() <= 0 || x.Sign() < 0 {
+		return nil, nil, ErrDomain
+	}
+
+	if x.Sign() == 0 {
+		return newFloat(prec, 0), nil, nil
+	}
+
+	wp := prec + guardBits
+	limit := new(big.Float).SetPrec(wp).Add(a, newFloat(wp, 1))
+	if x.Cmp(limit) < 0 {
+		p, err = gammaIncSeries(a, x, wp)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p.SetPrec(prec), nil, nil
+	}
+
+	q, err = gammaIncFraction(a, x, wp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, q.SetPrec(prec), nil
+}
+
+// BetaInc calculates the regularized incomplete beta function I_x(a, b) for
+// 0 <= x <= 1 and a, b > 0
+func BetaInc(x, a, b *big.Float, prec uint) (*big.Float, error) {
+	one := newFloat(prec, 1)
+	if x.Sign() < 0 || x.Cmp(one) > 0 || a.Sign() <= 0 || b.Sign() <= 0 {
+		return nil, ErrDomain
+	}
+
+	if x.Sign() == 0 || x.Cmp(one) == 0 {
+		return new(big.Float).SetPrec(prec).Set(x), nil
+	}
+
+	wp := prec + guardBits
+
+	// The continued fraction converges quickly for x < (a + 1) / (a + b + 2),
+	// use I_x(a, b) = 1 - I_(1-x)(b, a) otherwise
+	limit := new(big.Float).SetPrec(wp).Add(a, newFloat(wp, 1))
+	den := new(big.Float).SetPrec(wp).Add(a, b)
+	limit.Quo(limit, den.Add(den, newFloat(wp, 2)))
+	if x.Cmp(limit) > 0 {
+		res, err := BetaInc(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), x), b, a, wp)
+		if err != nil {
+			return nil, err
+		}
+		return res.Sub(newFloat(wp, 1), res).SetPrec(prec), nil
+	}
+
+	// prefix = x**a * (1-x)**b / (a * B(a, b))
+	lnX, _ := bigLog(x, wp)
+	lnX.Mul(lnX, a)
+	ln1mX, _ := bigLog(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), x), wp)
+	ln1mX.Mul(ln1mX, b)
+	lgA, err := Lgamma(a, wp)
+	if err != nil {
+		return nil, err
+	}
+	lgB, err := Lgamma(b, wp)
+	if err != nil {
+		return nil, err
+	}
+	lgAB, err := Lgamma(new(big.Float).SetPrec(wp).Add(a, b), wp)
+	if err != nil {
+		return nil, err
+	}
+	exp := lnX.Add(lnX, ln1mX)
+	exp.Sub(exp, lgA)
+	exp.Sub(exp, lgB)
+	exp.Add(exp, lgAB)
+	prefix := bigExp(exp, wp)
+	prefix.Quo(prefix, a)
+
+	// 1 / (1 + d1 / (1 + d2 / (1 + ...))), with
+	// d_2m = m(b-m)x / ((a+2m-1)(a+2m))
+	// d_2m+1 = -(a+m)(a+b+m)x / ((a+2m)(a+2m+1))
+	frac, err := lentz(newFloat(wp, 1), func(n int64) (*big.Float, *big.Float) {
+		m := n / 2
+		d := new(big.Float).SetPrec(wp)
+		if n%2 == 0 {
+			d.Sub(b, newFloat(wp, m))
+			d.Mul(d, newFloat(wp, m))
+			d.Mul(d, x)
+			den := new(big.Float).SetPrec(wp).Add(a, newFloat(wp, 2*m-1))
+			den.Mul(den, new(big.Float).SetPrec(wp).Add(a, newFloat(wp, 2*m)))
+			d.Quo(d, den)
+		} else {
+			d.Add(a, newFloat(wp, m))
+			ab := new(big.Float).SetPrec(wp).Add(a, b)
+			d.Mul(d, ab.Add(ab, newFloat(wp, m)))
+			d.Mul(d, x)
+			d.Neg(d)
+			den := new(big.Float).SetPrec(wp).Add(a, newFloat(wp, 2*m))
+			den.Mul(den, new(big.Float).SetPrec(wp).Add(a, newFloat(wp, 2*m+1)))
+			d.Quo(d, den)
+		}
+		return d, newFloat(wp, 1)
+	}, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return prefix.Quo(prefix, frac).SetPrec(prec), nil
+}
+
+// Erf calculates the error function of x
+func Erf(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() == 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// erf(x) = sign(x) * P(1/2, x**2)
+	wp := prec + guardBits
+	xSq := new(big.Float).SetPrec(wp).Mul(x, x)
+	res, err := GammaInc(big.NewFloat(0.5), xSq, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	if x.Sign() < 0 {
+		res.Neg(res)
+	}
+
+	return res.SetPrec(prec), nil
+}
+
+// Erfc calculates the complementary error function 1 - erf(x)
+func Erfc(x *big.Float, prec uint) (*big.Float, error) {
+	if x.Sign() <= 0 {
+		res, err := Erf(x, prec+guardBits)
+		if err != nil {
+			return nil, err
+		}
+		return res.Sub(newFloat(prec+guardBits, 1), res).SetPrec(prec), nil
+	}
+
+	// erfc(x) = Q(1/2, x**2) for positive x, without cancellation
+	wp := prec + guardBits
+	xSq := new(big.Float).SetPrec(wp).Mul(x, x)
+	res, err := GammaIncComp(big.NewFloat(0.5), xSq, wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.SetPrec(prec), nil
+}
+
+// Erfinv calculates the inverse error function of -1 < y < 1
+func Erfinv(y *big.Float, prec uint) (*big.Float, error) {
+	one := newFloat(prec, 1)
+	if new(big.Float).Abs(y).Cmp(one) >= 0 {
+		return nil, ErrDomain
+	}
+
+	if y.Sign() == 0 {
+		return newFloat(prec, 0), nil
+	}
+
+	// erfinv(y) = erfcinv(1 - y)
+	wp := prec + guardBits
+	res, err := Erfcinv(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), y), wp)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.SetPrec(prec), nil
+}
+
+// Erfcinv calculates the inverse complementary error function of 0 < y < 2
+func Erfcinv(y *big.Float, prec uint) (*big.Float, error) {
+	if y.Sign() <= 0 || y.Cmp(newFloat(prec, 2)) >= 0 {
+		return nil, ErrDomain
+	}
+
+	wp := prec + guardBits
+
+	switch y.Cmp(newFloat(wp, 1)) {
+	case 0:
+		return newFloat(prec, 0), nil
+	case 1:
+		// erfcinv(y) = -erfcinv(2 - y)
+		res, err := Erfcinv(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 2), y), prec)
+		if err != nil {
+			return nil, err
+		}
+		return res.Neg(res), nil
+	}
+
+	// Initial guess from float64, or the asymptotic expansion if y is too
+	// small for a float64
+	yf, _ := y.Float64()
+	guess := math.Erfcinv(yf)
+	if math.IsInf(guess, 0) || math.IsNaN(guess) {
+		ln, _ := bigLog(y, 64)
+		lnf, _ := ln.Float64()
+		guess = math.Sqrt(-lnf)
+	}
+	x := new(big.Float).SetPrec(wp).SetFloat64(guess)
+
+	// Solve erfc(x) = y with Newton's method, using
+	// d/dx erfc(x) = -2/sqrt(pi) * e**(-x**2)
+	twoOverSqrtPi := bigPi(wp)
+	twoOverSqrtPi.Sqrt(twoOverSqrtPi)
+	twoOverSqrtPi.Quo(newFloat(wp, 2), twoOverSqrtPi)
+
+	for i := 0; i < maxIterations; i++ {
+		erfc, err := Erfc(x, wp)
+		if err != nil {
+			return nil, err
+		}
+
+		xSq := new(big.Float).SetPrec(wp).Mul(x, x)
+		deriv := bigExp(xSq.Neg(xSq), wp)
+		deriv.Mul(deriv, twoOverSqrtPi)
+
+		// x = x + (erfc(x) - y) / deriv
+		step := erfc.Sub(erfc, y)
+		step.Quo(step, deriv)
+		x.Add(x, step)
+
+		if negligible(step, x, prec+8) {
+			return x.SetPrec(prec), nil
+		}
+	}
+
+	return nil, ErrNoConvergence
+}
+
+// Zeta calculates the Riemann zeta function of s
+func Zeta(s *big.Float, prec uint) (*big.Float, error) {
+	one := newFloat(prec, 1)
+	if s.Cmp(one) == 0 {
+		return nil, ErrPole
+	}
+
+	if s.Sign() == 0 {
+		return big.NewFloat(-0.5).SetPrec(prec), nil
+	}
+
+	wp := prec + guardBits
+
+	if s.Sign() < 0 {
+		// Trivial zeros at negative even integers
+		half := new(big.Float).SetPrec(wp).SetMantExp(s, -1)
+		if half.IsInt() {
+			return newFloat(prec, 0), nil
+		}
+
+		// zeta(s) = 2**s * pi**(s-1) * sin(pi*s/2) * gamma(1-s) * zeta(1-s)
+		oneMinusS := new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), s)
+		pi := bigPi(wp)
+		res, err := bigPow(newFloat(wp, 2), s, wp)
+		if err != nil {
+			return nil, err
+		}
+		piPow, err := bigPow(pi, new(big.Float).SetPrec(wp).Neg(oneMinusS), wp)
+		if err != nil {
+			return nil, err
+		}
+		sin, _ := bigSinCos(half.Mul(half, pi), wp)
+		g, err := Gamma(oneMinusS, wp)
+		if err != nil {
+			return nil, err
+		}
+		z, err := Zeta(oneMinusS, wp)
+		if err != nil {
+			return nil, err
+		}
+		res.Mul(res, piPow)
+		res.Mul(res, sin)
+		res.Mul(res, g)
+		return res.Mul(res, z).SetPrec(prec), nil
+	}
+
+	// 1 - 2**(1-s) gets small close to s = 1, losing bits to cancellation
+	factor, err := bigPow(newFloat(wp, 2), new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), s), wp)
+	if err != nil {
+		return nil, err
+	}
+	factor.Sub(newFloat(wp, 1), factor)
+	if exp := exponent(factor); exp < 0 {
+		wp += uint(-exp)
+		factor, _ = bigPow(newFloat(wp, 2), new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), s), wp)
+		factor.Sub(newFloat(wp, 1), factor)
+	}
+
+	// Borwein's algorithm, with
+	// d_k = n * sum_(i=0..k) (n+i-1)! * 4**i / ((n-i)! * (2i)!)
+	n := int64(float64(wp)*0.4) + 2
+	d := make([]*big.Int, n+1)
+	term := new(big.Rat).SetFrac(big.NewInt(1), big.NewInt(n)) // (n-1)!/n! for i = 0
+	sum := new(big.Rat)
+	for i := int64(0); i <= n; i++ {
+		if i > 0 {
+			// term_i = term_(i-1) * (n+i-1) * 4 * (n-i+1) / ((2i-1) * 2i)
+			term.Mul(term, big.NewRat(4*(n+i-1)*(n-i+1), (2*i-1)*(2*i)))
+		}
+		sum.Add(sum, term)
+		d[i] = new(big.Int).Set(new(big.Rat).Mul(sum, big.NewRat(n, 1)).Num())
+	}
+
+	res := newFloat(wp, 0)
+	for k := int64(0); k < n; k++ {
+		power, err := bigPow(newFloat(wp, k+1), s, wp)
+		if err != nil {
+			return nil, err
+		}
+
+		t := new(big.Float).SetPrec(wp).SetInt(new(big.Int).Sub(d[k], d[n]))
+		t.Quo(t, power)
+		if k%2 == 0 {
+			res.Add(res, t)
+		} else {
+			res.Sub(res, t)
+		}
+	}
+
+	dn := new(big.Float).SetPrec(wp).SetInt(d[n])
+	res.Quo(res, dn.Mul(dn, factor))
+
+	return res.Neg(res).SetPrec(prec), nil
+}
+
+// Digamma calculates the digamma function, the logarithmic derivative of
+// gamma
+func Digamma(x *big.Float, prec uint) (*big.Float, error) {
+	if isNonPositiveInt(x) {
+		return nil, ErrPole
+	}
+
+	wp := prec + guardBits
+
+	if x.Sign() < 0 {
+		// psi(x) = psi(1 - x) - pi * cot(pi * x)
+		res, err := Digamma(new(big.Float).SetPrec(wp).Sub(newFloat(wp, 1), x), wp)
+		if err != nil {
+			return nil, err
+		}
+		pi := bigPi(wp)
+		sin, cos := bigSinCos(new(big.Float).SetPrec(wp).Mul(pi, x), wp)
+		cot := cos.Quo(cos, sin)
+		return res.Sub(res, cot.Mul(cot, pi)).SetPrec(prec), nil
+	}
+
+	// psi(x) = psi(x + n) - sum 1 / (x + i)
+	shift := stirlingShift(x, wp)
+	y := new(big.Float).SetPrec(wp).Add(x, newFloat(wp, shift))
+	res, _ := bigLog(y, wp)
+
+	// psi(y) = ln(y) - 1/(2y) - sum B_2k / (2k * y**2k)
+	half := newFloat(wp, 1)
+	half.Quo(half, y)
+	res.Sub(res, half.SetMantExp(half, -1))
+
+	ySq := new(big.Float).SetPrec(wp).Mul(y, y)
+	power := new(big.Float).SetPrec(wp).Set(ySq)
+	for k := 1; ; k++ {
+		b := bernoulli(k)[k]
+		term := ratFloat(b, wp)
+		term.Quo(term, newFloat(wp, int64(2*k)))
+		term.Quo(term, power)
+		if negligible(term, res, wp) {
+			break
+		}
+		res.Sub(res, term)
+		power.Mul(power, ySq)
+	}
+
+	for i := int64(0); i < shift; i++ {
+		inv := new(big.Float).SetPrec(wp).Add(x, newFloat(wp, i))
+		res.Sub(res, inv.Quo(newFloat(wp, 1), inv))
+	}
+
+	return res.SetPrec(prec), nil
+}
+
+// BesselJ calculates the Bessel function of the first kind J_n(x) for n >= 0
+func BesselJ(n int64, x *big.Float, prec uint) (*big.Float, error) {
+	// The terms of the power series grow up to about e**|x| before the series
+	// converges, so calculate with enough extra bits to cancel them out
+	xf, _ := x.Float64()
+	extra := math.Abs(xf) * math.Log2E
+	if extra > maxBitWidth {
+		return nil, ErrArgTooLarge
+	}
+	wp := prec + guardBits + uint(extra)
+
+	// J_n(x) = sum (-1)**k * (x/2)**(2k+n) / (k! * (k+n)!)
+	halfX := new(big.Float).SetPrec(wp).SetMantExp(x, -1)
+	quarterXSq := new(big.Float).SetPrec(wp).Mul(halfX, halfX)
+
+	term := bigPowInt(halfX, n, wp)
+	fact, _ := factorialInt(big.NewInt(n))
+	term.Quo(term, new(big.Float).SetPrec(wp).SetInt(fact))
+
+	sum := new(big.Float).SetPrec(wp).Set(term)
+	for k := int64(1); ; k++ {
+		term.Mul(term, quarterXSq)
+		term.Quo(term, newFloat(wp, -k*(k+n)))
+		// Terms only start to shrink once k > |x|/2
+		if float64(k) > math.Abs(xf)/2 && negligible(term, sum, wp) {
+			break
+		}
+		sum.Add(sum, term)
+	}
+
+	return sum.SetPrec(prec), nil
+}
+
+// factorialInt calculates n! exactly for a non-negative integer n
+func factorialInt(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, ErrNegativeInput
+	}
+
+	f := intFloat(n)
+	if err := checkBits(f * math.Log2(f+1)); err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).MulRange(1, n.Int64()), nil
+}
@@ -70,9 +70,12 @@ const (
 	LtEq  // <=
 	operatorsEnd
 
-	Lparen // (
-	Rparen // )
-	Comma  // ,
+	Lparen    // (
+	Rparen    // )
+	Comma     // ,
+	Lbracket  // [
+	Rbracket  // ]
+	Semicolon // ;
 )
 
 var tokens = map[TokenType]string{
@@ -120,9 +123,12 @@ var tokens = map[TokenType]string{
 	Lt:    "<",
 	LtEq:  "<=",
 
-	Lparen: "(",
-	Rparen: ")",
-	Comma:  ",",
+	Lparen:    "(",
+	Rparen:    ")",
+	Comma:     ",",
+	Lbracket:  "[",
+	Rbracket:  "]",
+	Semicolon: ";",
 }
 
 func (tok Token) String() string {
@@ -0,0 +1,408 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ToSQL translates a parsed expression to a PostgreSQL expression. Variables
+// become quoted column names, divisions are done on numeric and comparisons
+// give 1 or 0. Bitwise operators can't be translated, as PostgreSQL only has
+// them for 64-bit integers.
+//
+// Example:
+//     tree, err := mathcat.Parse("price * 1.21 % 5")
+//     sql, err := mathcat.ToSQL(tree) // "price" * 1.21 - 5 * floor(("price" * 1.21)::numeric / 5)
+func ToSQL(n Node) (string, error) {
+	return sqlLang.translate(n)
+}
+
+// ToJavaScript translates a parsed expression to a JavaScript expression
+// using Math. Bitwise operators can't be translated, as JavaScript only has
+// them for 32-bit integers, and neither can integers JavaScript numbers can't
+// hold exactly.
+func ToJavaScript(n Node) (string, error) {
+	return jsLang.translate(n)
+}
+
+// ToPython translates a parsed expression to a Python 3 expression, which
+// needs the math module to be imported.
+func ToPython(n Node) (string, error) {
+	return pythonLang.translate(n)
+}
+
+// langOp is an operator in a language, with its precedence there
+type langOp struct {
+	text       string
+	prec       int
+	rightAssoc bool
+}
+
+// language describes how expressions are written in another language.
+// Constructs that behave differently are rewritten into expressions that
+// translate faithfully, like floored remainders.
+type language struct {
+	name   string
+	ops    map[TokenType]langOp
+	unary  int // precedence of unary operators
+	funcs  map[string]string
+	consts map[string]string
+
+	// minimal precedence of operands of unary operators
+	unaryOperand int
+	// if % has the sign of the divisor, like in expressions
+	flooredRem bool
+	// if integer division truncates, so the dividend is made numeric
+	numericDiv bool
+	// comparison turns a comparison into a number, or is nil if it's one
+	comparison func(code string) string
+	// ident writes a variable
+	ident func(name string) (string, error)
+	// maxInt is the largest integer that can be written exactly, or nil
+	maxInt *big.Int
+}
+
+// Precedences shared by the languages. Languages differ in how powers bind
+// compared to unary operators.
+const (
+	langComparison = iota
+	langOr
+	langXor
+	langAnd
+	langShift
+	langAdd
+	langMul
+)
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true,
+	"class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true,
+	"global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true, "with": true,
+	"yield": true, "math": true,
+}
+
+var pythonLang = &language{
+	name: "Python",
+	ops: map[TokenType]langOp{
+		Add: {"+", langAdd, false}, Sub: {"-", langAdd, false},
+		Mul: {"*", langMul, false}, Div: {"/", langMul, false},
+		Rem: {"%", langMul, false}, Pow: {"**", langMul + 2, true},
+		UnaryMin: {"-", langMul + 1, false}, Not: {"~", langMul + 1, false},
+		And: {"&", langAnd, false}, Or: {"|", langOr, false},
+		Xor: {"^", langXor, false}, Lsh: {"<<", langShift, false},
+		Rsh:  {">>", langShift, false},
+		EqEq: {"==", langComparison, false}, NotEq: {"!=", langComparison, false},
+		Gt: {">", langComparison, false}, GtEq: {">=", langComparison, false},
+		Lt: {"<", langComparison, false}, LtEq: {"<=", langComparison, false},
+	},
+	unary:        langMul + 1,
+	unaryOperand: langMul + 2,
+	flooredRem:   true,
+	funcs: map[string]string{
+		"abs": "abs", "ceil": "math.ceil", "floor": "math.floor",
+		"sin": "math.sin", "cos": "math.cos", "tan": "math.tan",
+		"asin": "math.asin", "acos": "math.acos", "atan": "math.atan",
+		"atan2": "math.atan2", "hypot": "math.hypot", "deg": "math.degrees",
+		"rad": "math.radians", "sinh": "math.sinh", "cosh": "math.cosh",
+		"tanh": "math.tanh", "asinh": "math.asinh", "acosh": "math.acosh",
+		"atanh": "math.atanh", "ln": "math.log", "log": "math.log10",
+		"max": "max", "min": "min", "sqrt": "math.sqrt",
+		"gamma": "math.gamma", "lgamma": "math.lgamma", "erf": "math.erf",
+		"erfc": "math.erfc", "fact": "math.factorial", "gcd": "math.gcd",
+		"lcm": "math.lcm", "isqrt": "math.isqrt", "ncr": "math.comb",
+		"binomial": "math.comb", "npr": "math.perm",
+	},
+	consts: map[string]string{"pi": "math.pi", "e": "math.e", "tau": "math.tau"},
+	ident: func(name string) (string, error) {
+		if pythonKeywords[name] {
+			return "", fmt.Errorf("Can't use ‘%s’ as variable in Python", name)
+		}
+		return name, nil
+	},
+}
+
+var jsKeywords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true,
+	"do": true, "else": true, "export": true, "extends": true, "finally": true,
+	"for": true, "function": true, "if": true, "import": true, "in": true,
+	"instanceof": true, "let": true, "new": true, "return": true,
+	"super": true, "switch": true, "this": true, "throw": true, "try": true,
+	"typeof": true, "var": true, "void": true, "while": true, "with": true,
+	"yield": true, "await": true, "enum": true, "null": true,
+	"undefined": true, "NaN": true, "Infinity": true, "Math": true,
+	"Number": true,
+}
+
+var jsLang = &language{
+	name: "JavaScript",
+	ops: map[TokenType]langOp{
+		Add: {"+", langAdd, false}, Sub: {"-", langAdd, false},
+		Mul: {"*", langMul, false}, Div: {"/", langMul, false},
+		Pow: {"**", langMul + 2, true}, UnaryMin: {"-", langMul + 1, false},
+		EqEq: {"===", langComparison, false}, NotEq: {"!==", langComparison, false},
+		Gt: {">", langComparison, false}, GtEq: {">=", langComparison, false},
+		Lt: {"<", langComparison, false}, LtEq: {"<=", langComparison, false},
+	},
+	unary: langMul + 1,
+	// -x ** 2 is a syntax error
+	unaryOperand: atomPrec,
+	funcs: map[string]string{
+		"abs": "Math.abs", "ceil": "Math.ceil", "floor": "Math.floor",
+		"sin": "Math.sin", "cos": "Math.cos", "tan": "Math.tan",
+		"asin": "Math.asin", "acos": "Math.acos", "atan": "Math.atan",
+		"atan2": "Math.atan2", "hypot": "Math.hypot", "sinh": "Math.sinh",
+		"cosh": "Math.cosh", "tanh": "Math.tanh", "asinh": "Math.asinh",
+		"acosh": "Math.acosh", "atanh": "Math.atanh", "ln": "Math.log",
+		"log": "Math.log10", "max": "Math.max", "min": "Math.min",
+		"sqrt": "Math.sqrt",
+	},
+	consts:     map[string]string{"pi": "Math.PI", "e": "Math.E"},
+	comparison: func(code string) string { return "Number(" + code + ")" },
+	ident: func(name string) (string, error) {
+		if jsKeywords[name] {
+			return "", fmt.Errorf("Can't use ‘%s’ as variable in JavaScript", name)
+		}
+		return name, nil
+	},
+	// Number.MAX_SAFE_INTEGER
+	maxInt: big.NewInt(1<<53 - 1),
+}
+
+var sqlLang = &language{
+	name: "SQL",
+	ops: map[TokenType]langOp{
+		Add: {"+", langAdd, false}, Sub: {"-", langAdd, false},
+		Mul: {"*", langMul, false}, Div: {"/", langMul, false},
+		Pow: {"^", langMul + 1, false}, UnaryMin: {"-", langMul + 2, false},
+		EqEq: {"=", langComparison, false}, NotEq: {"<>", langComparison, false},
+		Gt: {">", langComparison, false}, GtEq: {">=", langComparison, false},
+		Lt: {"<", langComparison, false}, LtEq: {"<=", langComparison, false},
+	},
+	// Unary minus binds tighter than ^, like in expressions
+	unary:        langMul + 2,
+	unaryOperand: atomPrec,
+	funcs: map[string]string{
+		"abs": "abs", "ceil": "ceil", "floor": "floor", "sin": "sin",
+		"cos": "cos", "tan": "tan", "cot": "cot", "asin": "asin",
+		"acos": "acos", "atan": "atan", "atan2": "atan2", "deg": "degrees",
+		"rad": "radians", "sinh": "sinh", "cosh": "cosh", "tanh": "tanh",
+		"asinh": "asinh", "acosh": "acosh", "atanh": "atanh", "ln": "ln",
+		"log": "log", "max": "greatest", "min": "least", "sqrt": "sqrt",
+		"gcd": "gcd", "lcm": "lcm", "fact": "factorial",
+	},
+	consts:     map[string]string{"pi": "pi()", "e": "exp(1)"},
+	comparison: func(code string) string { return "(" + code + ")::int" },
+	numericDiv: true,
+	ident: func(name string) (string, error) {
+		return `"` + name + `"`, nil
+	},
+}
+
+// langConsts are constants written as expressions where a language lacks them
+var langConsts = map[string]func(pos int) Node{
+	"tau": func(pos int) Node {
+		return binary(Mul, intNode(2, pos), identNode("pi", pos))
+	},
+	"phi": func(pos int) Node {
+		return binary(Div, binary(Add, intNode(1, pos), callNode("sqrt", pos, intNode(5, pos))), intNode(2, pos))
+	},
+}
+
+// langRewrites rewrite functions a language doesn't have into ones it has
+var langRewrites = map[string]func(args []Node) Node{
+	"sec": func(args []Node) Node {
+		return binary(Div, intNode(1, args[0].Pos()), callNode("cos", args[0].Pos(), args[0]))
+	},
+	"csc": func(args []Node) Node {
+		return binary(Div, intNode(1, args[0].Pos()), callNode("sin", args[0].Pos(), args[0]))
+	},
+	"cot": func(args []Node) Node {
+		return binary(Div, intNode(1, args[0].Pos()), callNode("tan", args[0].Pos(), args[0]))
+	},
+	"logn": func(args []Node) Node {
+		return binary(Div, callNode("ln", args[1].Pos(), args[1]), callNode("ln", args[0].Pos(), args[0]))
+	},
+	"deg": func(args []Node) Node {
+		return binary(Div, binary(Mul, args[0], intNode(180, args[0].Pos())), identNode("pi", args[0].Pos()))
+	},
+	"rad": func(args []Node) Node {
+		return binary(Div, binary(Mul, args[0], identNode("pi", args[0].Pos())), intNode(180, args[0].Pos()))
+	},
+	"hypot": func(args []Node) Node {
+		two := intNode(2, args[0].Pos())
+		return callNode("sqrt", args[0].Pos(), binary(Add, binary(Pow, args[0], two), binary(Pow, args[1], two)))
+	},
+}
+
+func (l *language) translate(n Node) (string, error) {
+	code, _, err := l.write(n)
+	return code, err
+}
+
+// operand writes n, between parentheses if it binds looser than minPrec
+func (l *language) operand(n Node, minPrec int) (string, error) {
+	code, prec, err := l.write(n)
+	if err != nil {
+		return "", err
+	}
+	if prec < minPrec {
+		return "(" + code + ")", nil
+	}
+	return code, nil
+}
+
+// write translates n, returning the code and its precedence
+func (l *language) write(n Node) (string, int, error) {
+	switch n := n.(type) {
+	case *NumberNode:
+		return l.number(n.Value)
+	case *IdentNode:
+		if val, ok := defaultVariables[n.Name()]; ok {
+			if c, ok := l.consts[n.Name()]; ok {
+				return c, atomPrec, nil
+			}
+			if c, ok := langConsts[n.Name()]; ok {
+				return l.write(c(n.Pos()))
+			}
+			return l.number(val)
+		}
+		code, err := l.ident(n.Name())
+		return code, atomPrec, err
+	case *UnaryNode:
+		op, ok := l.ops[n.Op.Type]
+		if !ok {
+			return "", 0, fmt.Errorf("Can't translate ‘%s’ to %s faithfully", n.Op, l.name)
+		}
+		x, err := l.operand(n.X, l.unaryOperand)
+		return op.text + x, l.unary, err
+	case *BinaryNode:
+		return l.binary(n)
+	case *CallNode:
+		return l.call(n)
+	}
+
+	return "", 0, fmt.Errorf("Can't translate ‘%s’ to %s", n, l.name)
+}
+
+// number writes a number exactly, as decimal or as division of integers
+func (l *language) number(val *big.Rat) (string, int, error) {
+	if val.Sign() < 0 {
+		code, prec, err := l.number(new(big.Rat).Neg(val))
+		if prec < l.unaryOperand {
+			code = "(" + code + ")"
+		}
+		return l.ops[UnaryMin].text + code, l.unary, err
+	}
+
+	if l.maxInt != nil && new(big.Int).Quo(val.Num(), val.Denom()).Cmp(l.maxInt) > 0 {
+		return "", 0, fmt.Errorf("Can't translate %s to %s exactly", val.RatString(), l.name)
+	}
+
+	text := numNode(val, 0).Tok.Value
+	if i := strings.Index(text, "/"); i >= 0 {
+		return l.division(text[:i], text[i+1:]), langMul, nil
+	}
+
+	return text, atomPrec, nil
+}
+
+// division writes lhs/rhs
+func (l *language) division(lhs, rhs string) string {
+	if l.numericDiv {
+		return lhs + "::numeric / " + rhs
+	}
+	return lhs + " / " + rhs
+}
+
+func (l *language) binary(n *BinaryNode) (string, int, error) {
+	switch {
+	case n.Op.IsAssignment():
+		return "", 0, fmt.Errorf("Can't translate assignment ‘%s’ to %s", n.Op, l.name)
+	case n.Op.Is(Rem) && !l.flooredRem:
+		// The remainder has the sign of the divisor
+		return l.write(binary(Sub, n.Lhs, binary(Mul, n.Rhs,
+			callNode("floor", n.Pos(), binary(Div, n.Lhs, n.Rhs)))))
+	}
+
+	op, ok := l.ops[n.Op.Type]
+	if !ok {
+		return "", 0, fmt.Errorf("Can't translate ‘%s’ to %s faithfully", n.Op, l.name)
+	}
+
+	lhsPrec, rhsPrec := op.prec, op.prec+1
+	if op.rightAssoc {
+		lhsPrec, rhsPrec = op.prec+1, op.prec
+	}
+	if op.prec == langComparison {
+		// Python chains comparisons, so they're parenthesized
+		lhsPrec, rhsPrec = langComparison+1, langComparison+1
+	}
+
+	// Dividends that aren't numeric yet are cast, which binds tighter than
+	// anything
+	cast := n.Op.Is(Div) && l.numericDiv
+	if lhs, ok := n.Lhs.(*BinaryNode); ok && lhs.Op.Is(Div) {
+		cast = false
+	}
+	if cast {
+		lhsPrec = atomPrec
+	}
+
+	lhs, err := l.operand(n.Lhs, lhsPrec)
+	if err != nil {
+		return "", 0, err
+	}
+	rhs, err := l.operand(n.Rhs, rhsPrec)
+	if err != nil {
+		return "", 0, err
+	}
+
+	code := lhs + " " + op.text + " " + rhs
+	switch {
+	case cast:
+		return l.division(lhs, rhs), op.prec, nil
+	case op.prec == langComparison && l.comparison != nil:
+		return l.comparison(code), atomPrec, nil
+	}
+
+	return code, op.prec, nil
+}
+
+func (l *language) call(n *CallNode) (string, int, error) {
+	function, ok := funcs[n.Func.Value]
+	if !ok {
+		return "", 0, fmt.Errorf("Undefined function ‘%s’", n.Func)
+	}
+	if err := New().checkCall(n.Func, function, len(n.Args)); err != nil {
+		return "", 0, err
+	}
+
+	name, ok := l.funcs[n.Func.Value]
+	if !ok {
+		if rewrite, ok := langRewrites[n.Func.Value]; ok {
+			return l.write(rewrite(n.Args))
+		}
+		return "", 0, fmt.Errorf("Can't translate ‘%s’ to %s", n.Func, l.name)
+	}
+
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		code, _, err := l.write(arg)
+		if err != nil {
+			return "", 0, err
+		}
+		args[i] = code
+	}
+
+	return name + "(" + strings.Join(args, ", ") + ")", atomPrec, nil
+}
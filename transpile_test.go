@@ -0,0 +1,83 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import "testing"
+
+func TestTranspile(t *testing.T) {
+	exprs := []struct {
+		expr, sql, js, python string
+	}{
+		{"price * 1.21 % 5",
+			`"price" * 1.21 - 5 * floor(("price" * 1.21)::numeric / 5)`,
+			"price * 1.21 - 5 * Math.floor(price * 1.21 / 5)",
+			"price * 1.21 % 5"},
+		{"-x**2", `-"x" ^ 2`, "(-x) ** 2", "(-x) ** 2"},
+		{"-(x**2)", `-("x" ^ 2)`, "-(x ** 2)", "-x ** 2"},
+		{"2**3**2", "2 ^ 3 ^ 2", "(2 ** 3) ** 2", "(2 ** 3) ** 2"},
+		{"x/(y*2) / 3", `"x"::numeric / ("y" * 2) / 3`, "x / (y * 2) / 3", "x / (y * 2) / 3"},
+		{"a < b < c", `("a" < ("b" < "c")::int)::int`, "Number(a < Number(b < c))", "a < (b < c)"},
+		{"1/3 - 0.5*x", `1::numeric / 3 - 0.5 * "x"`, "1 / 3 - 0.5 * x", "1 / 3 - 0.5 * x"},
+		{"10 % -3", "10 - -3 * floor(10::numeric / -3)", "10 - -3 * Math.floor(10 / -3)", "10 % -3"},
+		{"max(a, b) != 1", `(greatest("a", "b") <> 1)::int`, "Number(Math.max(a, b) !== 1)", "max(a, b) != 1"},
+		{"sec(x) + logn(2, x)",
+			`1::numeric / cos("x") + ln("x")::numeric / ln(2)`,
+			"1 / Math.cos(x) + Math.log(x) / Math.log(2)",
+			"1 / math.cos(x) + math.log(x) / math.log(2)"},
+		{"tau*r + phi",
+			`2 * pi() * "r" + (1 + sqrt(5))::numeric / 2`,
+			"2 * Math.PI * r + (1 + Math.sqrt(5)) / 2",
+			"math.tau * r + (1 + math.sqrt(5)) / 2"},
+		{"deg(x)", `degrees("x")`, "x * 180 / Math.PI", "math.degrees(x)"},
+	}
+
+	for _, e := range exprs {
+		tree, err := Parse(e.expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing '%s': %s", e.expr, err)
+			continue
+		}
+
+		if res, err := ToSQL(tree); err != nil || res != e.sql {
+			t.Errorf("wrong SQL of '%s' (expected %s, got %s, %v)", e.expr, e.sql, res, err)
+		}
+		if res, err := ToJavaScript(tree); err != nil || res != e.js {
+			t.Errorf("wrong JavaScript of '%s' (expected %s, got %s, %v)", e.expr, e.js, res, err)
+		}
+		if res, err := ToPython(tree); err != nil || res != e.python {
+			t.Errorf("wrong Python of '%s' (expected %s, got %s, %v)", e.expr, e.python, res, err)
+		}
+	}
+}
+
+func TestTranspileErrors(t *testing.T) {
+	errs := []struct {
+		expr      string
+		translate func(Node) (string, error)
+	}{
+		{"5 & 3", ToJavaScript},
+		{"x << 2", ToSQL},
+		{"~x", ToJavaScript},
+		{"2**60 + 1e30", ToJavaScript},
+		{"fact(n)", ToJavaScript},
+		{"erf(x)", ToSQL},
+		{"a = 1", ToPython},
+		{"[1, 2]", ToSQL},
+		{"class + 1", ToPython},
+		{"sin(x, y)", ToSQL},
+	}
+
+	for _, e := range errs {
+		tree, err := Parse(e.expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing '%s': %s", e.expr, err)
+			continue
+		}
+
+		if res, err := e.translate(tree); err == nil {
+			t.Errorf("expected error translating '%s' (got %s)", e.expr, res)
+		}
+	}
+}
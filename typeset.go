@@ -0,0 +1,586 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// greekLetters are the names of greek letters typeset as symbols by default
+var greekLetters = []struct{ name, letter string }{
+	{"alpha", "α"}, {"beta", "β"}, {"gamma", "γ"}, {"delta", "δ"},
+	{"epsilon", "ε"}, {"zeta", "ζ"}, {"eta", "η"}, {"theta", "θ"},
+	{"iota", "ι"}, {"kappa", "κ"}, {"lambda", "λ"}, {"mu", "μ"},
+	{"nu", "ν"}, {"xi", "ξ"}, {"pi", "π"}, {"rho", "ρ"}, {"sigma", "σ"},
+	{"tau", "τ"}, {"upsilon", "υ"}, {"phi", "φ"}, {"chi", "χ"},
+	{"psi", "ψ"}, {"omega", "ω"}, {"Gamma", "Γ"}, {"Delta", "Δ"},
+	{"Theta", "Θ"}, {"Lambda", "Λ"}, {"Xi", "Ξ"}, {"Pi", "Π"},
+	{"Sigma", "Σ"}, {"Upsilon", "Υ"}, {"Phi", "Φ"}, {"Psi", "Ψ"},
+	{"Omega", "Ω"},
+}
+
+// LaTeXSymbols maps variable names to the LaTeX symbols ToLaTeX uses by
+// default, like alpha to \alpha
+var LaTeXSymbols = map[string]string{"inf": `\infty`}
+
+// MathMLSymbols maps variable names to the symbols ToMathML uses by default,
+// like alpha to α
+var MathMLSymbols = map[string]string{"inf": "∞"}
+
+func init() {
+	for _, g := range greekLetters {
+		LaTeXSymbols[g.name] = `\` + g.name
+		MathMLSymbols[g.name] = g.letter
+	}
+}
+
+// typesetPrec returns the precedence of a node when typeset. Fractions are
+// atoms, and powers bind tighter than unary operators as the exponent is
+// raised.
+func typesetPrec(n Node) int {
+	switch n := n.(type) {
+	case *NumberNode:
+		if strings.HasPrefix(n.Tok.Value, "-") {
+			return operators[UnaryMin].prec
+		}
+	case *BinaryNode:
+		switch n.Op.Type {
+		case Div:
+			return atomPrec
+		case Pow:
+			return operators[UnaryMin].prec + 1
+		}
+	}
+
+	return prec(n)
+}
+
+// operandPrecs returns the minimal precedence of the operands of a binary
+// operator that isn't a fraction or power
+func operandPrecs(op *Token) (int, int) {
+	o := operators[op.Type]
+	if o.assoc == AssocRight {
+		return o.prec + 1, o.prec
+	}
+	return o.prec, o.prec + 1
+}
+
+// isBase reports if a node can be raised to a power without parentheses
+func isBase(n Node) bool {
+	switch n := n.(type) {
+	case *NumberNode:
+		return !strings.ContainsAny(n.Tok.Value, "-/eE") || strings.HasPrefix(n.Tok.Value, "0x")
+	case *IdentNode, *CallNode, *MatrixNode:
+		return true
+	}
+
+	return false
+}
+
+// isJuxtaposed reports if a product is typeset without a multiplication sign,
+// like 2x
+func isJuxtaposed(n *BinaryNode) bool {
+	if !n.Op.Is(Mul) || !isBase(n.Lhs) {
+		return false
+	}
+	if _, ok := n.Lhs.(*NumberNode); !ok {
+		return false
+	}
+
+	switch rhs := n.Rhs.(type) {
+	case *IdentNode, *CallNode:
+		return true
+	case *BinaryNode:
+		_, ok := rhs.Lhs.(*IdentNode)
+		return rhs.Op.Is(Pow) && ok
+	}
+
+	return false
+}
+
+// splitNumber splits a number in its sign, numerator, denominator and power of
+// ten, like -3/4 or 24e3
+func splitNumber(value string) (neg bool, num, den, exp string) {
+	if strings.HasPrefix(value, "-") {
+		neg, value = true, value[1:]
+	}
+	if i := strings.Index(value, "/"); i >= 0 {
+		return neg, value[:i], value[i+1:], ""
+	}
+	if i := strings.IndexAny(value, "eE"); i >= 0 && !strings.HasPrefix(value, "0x") {
+		return neg, value[:i], "", strings.TrimPrefix(value[i+1:], "+")
+	}
+
+	return neg, value, "", ""
+}
+
+// splitIdent splits a name like x_1 in its base and subscript
+func splitIdent(name string) (string, string) {
+	if i := strings.Index(name, "_"); i > 0 && i < len(name)-1 {
+		return name[:i], name[i+1:]
+	}
+
+	return name, ""
+}
+
+// ToLaTeX typesets a parsed expression in LaTeX, with variables named in
+// symbols replaced by their symbol. With nil symbols, LaTeXSymbols is used.
+//
+// Example:
+//     tree, err := mathcat.Parse("sqrt(alpha**2 + 1)/2")
+//     fmt.Println(mathcat.ToLaTeX(tree, nil)) // \frac{\sqrt{\alpha^{2} + 1}}{2}
+func ToLaTeX(n Node, symbols map[string]string) string {
+	if symbols == nil {
+		symbols = LaTeXSymbols
+	}
+
+	l := &latexWriter{symbols: symbols}
+	l.write(n, 0)
+	return l.buf.String()
+}
+
+// latexOps are the LaTeX symbols of operators
+var latexOps = map[TokenType]string{
+	Add: "+", Sub: "-", Mul: `\cdot`, Rem: `\bmod`, And: `\mathbin{\&}`,
+	Or: `\mathbin{|}`, Xor: `\oplus`, Lsh: `\ll`, Rsh: `\gg`, Not: `\lnot `,
+	UnaryMin: "-", Eq: "=", EqEq: "=", NotEq: `\neq`, Gt: ">", GtEq: `\geq`,
+	Lt: "<", LtEq: `\leq`,
+}
+
+// latexFuncs are the functions with a LaTeX command of their own
+var latexFuncs = map[string]string{
+	"sin": `\sin`, "cos": `\cos`, "tan": `\tan`, "sec": `\sec`, "csc": `\csc`,
+	"cot": `\cot`, "asin": `\arcsin`, "acos": `\arccos`, "atan": `\arctan`,
+	"sinh": `\sinh`, "cosh": `\cosh`, "tanh": `\tanh`, "ln": `\ln`,
+	"log": `\log`, "max": `\max`, "min": `\min`, "gcd": `\gcd`, "det": `\det`,
+}
+
+// texEscaper escapes characters with a special meaning in LaTeX
+var texEscaper = strings.NewReplacer("_", `\_`, "&", `\&`, "%", `\%`, "^", `\^{}`)
+
+type latexWriter struct {
+	buf     bytes.Buffer
+	symbols map[string]string
+}
+
+func (l *latexWriter) write(n Node, minPrec int) {
+	parens := typesetPrec(n) < minPrec
+	if parens {
+		l.buf.WriteString(`\left(`)
+	}
+
+	switch n := n.(type) {
+	case *NumberNode:
+		l.number(n.Tok.Value)
+	case *IdentNode:
+		l.ident(n.Name())
+	case *UnaryNode:
+		l.buf.WriteString(latexOps[n.Op.Type])
+		l.write(n.X, typesetPrec(n)+1)
+	case *BinaryNode:
+		l.binary(n)
+	case *CallNode:
+		l.call(n)
+	case *MatrixNode:
+		l.buf.WriteString(`\begin{bmatrix}`)
+		row := 0
+		for i, elem := range n.Elems {
+			switch {
+			case row < len(n.Breaks) && n.Breaks[row] == i:
+				l.buf.WriteString(` \\ `)
+				row++
+			case i > 0:
+				l.buf.WriteString(" & ")
+			}
+			l.write(elem, 0)
+		}
+		l.buf.WriteString(`\end{bmatrix}`)
+	}
+
+	if parens {
+		l.buf.WriteString(`\right)`)
+	}
+}
+
+func (l *latexWriter) number(value string) {
+	neg, num, den, exp := splitNumber(value)
+	if neg {
+		l.buf.WriteByte('-')
+	}
+
+	switch {
+	case den != "":
+		l.buf.WriteString(`\frac{` + num + "}{" + den + "}")
+	case exp != "":
+		l.buf.WriteString(num + ` \cdot 10^{` + exp + "}")
+	default:
+		l.buf.WriteString(num)
+	}
+}
+
+func (l *latexWriter) ident(name string) {
+	if sym, ok := l.symbols[name]; ok {
+		l.buf.WriteString(sym)
+		return
+	}
+
+	if base, sub := splitIdent(name); sub != "" {
+		l.ident(base)
+		l.buf.WriteString("_{")
+		l.ident(sub)
+		l.buf.WriteByte('}')
+		return
+	}
+
+	if len([]rune(name)) > 1 {
+		l.buf.WriteString(`\mathit{` + texEscaper.Replace(name) + "}")
+		return
+	}
+	l.buf.WriteString(name)
+}
+
+func (l *latexWriter) binary(n *BinaryNode) {
+	switch n.Op.Type {
+	case Div:
+		l.buf.WriteString(`\frac{`)
+		l.write(n.Lhs, 0)
+		l.buf.WriteString("}{")
+		l.write(n.Rhs, 0)
+		l.buf.WriteByte('}')
+		return
+	case Pow:
+		l.base(n.Lhs)
+		l.buf.WriteString("^{")
+		l.write(n.Rhs, 0)
+		l.buf.WriteByte('}')
+		return
+	}
+
+	lhsPrec, rhsPrec := operandPrecs(n.Op)
+	if isNeg(n.Rhs) {
+		// Negative operands on the right are put between parentheses, as
+		// 2 - (-x)
+		rhsPrec = atomPrec
+	}
+
+	l.write(n.Lhs, lhsPrec)
+	switch op, ok := latexOps[n.Op.Type]; {
+	case isJuxtaposed(n):
+	case ok:
+		l.buf.WriteString(" " + op + " ")
+	default:
+		// Compound assignments are written as they are
+		l.buf.WriteString(` \mathrel{` + texEscaper.Replace(n.Op.Value) + "} ")
+	}
+	l.write(n.Rhs, rhsPrec)
+}
+
+func (l *latexWriter) call(n *CallNode) {
+	name := n.Func.Value
+	if len(n.Args) == 1 {
+		arg := n.Args[0]
+		switch name {
+		case "sqrt":
+			l.buf.WriteString(`\sqrt{`)
+			l.write(arg, 0)
+			l.buf.WriteByte('}')
+			return
+		case "abs":
+			l.delimit(`\left|`, arg, `\right|`)
+			return
+		case "floor":
+			l.delimit(`\left\lfloor `, arg, ` \right\rfloor`)
+			return
+		case "ceil":
+			l.delimit(`\left\lceil `, arg, ` \right\rceil`)
+			return
+		case "fact":
+			l.base(arg)
+			l.buf.WriteByte('!')
+			return
+		}
+	}
+
+	switch {
+	case name == "logn" && len(n.Args) == 2:
+		l.buf.WriteString(`\log_{`)
+		l.write(n.Args[0], 0)
+		l.buf.WriteString(`}\left(`)
+		l.write(n.Args[1], 0)
+		l.buf.WriteString(`\right)`)
+		return
+	case latexFuncs[name] != "":
+		l.buf.WriteString(latexFuncs[name])
+	default:
+		l.buf.WriteString(`\operatorname{` + texEscaper.Replace(name) + "}")
+	}
+
+	l.buf.WriteString(`\left(`)
+	for i, arg := range n.Args {
+		if i > 0 {
+			l.buf.WriteString(", ")
+		}
+		l.write(arg, 0)
+	}
+	l.buf.WriteString(`\right)`)
+}
+
+// base writes a node raised to a power or followed by !, between parentheses
+// unless it's a base
+func (l *latexWriter) base(n Node) {
+	if isBase(n) {
+		l.write(n, 0)
+	} else {
+		l.write(n, atomPrec+1)
+	}
+}
+
+func (l *latexWriter) delimit(left string, n Node, right string) {
+	l.buf.WriteString(left)
+	l.write(n, 0)
+	l.buf.WriteString(right)
+}
+
+// ToMathML typesets a parsed expression in presentation MathML, with
+// variables named in symbols replaced by their symbol. With nil symbols,
+// MathMLSymbols is used.
+//
+// Example:
+//     tree, err := mathcat.Parse("x**2/2")
+//     fmt.Println(mathcat.ToMathML(tree, nil))
+func ToMathML(n Node, symbols map[string]string) string {
+	if symbols == nil {
+		symbols = MathMLSymbols
+	}
+
+	m := &mathmlWriter{symbols: symbols}
+	m.buf.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	m.write(n, 0)
+	m.buf.WriteString("</math>")
+	return m.buf.String()
+}
+
+// mathmlOps are the MathML symbols of operators
+var mathmlOps = map[TokenType]string{
+	Add: "+", Sub: "−", Mul: "⋅", Rem: "mod", And: "&", Or: "|", Xor: "⊕",
+	Lsh: "≪", Rsh: "≫", Not: "¬", UnaryMin: "−", Eq: "=", EqEq: "=",
+	NotEq: "≠", Gt: ">", GtEq: "≥", Lt: "<", LtEq: "≤",
+}
+
+// mathmlFuncs are the functions with a conventional name of their own
+var mathmlFuncs = map[string]string{
+	"asin": "arcsin", "acos": "arccos", "atan": "arctan",
+}
+
+// mathmlWriter writes every node as a single MathML element, so it can be
+// used as argument of elements like mfrac
+type mathmlWriter struct {
+	buf     bytes.Buffer
+	symbols map[string]string
+}
+
+func (m *mathmlWriter) elem(tag, text string) {
+	m.buf.WriteString("<" + tag + ">" + html.EscapeString(text) + "</" + tag + ">")
+}
+
+func (m *mathmlWriter) write(n Node, minPrec int) {
+	parens := typesetPrec(n) < minPrec
+	if parens {
+		m.buf.WriteString("<mrow><mo>(</mo>")
+	}
+
+	switch n := n.(type) {
+	case *NumberNode:
+		m.number(n.Tok.Value)
+	case *IdentNode:
+		m.ident(n.Name())
+	case *UnaryNode:
+		m.buf.WriteString("<mrow>")
+		m.elem("mo", mathmlOps[n.Op.Type])
+		m.write(n.X, typesetPrec(n)+1)
+		m.buf.WriteString("</mrow>")
+	case *BinaryNode:
+		m.binary(n)
+	case *CallNode:
+		m.call(n)
+	case *MatrixNode:
+		m.buf.WriteString("<mrow><mo>[</mo><mtable><mtr>")
+		row := 0
+		for i, elem := range n.Elems {
+			if row < len(n.Breaks) && n.Breaks[row] == i {
+				m.buf.WriteString("</mtr><mtr>")
+				row++
+			}
+			m.buf.WriteString("<mtd>")
+			m.write(elem, 0)
+			m.buf.WriteString("</mtd>")
+		}
+		m.buf.WriteString("</mtr></mtable><mo>]</mo></mrow>")
+	}
+
+	if parens {
+		m.buf.WriteString("<mo>)</mo></mrow>")
+	}
+}
+
+func (m *mathmlWriter) number(value string) {
+	neg, num, den, exp := splitNumber(value)
+	if neg {
+		m.buf.WriteString("<mrow>")
+		m.elem("mo", mathmlOps[UnaryMin])
+	}
+
+	switch {
+	case den != "":
+		m.buf.WriteString("<mfrac>")
+		m.elem("mn", num)
+		m.elem("mn", den)
+		m.buf.WriteString("</mfrac>")
+	case exp != "":
+		m.buf.WriteString("<mrow>")
+		m.elem("mn", num)
+		m.elem("mo", mathmlOps[Mul])
+		m.buf.WriteString("<msup>")
+		m.elem("mn", "10")
+		m.elem("mn", exp)
+		m.buf.WriteString("</msup></mrow>")
+	default:
+		m.elem("mn", num)
+	}
+
+	if neg {
+		m.buf.WriteString("</mrow>")
+	}
+}
+
+func (m *mathmlWriter) ident(name string) {
+	if sym, ok := m.symbols[name]; ok {
+		m.elem("mi", sym)
+		return
+	}
+
+	if base, sub := splitIdent(name); sub != "" {
+		m.buf.WriteString("<msub>")
+		m.ident(base)
+		if strings.Trim(sub, "0123456789") == "" {
+			m.elem("mn", sub)
+		} else {
+			m.ident(sub)
+		}
+		m.buf.WriteString("</msub>")
+		return
+	}
+
+	m.elem("mi", name)
+}
+
+func (m *mathmlWriter) binary(n *BinaryNode) {
+	switch n.Op.Type {
+	case Div:
+		m.buf.WriteString("<mfrac>")
+		m.write(n.Lhs, 0)
+		m.write(n.Rhs, 0)
+		m.buf.WriteString("</mfrac>")
+		return
+	case Pow:
+		m.buf.WriteString("<msup>")
+		m.base(n.Lhs)
+		m.write(n.Rhs, 0)
+		m.buf.WriteString("</msup>")
+		return
+	}
+
+	lhsPrec, rhsPrec := operandPrecs(n.Op)
+	if isNeg(n.Rhs) {
+		rhsPrec = atomPrec
+	}
+
+	m.buf.WriteString("<mrow>")
+	m.write(n.Lhs, lhsPrec)
+	switch op, ok := mathmlOps[n.Op.Type]; {
+	case isJuxtaposed(n):
+		// Invisible times
+		m.elem("mo", "⁢")
+	case ok:
+		m.elem("mo", op)
+	default:
+		m.elem("mo", n.Op.Value)
+	}
+	m.write(n.Rhs, rhsPrec)
+	m.buf.WriteString("</mrow>")
+}
+
+func (m *mathmlWriter) call(n *CallNode) {
+	name := n.Func.Value
+	if len(n.Args) == 1 {
+		arg := n.Args[0]
+		switch name {
+		case "sqrt":
+			m.buf.WriteString("<msqrt>")
+			m.write(arg, 0)
+			m.buf.WriteString("</msqrt>")
+			return
+		case "abs":
+			m.delimit("|", arg, "|")
+			return
+		case "floor":
+			m.delimit("⌊", arg, "⌋")
+			return
+		case "ceil":
+			m.delimit("⌈", arg, "⌉")
+			return
+		case "fact":
+			m.buf.WriteString("<mrow>")
+			m.base(arg)
+			m.elem("mo", "!")
+			m.buf.WriteString("</mrow>")
+			return
+		}
+	}
+
+	m.buf.WriteString("<mrow>")
+	args := n.Args
+	switch {
+	case name == "logn" && len(args) == 2:
+		m.buf.WriteString("<msub>")
+		m.elem("mi", "log")
+		m.write(args[0], 0)
+		m.buf.WriteString("</msub>")
+		args = args[1:]
+	case mathmlFuncs[name] != "":
+		m.elem("mi", mathmlFuncs[name])
+	default:
+		m.elem("mi", name)
+	}
+
+	// Function application
+	m.elem("mo", "⁡")
+	m.buf.WriteString("<mrow><mo>(</mo>")
+	for i, arg := range args {
+		if i > 0 {
+			m.elem("mo", ",")
+		}
+		m.write(arg, 0)
+	}
+	m.buf.WriteString("<mo>)</mo></mrow></mrow>")
+}
+
+func (m *mathmlWriter) base(n Node) {
+	if isBase(n) {
+		m.write(n, 0)
+	} else {
+		m.write(n, atomPrec+1)
+	}
+}
+
+func (m *mathmlWriter) delimit(left string, n Node, right string) {
+	m.buf.WriteString("<mrow>")
+	m.elem("mo", left)
+	m.write(n, 0)
+	m.elem("mo", right)
+	m.buf.WriteString("</mrow>")
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestToLaTeX(t *testing.T) {
+	exprs := map[string]string{
+		"sqrt(alpha**2 + 1)/2":    `\frac{\sqrt{\alpha^{2} + 1}}{2}`,
+		"2*x**2 - 3*x + 1":        `2x^{2} - 3x + 1`,
+		"-(x**2)":                 `-x^{2}`,
+		"-x**2":                   `\left(-x\right)^{2}`,
+		"(1/2)**x":                `\left(\frac{1}{2}\right)^{x}`,
+		"x**y**z":                 `\left(x^{y}\right)^{z}`,
+		"sin(x)**2 + cos(theta)":  `\sin\left(x\right)^{2} + \cos\left(\theta\right)`,
+		"x_1 + beta_max":          `x_{1} + \beta_{\mathit{max}}`,
+		"area*(r + 1)":            `\mathit{area} \cdot \left(r + 1\right)`,
+		"1 - -x":                  `1 - \left(-x\right)`,
+		"1 - (2 - 3)":             `1 - \left(2 - 3\right)`,
+		"abs(x - 1) + floor(y/2)": `\left|x - 1\right| + \left\lfloor \frac{y}{2} \right\rfloor`,
+		"fact(n + 1)/fact(n)":     `\frac{\left(n + 1\right)!}{n!}`,
+		"logn(2, x) >= inf":       `\log_{2}\left(x\right) \geq \infty`,
+		"max(a, b) % 3":           `\max\left(a, b\right) \bmod 3`,
+		"reverse_bits(x)":         `\operatorname{reverse\_bits}\left(x\right)`,
+		"[1, 2; 3, 4]":            `\begin{bmatrix}1 & 2 \\ 3 & 4\end{bmatrix}`,
+		"24e3 + 0xff":             `24 \cdot 10^{3} + 0xff`,
+		"y = 5 & 3 | ~x":          `y = 5 \mathbin{\&} 3 \mathbin{|} \lnot x`,
+		"x += 2":                  `x \mathrel{+=} 2`,
+	}
+
+	for expr, expected := range exprs {
+		tree, err := Parse(expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing '%s': %s", expr, err)
+			continue
+		}
+
+		if res := ToLaTeX(tree, nil); res != expected {
+			t.Errorf("wrong LaTeX of '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	// Custom symbols replace the default ones
+	tree, _ := Parse("alpha + x")
+	if res := ToLaTeX(tree, map[string]string{"x": `\xi`}); res != `\mathit{alpha} + \xi` {
+		t.Errorf("wrong LaTeX with custom symbols (got %s)", res)
+	}
+}
+
+func TestToMathML(t *testing.T) {
+	exprs := map[string]string{
+		"x**2/2":    "<mfrac><msup><mi>x</mi><mn>2</mn></msup><mn>2</mn></mfrac>",
+		"2*alpha":   "<mrow><mn>2</mn><mo>⁢</mo><mi>α</mi></mrow>",
+		"a < -b":    "<mrow><mi>a</mi><mo>&lt;</mo><mrow><mo>(</mo><mrow><mo>−</mo><mi>b</mi></mrow><mo>)</mo></mrow></mrow>",
+		"sqrt(x_1)": "<msqrt><msub><mi>x</mi><mn>1</mn></msub></msqrt>",
+		"atan(x)":   "<mrow><mi>arctan</mi><mo>⁡</mo><mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow></mrow>",
+		"[1; 2]":    "<mrow><mo>[</mo><mtable><mtr><mtd><mn>1</mn></mtd></mtr><mtr><mtd><mn>2</mn></mtd></mtr></mtable><mo>]</mo></mrow>",
+	}
+
+	for expr, expected := range exprs {
+		tree, err := Parse(expr)
+		if err != nil {
+			t.Errorf("unexpected error parsing '%s': %s", expr, err)
+			continue
+		}
+
+		expected = `<math xmlns="http://www.w3.org/1998/Math/MathML">` + expected + "</math>"
+		if res := ToMathML(tree, nil); res != expected {
+			t.Errorf("wrong MathML of '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	// The output has to be well-formed XML
+	for _, expr := range []string{"-(x**2)/2 + sin(alpha)*abs(-1/3) <= [x_1, 2]", "5 & 3 > 1", "fact(n)/logn(2, 8)"} {
+		tree, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dec := xml.NewDecoder(strings.NewReader(ToMathML(tree, nil)))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("malformed MathML of '%s': %s", expr, err)
+				break
+			}
+		}
+	}
+}
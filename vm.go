@@ -0,0 +1,476 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrCompileMatrix is returned when compiling an expression with a matrix
+// literal
+var ErrCompileMatrix = errors.New("Can't compile matrices")
+
+// Program is an expression compiled to bytecode, for evaluating it many times
+// with changing variables. Constants are parsed once and variables are
+// resolved to slots, and the values on the stack of the virtual machine are
+// reused between runs, so running a program barely allocates.
+//
+// A program runs on the parser it was compiled by and isn't safe for
+// concurrent use.
+type Program struct {
+	p    *Parser
+	code []instruction
+	// toks holds the token of every instruction, for errors
+	toks   []*Token
+	consts []*big.Rat
+	// names holds the variable names by slot, starting with those returned by
+	// Vars
+	names []string
+	nvars int
+	calls []compiledCall
+	// evals holds calls to functions taking expressions, which are evaluated
+	// as trees
+	evals []*CallNode
+
+	stack []*big.Rat
+	slots []*big.Rat
+	// tmp holds intermediate results of integer operations
+	tmp big.Int
+	// round is used to round results in the parser's number mode
+	round big.Float
+
+	// The same for running on float64, see RunFloat64. fcache holds the
+	// parser's variables converted to float64 by slot.
+	fconsts    []float64
+	fstack     []float64
+	fslots     []float64
+	fcache     []*big.Rat
+	fcacheVals []float64
+}
+
+type opcode uint8
+
+const (
+	opConst opcode = iota // push constant arg
+	opLoad                // push variable in slot arg
+	opStore               // assign the top to the variable in slot arg
+	opNeg                 // negate the top
+	opAdd                 // replace lhs and rhs by lhs + rhs
+	opSub                 // replace lhs and rhs by lhs - rhs
+	opMul                 // replace lhs and rhs by lhs * rhs
+	opDiv                 // replace lhs and rhs by lhs / rhs
+	opPow                 // replace lhs and rhs by lhs ** rhs
+	opRem                 // replace lhs and rhs by lhs % rhs
+	opCmp                 // replace lhs and rhs by the comparison of the token
+	opOp                  // apply any other operator of the token
+	opCall                // call function arg with arguments on the stack
+	opEval                // evaluate call arg to a function taking expressions
+)
+
+// instruction is a single bytecode instruction. Binary operators find their
+// left hand side on top of the stack, as it's evaluated after the right hand
+// side like in expressions. Operations on integers are done in place on the
+// numerators, which saves normalizing the results.
+type instruction struct {
+	op  opcode
+	arg int32
+}
+
+type compiledCall struct {
+	function function
+	arity    int
+	// float64 is the float64 implementation of the function, if any
+	float64 float64Func
+}
+
+// Compile compiles an expression to a program running on the parser.
+//
+// Example:
+//     prog, err := p.Compile("x**2 + y")
+//     for i := int64(0); i < 100; i++ {
+//         res, err := prog.Run(big.NewRat(i, 1), big.NewRat(1, 2))
+//     }
+func (p *Parser) Compile(expr string) (*Program, error) {
+	tree, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.CompileNode(tree)
+}
+
+// CompileNode compiles a parsed expression to a program running on the parser
+func (p *Parser) CompileNode(n Node) (*Program, error) {
+	prog := &Program{p: p, names: freeVariables(n)}
+	prog.nvars = len(prog.names)
+
+	c := &compiler{prog: prog, slots: make(map[string]int)}
+	for i, name := range prog.names {
+		c.slots[name] = i
+	}
+	if err := c.compile(n); err != nil {
+		return nil, err
+	}
+
+	prog.stack = make([]*big.Rat, c.maxDepth)
+	for i := range prog.stack {
+		prog.stack[i] = new(big.Rat)
+	}
+	prog.slots = make([]*big.Rat, len(prog.names))
+	prog.fstack = make([]float64, c.maxDepth)
+	prog.fslots = make([]float64, len(prog.names))
+	prog.fcache = make([]*big.Rat, len(prog.names))
+	prog.fcacheVals = make([]float64, len(prog.names))
+
+	return prog, nil
+}
+
+// Vars returns the variables of the program, except constants like pi, in
+// the order Run takes them
+func (prog *Program) Vars() []string {
+	return prog.names[:prog.nvars]
+}
+
+// Run runs the program with the variables returned by Vars bound to vars.
+// Variables without a value, or with a nil value, are looked up in the
+// parser's variables.
+func (prog *Program) Run(vars ...*big.Rat) (*big.Rat, error) {
+	if len(vars) > prog.nvars {
+		return nil, fmt.Errorf("Too many variables (expected at most %d, got %d)", prog.nvars, len(vars))
+	}
+
+	copy(prog.slots, vars)
+	for i := len(vars); i < len(prog.slots); i++ {
+		prog.slots[i] = nil
+	}
+
+	stack, sp := prog.stack, 0
+	for i, in := range prog.code {
+		switch in.op {
+		case opConst:
+			stack[sp].Set(prog.consts[in.arg])
+			sp++
+		case opLoad:
+			x := prog.slots[in.arg]
+			if x == nil {
+				var err error
+				if x, err = prog.p.lookup(prog.toks[i]); err != nil {
+					return nil, err
+				}
+			}
+			stack[sp].Set(x)
+			sp++
+		case opStore:
+			val := new(big.Rat).Set(stack[sp-1])
+			prog.p.setVar(prog.names[in.arg], val)
+			if prog.slots[in.arg] != nil {
+				prog.slots[in.arg] = val
+			}
+		case opNeg:
+			stack[sp-1].Neg(stack[sp-1])
+		case opAdd:
+			sp--
+			if lhs, rhs := stack[sp], stack[sp-1]; lhs.IsInt() && rhs.IsInt() {
+				rhs.Num().Add(lhs.Num(), rhs.Num())
+			} else {
+				rhs.Add(lhs, rhs)
+			}
+		case opSub:
+			sp--
+			if lhs, rhs := stack[sp], stack[sp-1]; lhs.IsInt() && rhs.IsInt() {
+				rhs.Num().Sub(lhs.Num(), rhs.Num())
+			} else {
+				rhs.Sub(lhs, rhs)
+			}
+		case opMul:
+			sp--
+			if lhs, rhs := stack[sp], stack[sp-1]; lhs.IsInt() && rhs.IsInt() {
+				rhs.Num().Mul(lhs.Num(), rhs.Num())
+			} else {
+				rhs.Mul(lhs, rhs)
+			}
+		case opDiv:
+			sp--
+			if stack[sp-1].Sign() == 0 {
+				return nil, ErrDivisionByZero
+			}
+			stack[sp-1].Quo(stack[sp], stack[sp-1])
+		case opPow:
+			sp--
+			if lhs, rhs := stack[sp], stack[sp-1]; lhs.IsInt() && rhs.IsInt() && rhs.Sign() >= 0 {
+				prog.tmp.Exp(lhs.Num(), rhs.Num(), nil)
+				rhs.Num().Set(&prog.tmp)
+				break
+			}
+			res, err := executeExpression(prog.toks[i], stack[sp], stack[sp-1])
+			if err != nil {
+				return nil, err
+			}
+			stack[sp-1].Set(res)
+		case opRem:
+			sp--
+			lhs, rhs := stack[sp], stack[sp-1]
+			if rhs.Sign() == 0 {
+				return nil, ErrDivisionByZero
+			}
+			if lhs.IsInt() && rhs.IsInt() {
+				// The remainder has the sign of the divisor
+				prog.tmp.Rem(lhs.Num(), rhs.Num())
+				if prog.tmp.Sign() != 0 && prog.tmp.Sign() != rhs.Sign() {
+					prog.tmp.Add(&prog.tmp, rhs.Num())
+				}
+				rhs.Num().Set(&prog.tmp)
+				break
+			}
+			rhs.Set(Mod(lhs, rhs))
+		case opCmp:
+			sp--
+			stack[sp-1].SetInt64(compare(prog.toks[i].Type, stack[sp].Cmp(stack[sp-1])))
+		case opOp:
+			var lhs *big.Rat
+			if !operators[prog.toks[i].Type].unary {
+				sp--
+				lhs = stack[sp]
+			}
+			res, err := executeExpression(prog.toks[i], lhs, stack[sp-1])
+			if err != nil {
+				return nil, err
+			}
+			stack[sp-1].Set(res)
+		case opCall:
+			call := prog.calls[in.arg]
+			sp -= call.arity
+			res, err := prog.call(prog.toks[i], call, stack[sp:sp+call.arity])
+			if err != nil {
+				return nil, err
+			}
+			stack[sp].Set(res)
+			sp++
+		case opEval:
+			res, err := prog.p.evalCall(prog.evals[in.arg], prog.scope())
+			if err != nil {
+				return nil, err
+			}
+			x, err := scalar(res)
+			if err != nil {
+				return nil, err
+			}
+			stack[sp].Set(x)
+			sp++
+		}
+
+		// Values loaded from variables aren't rounded, like in expressions
+		if prog.p.Mode != ExactMode && in.op != opLoad && in.op != opStore {
+			if _, err := prog.p.roundRat(stack[sp-1], stack[sp-1], &prog.round); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return new(big.Rat).Set(stack[0]), nil
+}
+
+// compare gives 1 if a comparison of type op is true for the result of Cmp,
+// and 0 otherwise
+func compare(op TokenType, cmp int) int64 {
+	var res bool
+	switch op {
+	case EqEq:
+		res = cmp == 0
+	case NotEq:
+		res = cmp != 0
+	case Gt:
+		res = cmp > 0
+	case GtEq:
+		res = cmp >= 0
+	case Lt:
+		res = cmp < 0
+	case LtEq:
+		res = cmp <= 0
+	}
+
+	if res {
+		return 1
+	}
+	return 0
+}
+
+// call calls a function with the arguments on the stack. The arguments are
+// passed as they are, without copying them.
+func (prog *Program) call(tok *Token, call compiledCall, args []*big.Rat) (*big.Rat, error) {
+	// The parser can be made deterministic after compiling
+	if err := prog.p.checkCall(tok, call.function, call.arity); err != nil {
+		return nil, err
+	}
+
+	if call.function.valueFn != nil {
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			values[i] = arg
+		}
+		res, err := prog.p.call(tok, call.function, values)
+		if err != nil {
+			return nil, err
+		}
+		return scalar(res)
+	}
+
+	if call.function.integer {
+		for _, arg := range args {
+			if !arg.IsInt() {
+				return nil, fmt.Errorf("Expecting integers for ‘%s’", tok)
+			}
+		}
+	}
+
+	return call.function.fn(prog.p, args)
+}
+
+// scope returns the bound variables, for evaluating calls to functions taking
+// expressions
+func (prog *Program) scope() map[string]*big.Rat {
+	scope := make(map[string]*big.Rat)
+	for i, val := range prog.slots {
+		if val != nil {
+			scope[prog.names[i]] = val
+		}
+	}
+
+	return scope
+}
+
+// compiler compiles a tree to the bytecode of a program
+type compiler struct {
+	prog            *Program
+	slots           map[string]int
+	depth, maxDepth int
+}
+
+// emit adds an instruction that changes the stack depth by delta
+func (c *compiler) emit(op opcode, arg int, tok *Token, delta int) {
+	c.prog.code = append(c.prog.code, instruction{op, int32(arg)})
+	c.prog.toks = append(c.prog.toks, tok)
+
+	c.depth += delta
+	if c.depth > c.maxDepth {
+		c.maxDepth = c.depth
+	}
+}
+
+// slot returns the slot of a variable, adding one if it has none yet
+func (c *compiler) slot(name string) int {
+	if i, ok := c.slots[name]; ok {
+		return i
+	}
+
+	c.slots[name] = len(c.prog.names)
+	c.prog.names = append(c.prog.names, name)
+	return c.slots[name]
+}
+
+func (c *compiler) compile(n Node) error {
+	switch n := n.(type) {
+	case *NumberNode:
+		c.emit(opConst, len(c.prog.consts), n.Tok, 1)
+		c.prog.consts = append(c.prog.consts, n.Value)
+		f, _ := n.Value.Float64()
+		c.prog.fconsts = append(c.prog.fconsts, f)
+	case *IdentNode:
+		c.emit(opLoad, c.slot(n.Name()), n.Tok, 1)
+	case *UnaryNode:
+		if err := c.compile(n.X); err != nil {
+			return err
+		}
+		if n.Op.Is(UnaryMin) {
+			c.emit(opNeg, 0, n.Op, 0)
+		} else {
+			c.emit(opOp, 0, n.Op, 0)
+		}
+	case *BinaryNode:
+		return c.binary(n)
+	case *CallNode:
+		return c.call(n)
+	case *MatrixNode:
+		return ErrCompileMatrix
+	default:
+		return fmt.Errorf("Invalid node ‘%T’", n)
+	}
+
+	return nil
+}
+
+func (c *compiler) binary(n *BinaryNode) error {
+	if err := c.compile(n.Rhs); err != nil {
+		return err
+	}
+
+	if n.Op.IsAssignment() {
+		ident, ok := n.Lhs.(*IdentNode)
+		if !ok {
+			return ErrAssignToLiteral
+		}
+		if !n.Op.Is(Eq) {
+			c.emit(opLoad, c.slot(ident.Name()), ident.Tok, 1)
+			c.emit(opOp, 0, n.Op, -1)
+		}
+		c.emit(opStore, c.slot(ident.Name()), n.Op, 0)
+		return nil
+	}
+
+	if err := c.compile(n.Lhs); err != nil {
+		return err
+	}
+
+	op := opOp
+	switch n.Op.Type {
+	case Add:
+		op = opAdd
+	case Sub:
+		op = opSub
+	case Mul:
+		op = opMul
+	case Div:
+		op = opDiv
+	case Pow:
+		op = opPow
+	case Rem:
+		op = opRem
+	case EqEq, NotEq, Gt, GtEq, Lt, LtEq:
+		op = opCmp
+	}
+	c.emit(op, 0, n.Op, -1)
+
+	return nil
+}
+
+func (c *compiler) call(n *CallNode) error {
+	function, ok := funcs[n.Func.Value]
+	if !ok {
+		return fmt.Errorf("Undefined function ‘%s’", n.Func)
+	}
+	if err := c.prog.p.checkCall(n.Func, function, len(n.Args)); err != nil {
+		return err
+	}
+
+	if function.exprFn != nil {
+		c.emit(opEval, len(c.prog.evals), n.Func, 1)
+		c.prog.evals = append(c.prog.evals, n)
+		return nil
+	}
+
+	for _, arg := range n.Args {
+		if err := c.compile(arg); err != nil {
+			return err
+		}
+	}
+
+	// Calls without arguments still push their result
+	c.emit(opCall, len(c.prog.calls), n.Func, 1-len(n.Args))
+	c.prog.calls = append(c.prog.calls, compiledCall{function, len(n.Args), float64Funcs[n.Func.Value]})
+
+	return nil
+}
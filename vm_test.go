@@ -0,0 +1,161 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	exprs := []string{
+		"x**2 + y",
+		"-x**2 - -y / 3",
+		"(x + 1) % -y",
+		"x < y == (y >= x)",
+		"~x & 0xff | y << 2",
+		"pi * x**2 / y",
+		"max(x, y) + sqrt(abs(x)) - gcd(x, 6)",
+		"sum(i, 1, y, i * x)",
+		"x**0.5 + y**-2",
+		"x/2 % y + (x/3)**3",
+	}
+	vals := [][2]int64{{0, 1}, {3, 4}, {-7, 2}, {12, 3}}
+
+	for _, expr := range exprs {
+		p := New()
+		prog, err := p.Compile(expr)
+		if err != nil {
+			t.Errorf("unexpected error compiling '%s': %s", expr, err)
+			continue
+		}
+
+		for _, v := range vals {
+			x, y := big.NewRat(v[0], 1), big.NewRat(v[1], 1)
+			vars := map[string]*big.Rat{"x": x, "y": y}
+			args := make([]*big.Rat, len(prog.Vars()))
+			for i, name := range prog.Vars() {
+				args[i] = vars[name]
+			}
+			res, err := prog.Run(args...)
+
+			p.Variables["x"], p.Variables["y"] = x, y
+			expected, expectedErr := p.Run(expr)
+			if (err != nil) != (expectedErr != nil) {
+				t.Errorf("wrong error running '%s' with %v (expected %v, got %v)", expr, v, expectedErr, err)
+				continue
+			}
+			if err == nil && res.Cmp(expected) != 0 {
+				t.Errorf("wrong result of '%s' with %v (expected %s, got %s)", expr, v, expected, res)
+			}
+		}
+	}
+}
+
+func TestProgramRun(t *testing.T) {
+	p := New()
+	prog, err := p.Compile("a = b * 2 + c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars := prog.Vars(); len(vars) != 3 || vars[0] != "a" || vars[2] != "c" {
+		t.Errorf("wrong variables (got %v)", vars)
+	}
+
+	// Unbound variables come from the parser
+	p.Variables["c"] = big.NewRat(1, 2)
+	res, err := prog.Run(nil, big.NewRat(3, 1))
+	if err != nil || res.Cmp(big.NewRat(13, 2)) != 0 {
+		t.Errorf("wrong result (expected 13/2, got %s, %v)", res, err)
+	}
+	if a, _ := p.GetVar("a"); a.Cmp(res) != 0 {
+		t.Errorf("assignment wasn't stored (got %s)", a)
+	}
+
+	// The result isn't changed by later runs
+	if _, err := prog.Run(nil, big.NewRat(5, 1)); err != nil || res.Cmp(big.NewRat(13, 2)) != 0 {
+		t.Errorf("result changed by a later run (got %s, %v)", res, err)
+	}
+
+	prog, _ = p.Compile("x += 1")
+	p.Run("x = 1")
+	for i := 0; i < 3; i++ {
+		prog.Run()
+	}
+	if x, _ := p.GetVar("x"); x.Cmp(big.NewRat(4, 1)) != 0 {
+		t.Errorf("wrong compound assignment (expected 4, got %s)", x)
+	}
+
+	runErrs := []string{"1 / (x - x)", "x & 0.5", "undefined + x", "fact(-x)"}
+	for _, expr := range runErrs {
+		prog, err := New().Compile(expr)
+		if err != nil {
+			t.Errorf("unexpected error compiling '%s': %s", expr, err)
+			continue
+		}
+		if res, err := prog.Run(big.NewRat(1, 1)); err == nil {
+			t.Errorf("expected error running '%s' (got %s)", expr, res)
+		}
+	}
+
+	compileErrs := []string{"[1, 2] * x", "2 = x", "nope(x)", "sin(x, x)", "1 +"}
+	for _, expr := range compileErrs {
+		if _, err := New().Compile(expr); err == nil {
+			t.Errorf("expected error compiling '%s'", expr)
+		}
+	}
+
+	if _, err := prog.Run(big.NewRat(1, 1), big.NewRat(1, 1)); err == nil {
+		t.Errorf("expected error running with too many variables")
+	}
+
+	// Deterministic mode is checked when running
+	p = New()
+	prog, err = p.Compile("x + rand()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Deterministic = true
+	if res, err := prog.Run(big.NewRat(1, 1)); err == nil {
+		t.Errorf("expected error running random program in deterministic mode (got %s)", res)
+	}
+}
+
+const benchExpr = "3*x**2 - 2*x*y + (x + 1) % 5 - y/7"
+
+func BenchmarkRun(b *testing.B) {
+	p := New()
+	for i := 0; i < b.N; i++ {
+		p.Variables["x"] = big.NewRat(int64(i%1000), 1)
+		p.Variables["y"] = big.NewRat(int64(i%7), 2)
+		if _, err := p.Run(benchExpr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvalNode(b *testing.B) {
+	p := New()
+	tree, _ := Parse(benchExpr)
+	for i := 0; i < b.N; i++ {
+		p.Variables["x"] = big.NewRat(int64(i%1000), 1)
+		p.Variables["y"] = big.NewRat(int64(i%7), 2)
+		if _, err := p.EvalNode(tree); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgram(b *testing.B) {
+	prog, _ := New().Compile(benchExpr)
+	x, y := new(big.Rat), new(big.Rat)
+	for i := 0; i < b.N; i++ {
+		x.SetInt64(int64(i % 1000))
+		y.SetFrac64(int64(i%7), 2)
+		if _, err := prog.Run(x, y); err != nil {
+			b.Fatal(err)
+		}
+	}
+}